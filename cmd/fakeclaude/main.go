@@ -0,0 +1,315 @@
+// Fake Claude Code CLI for offline testing.
+//
+// fakeclaude accepts the flags the gateway passes to the real CLI and emits
+// stream-json output driven by a scenario file, so the gateway can be
+// exercised end to end without a Claude install or network access.
+//
+// The scenario is chosen by, in order:
+//   - FAKECLAUDE_SCENARIO: path to a scenario file
+//   - a "[scenario:NAME]" marker in the prompt, loaded from
+//     FAKECLAUDE_SCENARIO_DIR/NAME.json
+//   - the built-in echo scenario
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const version = "2.0.0 (Claude Code)"
+
+// Step is a single action of a scenario.
+type Step struct {
+	// Type is one of init, text, tool_use, tool_result, result, sleep,
+	// stderr, raw, write, remove or exit.
+	Type string `json:"type"`
+
+	// Path is the file, relative to the working directory, that write
+	// fills with Content and remove deletes.
+	Path string `json:"path,omitempty"`
+
+	Text     string                 `json:"text,omitempty"`
+	Name     string                 `json:"name,omitempty"`
+	Input    map[string]interface{} `json:"input,omitempty"`
+	Content  string                 `json:"content,omitempty"`
+	IsError  bool                   `json:"is_error,omitempty"`
+	Subtype  string                 `json:"subtype,omitempty"`
+	Result   string                 `json:"result,omitempty"`
+	Usage    map[string]int         `json:"usage,omitempty"`
+	CostUSD  float64                `json:"cost_usd,omitempty"`
+	Ms       int                    `json:"ms,omitempty"`
+	Line     string                 `json:"line,omitempty"`
+	Code     int                    `json:"code,omitempty"`
+	Repeat   int                    `json:"repeat,omitempty"`
+	Interval int                    `json:"interval_ms,omitempty"`
+}
+
+// Scenario is a scripted CLI run.
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+var scenarioMarker = regexp.MustCompile(`\[scenario:([A-Za-z0-9_-]+)\]`)
+
+func main() {
+	var (
+		prompt       = flag.String("p", "", "prompt")
+		model        = flag.String("model", "claude-sonnet-4-5-20250929", "model")
+		systemPrompt = flag.String("system-prompt", "", "system prompt")
+		outputFormat = flag.String("output-format", "text", "output format")
+		resume       = flag.String("resume", "", "session to resume")
+		showVersion  = flag.Bool("version", false, "print version")
+	)
+	flag.Bool("verbose", false, "verbose output")
+	flag.Bool("dangerously-skip-permissions", false, "skip permission prompts")
+	flag.String("input-format", "text", "input format")
+	flag.String("disallowedTools", "", "disallowed tools")
+	flag.String("allowedTools", "", "allowed tools")
+	flag.String("permission-mode", "", "permission mode")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version)
+		return
+	}
+
+	if *outputFormat != "stream-json" {
+		fmt.Fprintln(os.Stderr, "fakeclaude only supports --output-format stream-json")
+		os.Exit(2)
+	}
+
+	sc, err := loadScenario(*prompt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	sessionID := *resume
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+
+	r := &runner{
+		prompt:       *prompt,
+		model:        *model,
+		systemPrompt: *systemPrompt,
+		sessionID:    sessionID,
+		start:        time.Now(),
+		enc:          json.NewEncoder(os.Stdout),
+	}
+	os.Exit(r.run(sc))
+}
+
+// loadScenario resolves the scenario for this invocation.
+func loadScenario(prompt string) (*Scenario, error) {
+	path := os.Getenv("FAKECLAUDE_SCENARIO")
+	if path == "" {
+		if m := scenarioMarker.FindStringSubmatch(prompt); m != nil {
+			path = filepath.Join(os.Getenv("FAKECLAUDE_SCENARIO_DIR"), m[1]+".json")
+		}
+	}
+	if path == "" {
+		return defaultScenario(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// defaultScenario echoes the prompt back.
+func defaultScenario() *Scenario {
+	return &Scenario{Steps: []Step{
+		{Type: "init"},
+		{Type: "text", Text: "You said: {{prompt}}"},
+		{Type: "result"},
+	}}
+}
+
+type runner struct {
+	prompt       string
+	model        string
+	systemPrompt string
+	sessionID    string
+	start        time.Time
+	enc          *json.Encoder
+	lastText     string
+	lastToolID   string
+	outputTokens int
+}
+
+func (r *runner) run(sc *Scenario) int {
+	for _, step := range sc.Steps {
+		repeat := step.Repeat
+		if repeat < 1 {
+			repeat = 1
+		}
+		for i := 0; i < repeat; i++ {
+			if i > 0 && step.Interval > 0 {
+				time.Sleep(time.Duration(step.Interval) * time.Millisecond)
+			}
+			if code, exit := r.step(step); exit {
+				return code
+			}
+		}
+	}
+	return 0
+}
+
+// step executes one scenario step and reports whether the process should exit.
+func (r *runner) step(s Step) (int, bool) {
+	switch s.Type {
+	case "init":
+		r.emit(map[string]interface{}{
+			"type":           "system",
+			"subtype":        "init",
+			"session_id":     r.sessionID,
+			"model":          r.model,
+			"cwd":            mustGetwd(),
+			"tools":          []string{"Bash", "Edit", "Read", "Write", "Glob", "Grep"},
+			"permissionMode": "bypassPermissions",
+			"apiKeySource":   "none",
+		})
+	case "text":
+		text := r.expand(s.Text)
+		r.lastText = text
+		r.outputTokens += len(strings.Fields(text))
+		r.emit(r.assistant([]interface{}{
+			map[string]interface{}{"type": "text", "text": text},
+		}))
+	case "tool_use":
+		r.lastToolID = "toolu_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+		input := s.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		r.emit(r.assistant([]interface{}{
+			map[string]interface{}{"type": "tool_use", "id": r.lastToolID, "name": s.Name, "input": input},
+		}))
+	case "tool_result":
+		r.emit(map[string]interface{}{
+			"type":       "user",
+			"session_id": r.sessionID,
+			"message": map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{map[string]interface{}{
+					"type":        "tool_result",
+					"tool_use_id": r.lastToolID,
+					"content":     r.expand(s.Content),
+					"is_error":    s.IsError,
+				}},
+			},
+		})
+	case "result":
+		r.emit(r.result(s))
+	case "sleep":
+		time.Sleep(time.Duration(s.Ms) * time.Millisecond)
+	case "stderr":
+		fmt.Fprintln(os.Stderr, r.expand(s.Text))
+	case "raw":
+		fmt.Fprintln(os.Stdout, r.expand(s.Line))
+	case "write":
+		os.MkdirAll(filepath.Dir(s.Path), 0755)
+		if err := os.WriteFile(s.Path, []byte(r.expand(s.Content)), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+	case "remove":
+		if err := os.Remove(s.Path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+	case "exit":
+		return s.Code, true
+	default:
+		fmt.Fprintf(os.Stderr, "unknown scenario step %q\n", s.Type)
+		return 2, true
+	}
+	return 0, false
+}
+
+func (r *runner) assistant(content []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "assistant",
+		"session_id": r.sessionID,
+		"message": map[string]interface{}{
+			"id":      "msg_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24],
+			"type":    "message",
+			"role":    "assistant",
+			"model":   r.model,
+			"content": content,
+		},
+	}
+}
+
+func (r *runner) result(s Step) map[string]interface{} {
+	subtype := s.Subtype
+	if subtype == "" {
+		subtype = "success"
+	}
+	isError := s.IsError || strings.HasPrefix(subtype, "error")
+
+	result := r.expand(s.Result)
+	if result == "" && !isError {
+		result = r.lastText
+	}
+
+	usage := map[string]int{
+		"input_tokens":                len(strings.Fields(r.prompt + " " + r.systemPrompt)),
+		"output_tokens":               r.outputTokens,
+		"cache_creation_input_tokens": 0,
+		"cache_read_input_tokens":     0,
+	}
+	for k, v := range s.Usage {
+		usage[k] = v
+	}
+
+	cost := s.CostUSD
+	if cost == 0 {
+		cost = float64(usage["input_tokens"]*3+usage["output_tokens"]*15) / 1e6
+	}
+
+	return map[string]interface{}{
+		"type":           "result",
+		"subtype":        subtype,
+		"is_error":       isError,
+		"session_id":     r.sessionID,
+		"result":         result,
+		"num_turns":      1,
+		"duration_ms":    time.Since(r.start).Milliseconds(),
+		"total_cost_usd": cost,
+		"usage":          usage,
+	}
+}
+
+func (r *runner) emit(v interface{}) {
+	_ = r.enc.Encode(v)
+}
+
+// expand replaces {{prompt}}, {{model}} and {{session_id}} placeholders.
+func (r *runner) expand(s string) string {
+	return strings.NewReplacer(
+		"{{prompt}}", r.prompt,
+		"{{model}}", r.model,
+		"{{session_id}}", r.sessionID,
+	).Replace(s)
+}
+
+func mustGetwd() string {
+	wd, _ := os.Getwd()
+	return wd
+}
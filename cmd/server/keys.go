@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"claude-code-api/internal/keys"
+)
+
+const keysUsage = `Usage: claude-api keys <command> [flags]
+
+Commands:
+  create --name NAME [--owner OWNER] [--scopes chat,models] [--models MODEL,...] [--expires 30d]
+  list
+  revoke KEY_ID
+
+Every command accepts --file to use another key store than KEYS_FILE.
+`
+
+// runKeys manages the key store from the command line and returns the exit
+// code.
+func runKeys(args []string) int {
+	if len(args) == 0 || (args[0] != "create" && args[0] != "list" && args[0] != "revoke") {
+		fmt.Fprint(os.Stderr, keysUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	file := fs.String("file", os.Getenv("KEYS_FILE"), "key store file")
+	name := fs.String("name", "", "name of the key")
+	owner := fs.String("owner", "", "who the key belongs to")
+	scopes := fs.String("scopes", strings.Join(keys.DefaultScopes, ","), "comma-separated scopes: "+strings.Join(keys.AllScopes, ", "))
+	allowed := fs.String("models", "", "comma-separated models the key may use; empty allows all")
+	expires := fs.String("expires", "", "expiry as a duration (720h, 30d) or date (2026-12-31); empty never expires")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "No key store: set KEYS_FILE or pass --file")
+		return 2
+	}
+	store, err := keys.Open(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		expiresAt, err := parseExpiry(*expires, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		k, secret, err := store.Create(keys.Options{
+			Name:      *name,
+			Owner:     *owner,
+			Scopes:    splitList(*scopes),
+			Models:    splitList(*allowed),
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Created key %s (%s)\n", k.ID, k.Name)
+		fmt.Println("Store it now, it will not be shown again:")
+		fmt.Println(secret)
+
+	case "list":
+		list, err := store.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tOWNER\tPREFIX\tSCOPES\tMODELS\tEXPIRES\tSTATUS")
+		for _, k := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Name, orDash(k.Owner), k.Prefix, strings.Join(k.Scopes, ","),
+				orDash(strings.Join(k.Models, ",")), formatUnix(k.ExpiresAt), keyStatus(k))
+		}
+		w.Flush()
+
+	case "revoke":
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, keysUsage)
+			return 2
+		}
+		k, err := store.Revoke(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Revoked key %s (%s)\n", k.ID, k.Name)
+	}
+	return 0
+}
+
+// parseExpiry turns --expires into a Unix time: a Go duration, a number of
+// days such as 30d, or a YYYY-MM-DD date.
+func parseExpiry(s string, now time.Time) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n).Unix(), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d).Unix(), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid --expires %q: use a duration such as 720h or 30d, or a date such as 2026-12-31", s)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return "never"
+	}
+	return time.Unix(ts, 0).UTC().Format("2006-01-02 15:04")
+}
+
+func keyStatus(k keys.Key) string {
+	switch {
+	case k.Disabled:
+		return "revoked"
+	case k.Expired(time.Now()):
+		return "expired"
+	}
+	return "active"
+}
@@ -13,9 +13,7 @@ import (
 	"time"
 
 	"claude-code-api/internal/api"
-	"claude-code-api/internal/claude"
 	"claude-code-api/internal/config"
-	"claude-code-api/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -29,6 +27,11 @@ func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
+	// Key management runs instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeys(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -36,87 +39,34 @@ func main() {
 	}
 
 	// Set log level
-	switch cfg.LogLevel {
-	case "debug":
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	case "warn":
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	case "error":
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	default:
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	}
+	config.SetLogLevel(cfg.LogLevel)
 
-	// Initialize Claude manager
-	manager := claude.NewManager(cfg)
+	// Live configuration, swapped on SIGHUP or config file change
+	live := config.NewReloader(cfg)
 
-	// Verify Claude availability
-	claudeVersion, err := manager.GetVersion()
+	// Wire the backends, stores and routes
+	gin.SetMode(gin.ReleaseMode)
+	server, err := api.NewServer(live, version)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Claude Code CLI not available")
+		log.Fatal().Err(err).Msg("Failed to start server")
 	}
-	log.Info().Str("claude_version", claudeVersion).Msg("Claude Code available")
 
-	// Setup Gin
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
-	router.Use(gin.Recovery())
-	router.Use(api.LoggingMiddleware())
-	router.Use(api.CORSMiddleware(cfg))
-	router.Use(api.AuthMiddleware(cfg))
-
-	// Create handlers
-	chatHandler := api.NewChatHandler(cfg, manager)
-	modelsHandler := api.NewModelsHandler(cfg, manager)
-
-	// Root endpoint
-	router.GET("/", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"name":        "Claude Code API Gateway",
-			"version":     version,
-			"description": "OpenAI-compatible API for Claude Code",
-			"endpoints": gin.H{
-				"chat":   "/v1/chat/completions",
-				"models": "/v1/models",
-			},
-			"docs":   "/docs",
-			"health": "/health",
-		})
-	})
-
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		cv, err := manager.GetVersion()
-		if err != nil {
-			c.JSON(http.StatusServiceUnavailable, models.HealthCheckResponse{
-				Status:  "unhealthy",
-				Version: version,
-			})
-			return
-		}
-
-		c.JSON(http.StatusOK, models.HealthCheckResponse{
-			Status:         "healthy",
-			Version:        version,
-			ClaudeVersion:  cv,
-			ActiveSessions: manager.ActiveSessionCount(),
-		})
-	})
-
-	// API routes
-	v1 := router.Group("/v1")
-	{
-		v1.POST("/chat/completions", chatHandler.HandleChatCompletion)
-		v1.GET("/models", modelsHandler.HandleListModels)
-		v1.GET("/models/capabilities", modelsHandler.HandleModelCapabilities)
-		v1.GET("/models/:model_id", modelsHandler.HandleGetModel)
+	// Verify the default backend is available
+	defaultBackend, err := server.Backends.Default()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Default backend not registered")
 	}
+	claudeVersion, err := defaultBackend.GetVersion()
+	if err != nil {
+		log.Fatal().Err(err).Str("backend", defaultBackend.Name()).Msg("Default backend not available")
+	}
+	log.Info().Str("backend", defaultBackend.Name()).Str("claude_version", claudeVersion).Msg("Claude Code available")
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	srv := &http.Server{
 		Addr:    addr,
-		Handler: router,
+		Handler: server.Router,
 	}
 
 	// Graceful shutdown
@@ -127,6 +77,21 @@ func main() {
 		}
 	}()
 
+	// Watch config file for changes
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go live.Watch(watchCtx, time.Duration(cfg.ConfigWatchSecs)*time.Second)
+
+	// Reload on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info().Msg("Received SIGHUP, reloading configuration")
+			_, _ = live.Reload()
+		}
+	}()
+
 	// Wait for interrupt
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -137,7 +102,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	manager.CleanupAll()
+	server.Shutdown()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/batches"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchesHandler handles batch requests.
+type BatchesHandler struct {
+	batches *batches.Manager
+}
+
+// NewBatchesHandler creates a new batches handler.
+func NewBatchesHandler(batches *batches.Manager) *BatchesHandler {
+	return &BatchesHandler{batches: batches}
+}
+
+// NewBatchExecutor returns the executor that runs batch requests through the
+// backends, producing the same responses as the synchronous endpoints.
+// Requests of batches created with a key from store, which may be nil, are
+// held to that key as it is when they run.
+func NewBatchExecutor(cfg *config.Reloader, backends *backend.Registry, store *keys.Store) batches.Executor {
+	return func(ctx context.Context, b batches.Batch, requestID string, body json.RawMessage) (int, interface{}, error) {
+		return executeChatCompletion(ctx, cfg.Get(), backends, store, b, requestID, body)
+	}
+}
+
+func executeChatCompletion(ctx context.Context, cfg *config.Config, backends *backend.Registry, store *keys.Store, batch batches.Batch, requestID string, body json.RawMessage) (int, interface{}, error) {
+	invalid := func(code, message string) (int, interface{}, error) {
+		return http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{Message: message, Type: "invalid_request_error", Code: code},
+		}, nil
+	}
+
+	var req models.ChatCompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return invalid("invalid_body", fmt.Sprintf("Invalid request: %v", err))
+	}
+	if req.Stream {
+		return invalid("stream_not_supported", "Streaming is not supported in batches")
+	}
+	prompt, systemPrompt := chatPrompt(&req)
+	if prompt == "" {
+		return invalid("missing_user_message", "At least one user message is required")
+	}
+
+	model := req.Model
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	if store != nil && batch.Owner != "" {
+		if k, err := store.Get(batch.Owner); err == nil {
+			if k.Disabled || k.Expired(time.Now()) {
+				return http.StatusUnauthorized, models.ErrorResponse{
+					Error: models.ErrorDetail{Message: "API key has expired or been revoked", Type: "authentication_error"},
+				}, nil
+			}
+			if !k.AllowsModel(model) {
+				return http.StatusForbidden, models.ErrorResponse{
+					Error: models.ErrorDetail{
+						Message: fmt.Sprintf("This API key may not use model '%s'", model),
+						Type:    "permission_error",
+						Code:    "model_not_allowed",
+					},
+				}, nil
+			}
+		}
+	}
+
+	breq := backend.Request{
+		RequestID:    requestID,
+		Model:        model,
+		Prompt:       prompt,
+		SystemPrompt: systemPrompt,
+		ProjectID:    req.ProjectID,
+		ReadOnly:     req.ReadOnly,
+		Principal:    batch.Owner,
+		Namespace:    batch.Namespace,
+	}
+	if serr := prepareRequest(cfg, &breq); serr != nil {
+		return serr.Status, serr.openAIError(), nil
+	}
+
+	b, err := backends.ForModel(model)
+	if err != nil {
+		return invalid("model_not_available", err.Error())
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.StreamingTimeoutSecs)*time.Second)
+	defer cancel()
+
+	run, err := b.CreateSession(runCtx, breq)
+	if errors.Is(err, backend.ErrBusy) {
+		return 0, nil, err
+	}
+	if serr := rateLimitError(err); serr != nil {
+		return serr.Status, serr.openAIError(), nil
+	}
+	if serr := quotaError(err); serr != nil {
+		return serr.Status, serr.openAIError(), nil
+	}
+	if err != nil {
+		return http.StatusServiceUnavailable, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to start Claude: %v", err),
+				Type:    "service_unavailable",
+				Code:    "claude_unavailable",
+			},
+		}, nil
+	}
+	// Returning before the run's last event must not leave its hooks
+	// waiting for a reader
+	defer run.Cancel()
+
+	var parts []string
+	var sessionID string
+	var changes *backend.WorkspaceChanges
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
+		}
+		switch ev.Type {
+		case backend.EventText:
+			parts = append(parts, ev.Text)
+		case backend.EventWorkspaceChanges:
+			changes = ev.Changes
+		case backend.EventError:
+			return http.StatusBadGateway, models.ErrorResponse{
+				Error: models.ErrorDetail{Message: ev.Error, Type: "api_error", Code: "claude_error"},
+			}, nil
+		}
+	}
+
+	resp := completionResponse(model, strings.Join(parts, "\n"), run.Usage(), sessionID, breq.ProjectID)
+	resp.WorkspaceChanges = changes
+	return http.StatusOK, resp, nil
+}
+
+func batchNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("No batch found with id '%s'.", id),
+			Type:    "invalid_request_error",
+			Code:    "batch_not_found",
+		},
+	})
+}
+
+// HandleCreateBatch handles POST /v1/batches
+func (h *BatchesHandler) HandleCreateBatch(c *gin.Context) {
+	var req models.CreateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	b, err := h.batches.Create(namespace(c), principal(c), req.InputFileID, req.Endpoint, req.CompletionWindow, req.Metadata)
+	var invalid *batches.InvalidRequestError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: invalid.Message,
+				Type:    "invalid_request_error",
+				Code:    "invalid_" + invalid.Param,
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to create batch: %v", err),
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// HandleListBatches handles GET /v1/batches
+func (h *BatchesHandler) HandleListBatches(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	list, hasMore := h.batches.List(namespace(c), c.Query("after"), limit)
+	resp := gin.H{"object": "list", "data": list, "has_more": hasMore}
+	if len(list) > 0 {
+		resp["first_id"] = list[0].ID
+		resp["last_id"] = list[len(list)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleGetBatch handles GET /v1/batches/:batch_id
+func (h *BatchesHandler) HandleGetBatch(c *gin.Context) {
+	id := c.Param("batch_id")
+	b, err := h.batches.Get(namespace(c), id)
+	if err != nil {
+		batchNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// HandleCancelBatch handles POST /v1/batches/:batch_id/cancel
+func (h *BatchesHandler) HandleCancelBatch(c *gin.Context) {
+	id := c.Param("batch_id")
+	b, err := h.batches.Cancel(namespace(c), id)
+	switch {
+	case errors.Is(err, batches.ErrNotFound):
+		batchNotFound(c, id)
+	case errors.Is(err, batches.ErrFinished):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Cannot cancel a batch with status '%s'.", b.Status),
+				Type:    "invalid_request_error",
+				Code:    "batch_finished",
+			},
+		})
+	default:
+		c.JSON(http.StatusOK, b)
+	}
+}
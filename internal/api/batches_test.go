@@ -0,0 +1,315 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/batches"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/files"
+	"claude-code-api/internal/projects"
+)
+
+func uploadFile(t *testing.T, url, purpose, name, content string) (files.File, int) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("purpose", purpose)
+	part, _ := w.CreateFormFile("file", name)
+	part.Write([]byte(content))
+	w.Close()
+
+	resp, err := http.Post(url+"/v1/files", w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var f files.File
+	json.NewDecoder(resp.Body).Decode(&f)
+	return f, resp.StatusCode
+}
+
+func batchLine(customID, prompt string) string {
+	return fmt.Sprintf(`{"custom_id":%q,"method":"POST","url":"/v1/chat/completions","body":{"model":"claude-sonnet-4-5-20250929","messages":[{"role":"user","content":%q}]}}`, customID, prompt)
+}
+
+func createBatch(t *testing.T, url, fileID string) batches.Batch {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/batches", "application/json",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create batch status = %d", resp.StatusCode)
+	}
+	var b batches.Batch
+	json.NewDecoder(resp.Body).Decode(&b)
+	return b
+}
+
+func waitForBatch(t *testing.T, url, id string) batches.Batch {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url + "/v1/batches/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b batches.Batch
+		json.NewDecoder(resp.Body).Decode(&b)
+		resp.Body.Close()
+		if b.Status.Terminal() {
+			return b
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not finish", id)
+	return batches.Batch{}
+}
+
+func readResults(t *testing.T, url, fileID string) map[string]batches.ResultLine {
+	t.Helper()
+	resp, err := http.Get(url + "/v1/files/" + fileID + "/content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	results := make(map[string]batches.ResultLine)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r batches.ResultLine
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("bad result line %q: %v", line, err)
+		}
+		results[r.CustomID] = r
+	}
+	return results
+}
+
+func TestBatchCompletes(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	input := strings.Join([]string{
+		batchLine("a", "hello"),
+		batchLine("b", "[scenario:tools] look"),
+		`{"custom_id":"c","method":"POST","url":"/v1/chat/completions","body":{"messages":[{"role":"system","content":"no user"}]}}`,
+	}, "\n")
+	f, status := uploadFile(t, srv.URL, "batch", "input.jsonl", input)
+	if status != http.StatusOK || f.Purpose != "batch" || f.Bytes != int64(len(input)) {
+		t.Fatalf("upload = %+v (%d)", f, status)
+	}
+
+	b := waitForBatch(t, srv.URL, createBatch(t, srv.URL, f.ID).ID)
+	if b.Status != batches.StatusCompleted {
+		t.Fatalf("batch = %+v", b)
+	}
+	if b.RequestCounts != (batches.RequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Errorf("counts = %+v", b.RequestCounts)
+	}
+
+	output := readResults(t, srv.URL, b.OutputFileID)
+	if len(output) != 2 || output["a"].Response.StatusCode != 200 {
+		t.Fatalf("output = %+v", output)
+	}
+	body := output["a"].Response.Body.(map[string]interface{})
+	content := body["choices"].([]interface{})[0].(map[string]interface{})["message"].(map[string]interface{})["content"]
+	if content != "You said: hello" {
+		t.Errorf("content = %v", content)
+	}
+
+	errs := readResults(t, srv.URL, b.ErrorFileID)
+	if errs["c"].Response == nil || errs["c"].Response.StatusCode != 400 {
+		t.Errorf("errors = %+v", errs)
+	}
+}
+
+func TestBatchFailureReleasesProject(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":      root,
+		"PROJECT_SNAPSHOTS": "true",
+		"WORKSPACE_CHANGES": "true",
+	})
+	var snap projects.ProjectSnapshot
+	os.MkdirAll(filepath.Join(root, "snap"), 0o755)
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots", "", &snap); status != http.StatusOK {
+		t.Fatalf("snapshot status = %d", status)
+	}
+
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"project_id":"snap","messages":[{"role":"user","content":"[scenario:crash] go"}]}}`
+	f, _ := uploadFile(t, srv.URL, "batch", "input.jsonl", input)
+	b := waitForBatch(t, srv.URL, createBatch(t, srv.URL, f.ID).ID)
+	if b.RequestCounts.Failed != 1 {
+		t.Fatalf("batch = %+v", b)
+	}
+
+	// The failed run's hooks finish, so the project is no longer in use
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots/"+snap.ID+"/restore", "", nil)
+		if status == http.StatusOK {
+			break
+		}
+		if status != http.StatusConflict || time.Now().After(deadline) {
+			t.Fatalf("restore after the batch status = %d", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	f, _ := uploadFile(t, srv.URL, "batch", "dup.jsonl", batchLine("a", "one")+"\n"+batchLine("a", "two"))
+	b := waitForBatch(t, srv.URL, createBatch(t, srv.URL, f.ID).ID)
+	if b.Status != batches.StatusFailed || b.Errors == nil || b.Errors.Data[0].Code != "duplicate_custom_id" || b.Errors.Data[0].Line != 2 {
+		t.Errorf("batch = %+v, errors = %+v", b, b.Errors)
+	}
+
+	resp, _ := http.Post(srv.URL+"/v1/batches", "application/json",
+		strings.NewReader(`{"input_file_id":"`+f.ID+`","endpoint":"/v1/embeddings","completion_window":"24h"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unsupported endpoint status = %d, want 400", resp.StatusCode)
+	}
+
+	if _, status := uploadFile(t, srv.URL, "assistants", "x.txt", "x"); status != http.StatusBadRequest {
+		t.Errorf("unsupported purpose status = %d, want 400", status)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"BATCH_CONCURRENCY": "1"})
+
+	f, _ := uploadFile(t, srv.URL, "batch", "slow.jsonl", batchLine("fast", "hello")+"\n"+batchLine("slow", "[scenario:slow] wait")+"\n"+batchLine("never", "hi"))
+	b := createBatch(t, srv.URL, f.ID)
+
+	// Cancel once the slow request is running
+	for {
+		resp, _ := http.Get(srv.URL + "/v1/batches/" + b.ID)
+		var cur batches.Batch
+		json.NewDecoder(resp.Body).Decode(&cur)
+		resp.Body.Close()
+		if cur.RequestCounts.Completed == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(srv.URL+"/v1/batches/"+b.ID+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cancelling batches.Batch
+	json.NewDecoder(resp.Body).Decode(&cancelling)
+	resp.Body.Close()
+	if cancelling.Status != batches.StatusCancelling {
+		t.Errorf("cancel returned status %s", cancelling.Status)
+	}
+
+	final := waitForBatch(t, srv.URL, b.ID)
+	if final.Status != batches.StatusCancelled || final.RequestCounts.Completed != 1 || final.OutputFileID == "" {
+		t.Errorf("final = %+v", final)
+	}
+}
+
+// TestBatchResume stops a batch manager midway and checks that a new one
+// finishes the batch without rerunning completed requests.
+func TestBatchResume(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BATCHES_DIR", dir+"/batches")
+	t.Setenv("CONFIG_FILE", dir+"/missing.yaml")
+	t.Setenv("BATCH_CONCURRENCY", "1")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := config.NewReloader(cfg)
+	store, err := files.NewStore(dir+"/files", func() string { return cfg.ProjectRoot })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	release := make(chan struct{})
+	exec := func(ctx context.Context, b batches.Batch, requestID string, body json.RawMessage) (int, interface{}, error) {
+		prompt := string(body)
+		mu.Lock()
+		calls[prompt]++
+		mu.Unlock()
+		if strings.Contains(prompt, "block") {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			}
+		}
+		return 200, map[string]string{"echo": prompt}, nil
+	}
+
+	input := `{"custom_id":"1","method":"POST","url":"/v1/chat/completions","body":{"p":"first"}}
+{"custom_id":"2","method":"POST","url":"/v1/chat/completions","body":{"p":"block"}}
+{"custom_id":"3","method":"POST","url":"/v1/chat/completions","body":{"p":"third"}}`
+	f, err := store.Create("", "input.jsonl", files.PurposeBatch, strings.NewReader(input), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := batches.NewManager(live, store, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := first.Create("", "", f.ID, "/v1/chat/completions", "24h", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		cur, _ := first.Get("", b.ID)
+		if cur.RequestCounts.Completed == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	first.Shutdown()
+	if cur, _ := first.Get("", b.ID); cur.Status != batches.StatusInProgress {
+		t.Fatalf("status after shutdown = %s, want in_progress", cur.Status)
+	}
+
+	close(release)
+	second, err := batches.NewManager(live, store, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Shutdown()
+
+	var final batches.Batch
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if final, _ = second.Get("", b.ID); final.Status.Terminal() {
+			break
+		}
+	}
+	if final.Status != batches.StatusCompleted || final.RequestCounts.Completed != 3 {
+		t.Fatalf("final = %+v", final)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls[`{"p":"first"}`] != 1 || calls[`{"p":"third"}`] != 1 {
+		t.Errorf("calls = %v, want completed requests run once", calls)
+	}
+}
@@ -2,37 +2,38 @@
 package api
 
 import (
-	"context"
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"claude-code-api/internal/claude"
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/cache"
 	"claude-code-api/internal/config"
 	"claude-code-api/internal/models"
 	"claude-code-api/internal/streaming"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 )
 
 // ChatHandler handles chat completion requests.
 type ChatHandler struct {
-	cfg     *config.Config
-	manager *claude.Manager
+	cfg       *config.Reloader
+	backends  *backend.Registry
+	responses *cache.Cache
 }
 
 // NewChatHandler creates a new chat handler.
-func NewChatHandler(cfg *config.Config, manager *claude.Manager) *ChatHandler {
-	return &ChatHandler{cfg: cfg, manager: manager}
+// responses may be nil, which disables response caching.
+func NewChatHandler(cfg *config.Reloader, backends *backend.Registry, responses *cache.Cache) *ChatHandler {
+	return &ChatHandler{cfg: cfg, backends: backends, responses: responses}
 }
 
 // HandleChatCompletion handles POST /v1/chat/completions
 func (h *ChatHandler) HandleChatCompletion(c *gin.Context) {
+	cfg := h.cfg.Get()
+
 	var req models.ChatCompletionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
@@ -47,20 +48,10 @@ func (h *ChatHandler) HandleChatCompletion(c *gin.Context) {
 	// Use model directly - Claude CLI will validate
 	claudeModel := req.Model
 	if claudeModel == "" {
-		claudeModel = h.cfg.DefaultModel
-	}
-
-	// Extract user prompt
-	var userPrompt string
-	var systemPrompt string
-	for _, msg := range req.Messages {
-		if msg.Role == "user" {
-			userPrompt = msg.GetTextContent()
-		} else if msg.Role == "system" {
-			systemPrompt = msg.GetTextContent()
-		}
+		claudeModel = cfg.DefaultModel
 	}
 
+	userPrompt, systemPrompt := chatPrompt(&req)
 	if userPrompt == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: models.ErrorDetail{
@@ -72,55 +63,105 @@ func (h *ChatHandler) HandleChatCompletion(c *gin.Context) {
 		return
 	}
 
-	if req.SystemPrompt != "" {
-		systemPrompt = req.SystemPrompt
-	}
-
-	// Setup project directory
 	projectID := req.ProjectID
 	if projectID == "" {
-		projectID = "default"
-	}
-	projectPath := filepath.Join(h.cfg.ProjectRoot, projectID)
-	if err := os.MkdirAll(projectPath, 0755); err != nil {
-		log.Error().Err(err).Msg("Failed to create project directory")
+		projectID = defaultProjectID
 	}
 
-	// Create Claude session
-	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(h.cfg.StreamingTimeoutSecs)*time.Second)
-	defer cancel()
+	// Serve read-only requests from the response cache when allowed. The
+	// caller must be allowed the model and the project either way
+	var key string
+	storeResult := false
+	if h.responses != nil && cfg.ResponseCacheEnabled {
+		if !req.ReadOnly {
+			c.Header("X-Cache", cacheBypass)
+		} else {
+			if serr := modelError(c, claudeModel); serr != nil {
+				c.JSON(serr.Status, serr.openAIError())
+				return
+			}
+			// Answers are only shared between callers asking about the same project
+			project, _, serr := resolveProject(cfg, namespace(c), projectID, false)
+			if serr != nil {
+				c.JSON(serr.Status, serr.openAIError())
+				return
+			}
+			lookup, store := cacheDirectives(c)
+			key, storeResult = cacheKey(&req, claudeModel, project), store
+			if lookup {
+				if entry, ok := h.responses.Get(key); ok {
+					if serr := chargeCacheHit(c, h.backends, claudeModel, userPrompt, project, entry); serr != nil {
+						c.JSON(serr.Status, serr.openAIError())
+						return
+					}
+					c.Header("X-Cache", cacheHit)
+					writeCachedResponse(c, entry, claudeModel, projectID, req.Stream)
+					return
+				}
+				c.Header("X-Cache", cacheMiss)
+			} else {
+				c.Header("X-Cache", cacheBypass)
+			}
+		}
+	}
 
-	proc, err := h.manager.CreateSession(ctx, projectPath, userPrompt, claudeModel, systemPrompt)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to create Claude session")
-		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
-			Error: models.ErrorDetail{
-				Message: fmt.Sprintf("Failed to start Claude: %v", err),
-				Type:    "service_unavailable",
-				Code:    "claude_unavailable",
-			},
-		})
+	run, cancel, serr := startSession(c, cfg, h.backends, backend.Request{
+		Model:        claudeModel,
+		Prompt:       userPrompt,
+		SystemPrompt: systemPrompt,
+		ProjectID:    projectID,
+		ReadOnly:     req.ReadOnly,
+	})
+	if serr != nil {
+		c.JSON(serr.Status, serr.openAIError())
 		return
 	}
+	defer cancel()
 
-	sessionID := proc.SessionID
+	sessionID := run.SessionID()
 	if sessionID == "" {
 		sessionID = uuid.New().String()
 	}
 
+	var entry *cache.Entry
 	if req.Stream {
-		h.handleStreamingResponse(c, proc, claudeModel, sessionID, projectID)
+		entry = h.handleStreamingResponse(c, run, claudeModel, sessionID, projectID)
 	} else {
-		h.handleNonStreamingResponse(c, proc, claudeModel, sessionID, projectID)
+		entry = h.handleNonStreamingResponse(c, run, claudeModel, sessionID, projectID)
+	}
+
+	if entry != nil && storeResult {
+		h.responses.Put(key, *entry)
 	}
 }
 
-func (h *ChatHandler) handleStreamingResponse(c *gin.Context, proc *claude.Process, model, sessionID, projectID string) {
+// chatPrompt extracts the prompt and system prompt of a chat request.
+func chatPrompt(req *models.ChatCompletionRequest) (prompt, system string) {
+	for _, msg := range req.Messages {
+		if msg.Role == "user" {
+			prompt = msg.GetTextContent()
+		} else if msg.Role == "system" {
+			system = msg.GetTextContent()
+		}
+	}
+	if req.SystemPrompt != "" {
+		system = req.SystemPrompt
+	}
+	return prompt, system
+}
+
+func setStreamingHeaders(c *gin.Context, sessionID, projectID string) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Session-ID", sessionID)
 	c.Header("X-Project-ID", projectID)
+}
+
+// handleStreamingResponse streams the run as SSE chunks. It returns the
+// completed response for caching, or nil if the run failed.
+func (h *ChatHandler) handleStreamingResponse(c *gin.Context, run backend.Run, model, sessionID, projectID string) *cache.Entry {
+	setStreamingHeaders(c, sessionID, projectID)
 
 	formatter := &streaming.SSEFormatter{}
 	converter := streaming.NewConverter(model, sessionID)
@@ -130,38 +171,63 @@ func (h *ChatHandler) handleStreamingResponse(c *gin.Context, proc *claude.Proce
 	c.Writer.Flush()
 
 	// Stream Claude output
-	for msg := range proc.Output {
-		if msg.Type == "assistant" && msg.Message != nil {
-			content := streaming.ExtractTextContent(msg.Message.Content)
-			if content != "" {
-				c.Writer.WriteString(formatter.FormatEvent(converter.CreateContentChunk(content)))
-				c.Writer.Flush()
-			}
+	var contentParts []string
+	var changes *backend.WorkspaceChanges
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
 		}
-		if msg.Type == "result" {
-			break
+		switch ev.Type {
+		case backend.EventText:
+			contentParts = append(contentParts, ev.Text)
+			c.Writer.WriteString(formatter.FormatEvent(converter.CreateContentChunk(ev.Text)))
+			c.Writer.Flush()
+		case backend.EventWorkspaceChanges:
+			changes = ev.Changes
+		case backend.EventError:
+			c.Writer.WriteString(formatter.FormatError(ev.Error, "api_error"))
+			c.Writer.WriteString(formatter.FormatDone())
+			c.Writer.Flush()
+			return nil
 		}
 	}
 
 	// Send final chunk and done
 	c.Writer.WriteString(formatter.FormatEvent(converter.CreateFinalChunk()))
+	if changes != nil {
+		c.Writer.WriteString(formatter.FormatExtensionEvent(string(backend.EventWorkspaceChanges), changes))
+	}
 	c.Writer.WriteString(formatter.FormatDone())
 	c.Writer.Flush()
+
+	return &cache.Entry{Content: strings.Join(contentParts, "\n"), Usage: run.Usage(), SessionID: sessionID}
 }
 
-func (h *ChatHandler) handleNonStreamingResponse(c *gin.Context, proc *claude.Process, model, sessionID, projectID string) {
+// handleNonStreamingResponse collects the run into a single response. It
+// returns the completed response for caching, or nil if the run failed.
+func (h *ChatHandler) handleNonStreamingResponse(c *gin.Context, run backend.Run, model, sessionID, projectID string) *cache.Entry {
 	var contentParts []string
+	var changes *backend.WorkspaceChanges
 
 	// Collect all output
-	for msg := range proc.Output {
-		if msg.Type == "assistant" && msg.Message != nil {
-			content := streaming.ExtractTextContent(msg.Message.Content)
-			if content != "" {
-				contentParts = append(contentParts, content)
-			}
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
 		}
-		if msg.Type == "result" {
-			break
+		switch ev.Type {
+		case backend.EventText:
+			contentParts = append(contentParts, ev.Text)
+		case backend.EventWorkspaceChanges:
+			changes = ev.Changes
+		case backend.EventError:
+			c.JSON(http.StatusBadGateway, models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Message: ev.Error,
+					Type:    "api_error",
+					Code:    "claude_error",
+				},
+			})
+			return nil
 		}
 	}
 
@@ -170,10 +236,19 @@ func (h *ChatHandler) handleNonStreamingResponse(c *gin.Context, proc *claude.Pr
 		completeContent = "Hello! I'm Claude, ready to help."
 	}
 
+	usage := run.Usage()
+	resp := completionResponse(model, completeContent, usage, sessionID, projectID)
+	resp.WorkspaceChanges = changes
+	c.JSON(http.StatusOK, resp)
+
+	return &cache.Entry{Content: completeContent, Usage: usage, SessionID: sessionID}
+}
+
+// completionResponse builds a non-streaming chat completion response.
+func completionResponse(model, content string, usage backend.Usage, sessionID, projectID string) models.ChatCompletionResponse {
 	completionID := fmt.Sprintf("chatcmpl-%s", uuid.New().String()[:29])
-	wordCount := len(strings.Fields(completeContent))
 
-	response := models.ChatCompletionResponse{
+	return models.ChatCompletionResponse{
 		ID:      completionID,
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
@@ -182,18 +257,16 @@ func (h *ChatHandler) handleNonStreamingResponse(c *gin.Context, proc *claude.Pr
 			Index: 0,
 			Message: models.ChatMessage{
 				Role:    "assistant",
-				Content: completeContent,
+				Content: content,
 			},
 			FinishReason: "stop",
 		}},
 		Usage: models.ChatCompletionUsage{
-			PromptTokens:     10,
-			CompletionTokens: wordCount,
-			TotalTokens:      10 + wordCount,
+			PromptTokens:     usage.PromptTokens(),
+			CompletionTokens: usage.OutputTokens,
+			TotalTokens:      usage.PromptTokens() + usage.OutputTokens,
 		},
 		SessionID: sessionID,
 		ProjectID: projectID,
 	}
-
-	c.JSON(http.StatusOK, response)
 }
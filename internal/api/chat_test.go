@@ -0,0 +1,313 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClaudePath is the fakeclaude binary built once for the package.
+var fakeClaudePath string
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	dir, err := os.MkdirTemp("", "fakeclaude")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeClaudePath = filepath.Join(dir, "claude")
+
+	build := exec.Command("go", "build", "-o", fakeClaudePath, "claude-code-api/cmd/fakeclaude")
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to build fakeclaude:", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestServer starts the gateway as in production, with the Claude CLI
+// backend running fakeclaude.
+func newTestServer(t *testing.T, env map[string]string) *httptest.Server {
+	t.Helper()
+
+	scenarios, err := filepath.Abs("testdata/scenarios")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CLAUDE_BINARY_PATH", fakeClaudePath)
+	t.Setenv("FAKECLAUDE_SCENARIO_DIR", scenarios)
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("TASKS_DIR", t.TempDir())
+	t.Setenv("FILES_DIR", t.TempDir())
+	t.Setenv("BATCHES_DIR", t.TempDir())
+	t.Setenv("KEYS_FILE", filepath.Join(t.TempDir(), "keys.json"))
+	t.Setenv("USAGE_DIR", t.TempDir())
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(config.NewReloader(cfg), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Shutdown)
+
+	srv := httptest.NewServer(server.Router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func postChat(t *testing.T, srv *httptest.Server, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func chatBody(prompt string, stream bool) string {
+	req := models.ChatCompletionRequest{
+		Model:    "claude-sonnet-4-5-20250929",
+		Messages: []models.ChatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+	}
+	data, _ := json.Marshal(req)
+	return string(data)
+}
+
+// readSSE returns the data payloads of an SSE response.
+func readSSE(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	return events
+}
+
+func TestChatCompletionNonStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postChat(t, srv, chatBody("hello gateway", false))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var out models.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Choices[0].Message.Content; got != "You said: hello gateway" {
+		t.Errorf("content = %q", got)
+	}
+	if out.SessionID == "" || out.ProjectID != "default" {
+		t.Errorf("session_id = %q, project_id = %q", out.SessionID, out.ProjectID)
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("missing X-Request-ID header")
+	}
+}
+
+func TestChatCompletionToolUseAndUsage(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postChat(t, srv, chatBody("[scenario:tools] what is here?", false))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var out models.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := "Let me look at the project.\nThe project has two files."
+	if got := out.Choices[0].Message.Content; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	if out.Usage.PromptTokens != 112 || out.Usage.CompletionTokens != 34 || out.Usage.TotalTokens != 146 {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+}
+
+func TestChatCompletionStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postChat(t, srv, chatBody("[scenario:tools] stream please", true))
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	events := readSSE(t, resp)
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5: %v", len(events), events)
+	}
+	if events[len(events)-1] != "[DONE]" {
+		t.Errorf("last event = %q, want [DONE]", events[len(events)-1])
+	}
+
+	var content []string
+	for _, data := range events[:len(events)-1] {
+		var chunk models.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", data, err)
+		}
+		if c := chunk.Choices[0].Delta.Content; c != "" {
+			content = append(content, c)
+		}
+	}
+	if len(content) != 2 || content[1] != "The project has two files." {
+		t.Errorf("content chunks = %q", content)
+	}
+
+	var final models.ChatCompletionChunk
+	_ = json.Unmarshal([]byte(events[len(events)-2]), &final)
+	if fr := final.Choices[0].FinishReason; fr == nil || *fr != "stop" {
+		t.Errorf("final finish_reason = %v", fr)
+	}
+}
+
+func TestChatCompletionTimeout(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"STREAMING_TIMEOUT_SECONDS": "1"})
+
+	resp := postChat(t, srv, chatBody("[scenario:slow] take your time", false))
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", resp.StatusCode)
+	}
+
+	var out models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Error.Message != "claude timed out" {
+		t.Errorf("error = %q", out.Error.Message)
+	}
+}
+
+func TestChatCompletionStreamingTimeout(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"STREAMING_TIMEOUT_SECONDS": "1"})
+
+	resp := postChat(t, srv, chatBody("[scenario:slow] take your time", true))
+	events := readSSE(t, resp)
+	if len(events) < 2 {
+		t.Fatalf("got %d events: %v", len(events), events)
+	}
+
+	var ticks int
+	for _, data := range events {
+		if strings.Contains(data, `"content":"tick"`) {
+			ticks++
+		}
+		if strings.Contains(data, "too late") {
+			t.Error("received output after the timeout")
+		}
+	}
+	if ticks != 3 {
+		t.Errorf("got %d ticks before timeout, want 3", ticks)
+	}
+	if !strings.Contains(events[len(events)-2], "claude timed out") || events[len(events)-1] != "[DONE]" {
+		t.Errorf("stream did not end with a timeout error: %v", events[len(events)-2:])
+	}
+}
+
+func TestChatCompletionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   string
+		contains string
+	}{
+		{"crash", "[scenario:crash] go", "simulated crash"},
+		{"max turns", "[scenario:max_turns] go", "Reached maximum number of turns"},
+	}
+
+	srv := newTestServer(t, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postChat(t, srv, chatBody(tt.prompt, false))
+			if resp.StatusCode != http.StatusBadGateway {
+				t.Fatalf("status = %d, want 502", resp.StatusCode)
+			}
+			var out models.ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.Error.Message, tt.contains) {
+				t.Errorf("error = %q, want it to contain %q", out.Error.Message, tt.contains)
+			}
+		})
+	}
+}
+
+func TestChatCompletionMissingUserMessage(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postChat(t, srv, `{"model":"m","messages":[{"role":"system","content":"be brief"}]}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestChatCompletionRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	body := chatBody("[scenario:tools] record me", false)
+
+	rec := newTestServer(t, map[string]string{"TRANSCRIPT_MODE": "record", "TRANSCRIPT_DIR": dir})
+	var recorded models.ChatCompletionResponse
+	if err := json.NewDecoder(postChat(t, rec, body).Body).Decode(&recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("got %d transcripts, want 1", len(files))
+	}
+
+	// Replay must not invoke the CLI at all
+	play := newTestServer(t, map[string]string{
+		"TRANSCRIPT_MODE":    "replay",
+		"TRANSCRIPT_DIR":     dir,
+		"CLAUDE_BINARY_PATH": filepath.Join(t.TempDir(), "does-not-exist"),
+	})
+	resp := postChat(t, play, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var replayed models.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&replayed); err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Choices[0].Message.Content != recorded.Choices[0].Message.Content {
+		t.Errorf("replayed content = %q, want %q", replayed.Choices[0].Message.Content, recorded.Choices[0].Message.Content)
+	}
+	if replayed.SessionID != recorded.SessionID || replayed.Usage != recorded.Usage {
+		t.Errorf("replayed session/usage differ: %+v vs %+v", replayed, recorded)
+	}
+}
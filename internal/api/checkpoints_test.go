@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/projects"
+)
+
+// chatInProject sends a non-streaming chat request for project with the
+// given request ID.
+func chatInProject(t *testing.T, url, project, requestID, prompt string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":      "claude-sonnet-4-5-20250929",
+		"project_id": project,
+		"messages":   []map[string]string{{"role": "user", "content": prompt}},
+	})
+	req, _ := http.NewRequest("POST", url+"/v1/chat/completions", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", requestID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("chat status = %d", resp.StatusCode)
+	}
+}
+
+func listCommits(t *testing.T, url, query string) []projects.Commit {
+	t.Helper()
+	var list struct {
+		Data []projects.Commit `json:"data"`
+	}
+	if status := projectRequest(t, "GET", url+"/v1/projects/cp/commits"+query, "", &list); status != http.StatusOK {
+		t.Fatalf("commits status = %d", status)
+	}
+	return list.Data
+}
+
+func TestGitCheckpoints(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "GIT_CHECKPOINTS": "true"})
+
+	chatInProject(t, srv.URL, "cp", "req-1", "[scenario:edit] first change")
+	chatInProject(t, srv.URL, "cp", "req-2", "[scenario:edit] second change")
+	// Runs that change nothing leave no commit
+	chatInProject(t, srv.URL, "cp", "req-3", "hello")
+
+	commits := listCommits(t, srv.URL, "")
+	if len(commits) != 2 {
+		t.Fatalf("commits = %+v", commits)
+	}
+	second, first := commits[0], commits[1]
+	if first.RequestID != "req-1" || first.Model != "claude-sonnet-4-5-20250929" || first.SessionID == "" || first.Message != "[scenario:edit] first change" {
+		t.Errorf("first commit = %+v", first)
+	}
+	if len(first.Files) != 2 || first.Files[0] != (projects.ChangedFile{Path: "docs/log.txt", Status: "added"}) {
+		t.Errorf("first commit files = %+v", first.Files)
+	}
+	if second.RequestID != "req-2" || len(second.Files) != 1 || second.Files[0] != (projects.ChangedFile{Path: "notes.md", Status: "modified"}) {
+		t.Errorf("second commit = %+v", second)
+	}
+
+	if only := listCommits(t, srv.URL, "?request_id=req-1"); len(only) != 1 || only[0].SHA != first.SHA {
+		t.Errorf("filtered commits = %+v", only)
+	}
+	if none := listCommits(t, srv.URL, "?request_id=req-3"); len(none) != 0 {
+		t.Errorf("commits for unchanged run = %+v", none)
+	}
+}
+
+func TestGitCheckpointsOff(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	chatInProject(t, srv.URL, "cp", "req-1", "[scenario:edit] change")
+	if _, err := os.Stat(filepath.Join(root, "cp", "notes.md")); err != nil {
+		t.Fatalf("scenario did not edit the project: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cp", ".git")); !os.IsNotExist(err) {
+		t.Errorf("repository created with checkpoints off: %v", err)
+	}
+	if commits := listCommits(t, srv.URL, ""); len(commits) != 0 {
+		t.Errorf("commits = %+v", commits)
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/streaming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionsHandler handles legacy text completion requests.
+type CompletionsHandler struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+}
+
+// NewCompletionsHandler creates a new completions handler.
+func NewCompletionsHandler(cfg *config.Reloader, backends *backend.Registry) *CompletionsHandler {
+	return &CompletionsHandler{cfg: cfg, backends: backends}
+}
+
+// completionSystemPrompt asks Claude to behave like a text completion model.
+// The CLI has no fill-in-the-middle mode, so a suffix is passed as an
+// instruction.
+func completionSystemPrompt(suffix string) string {
+	prompt := "Continue the text given by the user. Output only the continuation, without repeating the text or adding commentary."
+	if suffix != "" {
+		prompt += " The continuation will be followed by this text, so it must lead into it:\n\n" + suffix
+	}
+	return prompt
+}
+
+// HandleCompletion handles POST /v1/completions
+func (h *CompletionsHandler) HandleCompletion(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	var req models.CompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	for _, p := range req.Prompt {
+		if p == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Message: "Prompts must not be empty",
+					Type:    "invalid_request_error",
+					Code:    "missing_prompt",
+				},
+			})
+			return
+		}
+	}
+	if len(req.Prompt) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "At least one prompt is required",
+				Type:    "invalid_request_error",
+				Code:    "missing_prompt",
+			},
+		})
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	projectID := req.ProjectID
+	if projectID == "" {
+		projectID = defaultProjectID
+	}
+
+	if req.Stream {
+		h.handleStreamingCompletion(c, cfg, &req, model, projectID)
+	} else {
+		h.handleNonStreamingCompletion(c, cfg, &req, model, projectID)
+	}
+}
+
+// sessionRequest builds the run for one prompt. Completions only generate
+// text, so they run read-only.
+func (h *CompletionsHandler) sessionRequest(req *models.CompletionRequest, model, projectID, prompt string) backend.Request {
+	return backend.Request{
+		Model:        model,
+		Prompt:       prompt,
+		SystemPrompt: completionSystemPrompt(req.Suffix),
+		ProjectID:    projectID,
+		ReadOnly:     true,
+	}
+}
+
+// handleNonStreamingCompletion runs each prompt in turn and returns one choice
+// per prompt.
+func (h *CompletionsHandler) handleNonStreamingCompletion(c *gin.Context, cfg *config.Config, req *models.CompletionRequest, model, projectID string) {
+	resp := models.CompletionResponse{
+		ID:        streaming.NewCompletionID(),
+		Object:    "text_completion",
+		Created:   time.Now().Unix(),
+		Model:     model,
+		Choices:   []models.CompletionChoice{},
+		Usage:     &models.ChatCompletionUsage{},
+		ProjectID: projectID,
+	}
+
+	for i, prompt := range req.Prompt {
+		text, usage, serr := h.complete(c, cfg, h.sessionRequest(req, model, projectID, prompt))
+		if serr != nil {
+			c.JSON(serr.Status, serr.openAIError())
+			return
+		}
+
+		finishReason := "stop"
+		text, _ = streaming.TruncateAtStop(text, req.Stop)
+		if req.Echo {
+			text = prompt + text
+		}
+		resp.Choices = append(resp.Choices, models.CompletionChoice{Text: text, Index: i, FinishReason: &finishReason})
+
+		resp.Usage.PromptTokens += usage.PromptTokens()
+		resp.Usage.CompletionTokens += usage.OutputTokens
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// complete runs a single prompt to completion.
+func (h *CompletionsHandler) complete(c *gin.Context, cfg *config.Config, breq backend.Request) (string, backend.Usage, *sessionError) {
+	run, cancel, serr := startSession(c, cfg, h.backends, breq)
+	if serr != nil {
+		return "", backend.Usage{}, serr
+	}
+	defer cancel()
+
+	var parts []string
+	for ev := range run.Events() {
+		switch ev.Type {
+		case backend.EventText:
+			parts = append(parts, ev.Text)
+		case backend.EventError:
+			return "", backend.Usage{}, &sessionError{
+				Status:  http.StatusBadGateway,
+				Type:    "api_error",
+				Code:    "claude_error",
+				Message: ev.Error,
+			}
+		}
+	}
+	return strings.Join(parts, "\n"), run.Usage(), nil
+}
+
+// handleStreamingCompletion streams each prompt's choice in turn.
+func (h *CompletionsHandler) handleStreamingCompletion(c *gin.Context, cfg *config.Config, req *models.CompletionRequest, model, projectID string) {
+	formatter := &streaming.SSEFormatter{}
+	converter := streaming.NewCompletionConverter(model)
+	started := false
+
+	for i, prompt := range req.Prompt {
+		run, cancel, serr := startSession(c, cfg, h.backends, h.sessionRequest(req, model, projectID, prompt))
+		if serr != nil {
+			if !started {
+				c.JSON(serr.Status, serr.openAIError())
+				return
+			}
+			c.Writer.WriteString(formatter.FormatError(serr.Message, serr.Type))
+			c.Writer.WriteString(formatter.FormatDone())
+			c.Writer.Flush()
+			return
+		}
+
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Project-ID", projectID)
+		}
+		if req.Echo {
+			c.Writer.WriteString(formatter.FormatEvent(converter.CreateTextChunk(i, prompt)))
+			c.Writer.Flush()
+		}
+
+		ok := streamCompletionChoice(c, run, formatter, converter, i, req.Stop)
+		cancel()
+		if !ok {
+			return
+		}
+	}
+
+	c.Writer.WriteString(formatter.FormatDone())
+	c.Writer.Flush()
+}
+
+// streamCompletionChoice streams one run as the choice at index, ending it at
+// the first stop sequence. It reports whether the stream may continue.
+func streamCompletionChoice(c *gin.Context, run backend.Run, formatter *streaming.SSEFormatter, converter *streaming.CompletionConverter, index int, stop []string) bool {
+	var full strings.Builder
+	sent := 0
+
+	send := func(upTo int) {
+		text := full.String()
+		if upTo > sent {
+			c.Writer.WriteString(formatter.FormatEvent(converter.CreateTextChunk(index, text[sent:upTo])))
+			c.Writer.Flush()
+			sent = upTo
+		}
+	}
+
+	for ev := range run.Events() {
+		switch ev.Type {
+		case backend.EventText:
+			if full.Len() > 0 {
+				full.WriteString("\n")
+			}
+			full.WriteString(ev.Text)
+
+			text, stopped := streaming.TruncateAtStop(full.String(), stop)
+			if stopped {
+				// The rest of the run is discarded; the caller cancels it
+				send(len(text))
+				c.Writer.WriteString(formatter.FormatEvent(converter.CreateFinalChunk(index, "stop")))
+				c.Writer.Flush()
+				return true
+			}
+			send(len(text) - streaming.PartialStop(text, stop))
+		case backend.EventError:
+			c.Writer.WriteString(formatter.FormatError(ev.Error, "api_error"))
+			c.Writer.WriteString(formatter.FormatDone())
+			c.Writer.Flush()
+			return false
+		}
+	}
+
+	send(full.Len())
+	c.Writer.WriteString(formatter.FormatEvent(converter.CreateFinalChunk(index, "stop")))
+	c.Writer.Flush()
+	return true
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func postCompletions(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestCompletionsPromptList(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postCompletions(t, srv.URL, `{"model":"claude-sonnet-4-5-20250929","prompt":["one","two"],"echo":true}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got models.CompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Object != "text_completion" || len(got.Choices) != 2 {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+	for i, want := range []string{"oneYou said: one", "twoYou said: two"} {
+		if got.Choices[i].Text != want || got.Choices[i].Index != i {
+			t.Errorf("choice %d = %+v, want text %q", i, got.Choices[i], want)
+		}
+	}
+	if got.Usage == nil || got.Usage.TotalTokens == 0 {
+		t.Errorf("usage = %+v", got.Usage)
+	}
+}
+
+func TestCompletionsStop(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postCompletions(t, srv.URL, `{"prompt":"[scenario:tools] look","stop":"two"}`)
+	var got models.CompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if text := got.Choices[0].Text; text != "Let me look at the project.\nThe project has " {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestCompletionsStreamingStop(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	// The stop sequence spans the boundary between the two text blocks
+	resp := postCompletions(t, srv.URL, `{"prompt":"[scenario:tools] look","stream":true,"stop":["project.\nThe"]}`)
+
+	var text strings.Builder
+	var finish []string
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk models.CompletionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", data, err)
+		}
+		text.WriteString(chunk.Choices[0].Text)
+		if fr := chunk.Choices[0].FinishReason; fr != nil {
+			finish = append(finish, *fr)
+		}
+	}
+
+	if text.String() != "Let me look at the " {
+		t.Errorf("streamed text = %q", text.String())
+	}
+	if len(finish) != 1 || finish[0] != "stop" || !done {
+		t.Errorf("finish = %v, done = %v", finish, done)
+	}
+}
+
+func TestCompletionsReadOnly(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "PROJECT_QUOTA_BYTES": "100"})
+	writeProject(t, root, "big", 200)
+
+	// Completions run read-only, so a project over its quota still answers
+	resp := postCompletions(t, srv.URL, `{"prompt":"hello","project_id":"big"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/files"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// FilesHandler handles file uploads and downloads.
+type FilesHandler struct {
+	cfg   *config.Reloader
+	files *files.Store
+	quota *projects.QuotaGuard
+}
+
+// NewFilesHandler creates a new files handler. Uploads into projects are
+// refused by quota like runs are.
+func NewFilesHandler(cfg *config.Reloader, store *files.Store, quota *projects.QuotaGuard) *FilesHandler {
+	return &FilesHandler{cfg: cfg, files: store, quota: quota}
+}
+
+func fileNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("No such File object: %s", id),
+			Type:    "invalid_request_error",
+			Code:    "file_not_found",
+		},
+	})
+}
+
+// HandleUploadFile handles POST /v1/files
+//
+// With a project_id field the file is written into the project directory at
+// path (default: the uploaded file's name); otherwise it is stored for use
+// as batch input.
+func (h *FilesHandler) HandleUploadFile(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	// Leave room for the multipart framing and other fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.FileMaxBytes+1<<20)
+
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			fileTooLarge(c, cfg.FileMaxBytes)
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid multipart form: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	projectID := c.PostForm("project_id")
+	purpose := c.PostForm("purpose")
+	if projectID != "" && purpose == "" {
+		purpose = files.PurposeProject
+	}
+	if purpose != files.PurposeBatch && purpose != files.PurposeProject {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid purpose '%s'. Supported: %s, %s.", purpose, files.PurposeBatch, files.PurposeProject),
+				Type:    "invalid_request_error",
+				Code:    "invalid_purpose",
+			},
+		})
+		return
+	}
+	if purpose == files.PurposeProject && projectID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "project_id is required for project files",
+				Type:    "invalid_request_error",
+				Code:    "missing_project_id",
+			},
+		})
+		return
+	}
+	if purpose == files.PurposeProject {
+		if _, _, serr := resolveProject(cfg, namespace(c), projectID, true); serr != nil {
+			c.JSON(serr.Status, serr.openAIError())
+			return
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "A multipart 'file' field is required",
+				Type:    "invalid_request_error",
+				Code:    "missing_file",
+			},
+		})
+		return
+	}
+	if header.Size > cfg.FileMaxBytes {
+		fileTooLarge(c, cfg.FileMaxBytes)
+		return
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to read upload: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	defer src.Close()
+
+	var f files.File
+	if purpose == files.PurposeProject {
+		path := c.PostForm("path")
+		if path == "" {
+			path = header.Filename
+		}
+		// Set the project up as a run would on first use, and keep it
+		// within its quota
+		req := backend.Request{ProjectID: projectID, Namespace: namespace(c), Principal: principal(c)}
+		if serr := prepareRequest(cfg, &req); serr != nil {
+			c.JSON(serr.Status, serr.openAIError())
+			return
+		}
+		if serr := quotaError(h.quota.Admit(req)); serr != nil {
+			c.JSON(serr.Status, serr.openAIError())
+			return
+		}
+		f, err = h.files.CreateInProject(req.Namespace, req.ProjectID, path, src, cfg.FileMaxBytes)
+	} else {
+		f, err = h.files.Create(namespace(c), header.Filename, purpose, src, cfg.FileMaxBytes)
+	}
+	switch {
+	case errors.Is(err, files.ErrTooLarge):
+		fileTooLarge(c, cfg.FileMaxBytes)
+		return
+	case errors.Is(err, files.ErrBadPath):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "project_id and path must name a file inside the project directory",
+				Type:    "invalid_request_error",
+				Code:    "invalid_path",
+			},
+		})
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to store upload")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Failed to store file",
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	log.Info().Str("file_id", f.ID).Str("purpose", f.Purpose).Str("project_id", f.ProjectID).Str("path", f.Path).Int64("bytes", f.Bytes).Msg("File uploaded")
+	c.JSON(http.StatusOK, f)
+}
+
+func fileTooLarge(c *gin.Context, limit int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", limit),
+			Type:    "invalid_request_error",
+			Code:    "file_too_large",
+		},
+	})
+}
+
+// fileAccess reports whether the caller may use file f with access need.
+// Files belong to their namespace; project files are also reachable through
+// a grant on the project they are in.
+func (h *FilesHandler) fileAccess(c *gin.Context, f files.File, need projects.Access) bool {
+	ns := namespace(c)
+	if f.Namespace == ns {
+		return true
+	}
+	if f.Purpose != files.PurposeProject {
+		return false
+	}
+	return projects.AccessTo(h.cfg.Get(), ns, projects.Ref{Namespace: f.Namespace, ID: f.ProjectID}) >= need
+}
+
+// HandleGetFile handles GET /v1/files/:file_id
+func (h *FilesHandler) HandleGetFile(c *gin.Context) {
+	id := c.Param("file_id")
+	f, err := h.files.Get(id)
+	if err != nil || !h.fileAccess(c, f, projects.AccessRead) {
+		fileNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// HandleListFiles handles GET /v1/files?purpose=&project_id=
+//
+// It lists the caller's files, or the files of one project, which may be a
+// project shared with the caller.
+func (h *FilesHandler) HandleListFiles(c *gin.Context) {
+	ns, projectID := namespace(c), c.Query("project_id")
+	if projectID != "" {
+		ref, serr := projectAccess(h.cfg.Get(), ns, projectID, projects.AccessRead)
+		if serr != nil {
+			c.JSON(serr.Status, serr.openAIError())
+			return
+		}
+		ns, projectID = ref.Namespace, ref.ID
+	}
+	list, err := h.files.List(ns, c.Query("purpose"), projectID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list files")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Failed to list files",
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+}
+
+// HandleDeleteFile handles DELETE /v1/files/:file_id
+func (h *FilesHandler) HandleDeleteFile(c *gin.Context) {
+	id := c.Param("file_id")
+	f, err := h.files.Get(id)
+	if err == nil && !h.fileAccess(c, f, projects.AccessWrite) {
+		err = files.ErrNotFound
+	}
+	if err == nil {
+		err = h.files.Delete(id)
+	}
+	if errors.Is(err, files.ErrNotFound) {
+		fileNotFound(c, id)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("file_id", id).Msg("Failed to delete file")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Failed to delete file",
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// HandleFileContent handles GET /v1/files/:file_id/content
+func (h *FilesHandler) HandleFileContent(c *gin.Context) {
+	id := c.Param("file_id")
+	f, r, err := h.files.Open(id)
+	if err != nil {
+		fileNotFound(c, id)
+		return
+	}
+	defer r.Close()
+	if !h.fileAccess(c, f, projects.AccessRead) {
+		fileNotFound(c, id)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.Filename))
+	c.DataFromReader(http.StatusOK, f.Bytes, "application/octet-stream", r, nil)
+}
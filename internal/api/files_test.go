@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-code-api/internal/files"
+)
+
+func uploadProjectFile(t *testing.T, url string, fields map[string]string, content string) (files.File, int) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	part, _ := w.CreateFormFile("file", "upload.txt")
+	part.Write([]byte(content))
+	w.Close()
+
+	resp, err := http.Post(url+"/v1/files", w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var f files.File
+	json.NewDecoder(resp.Body).Decode(&f)
+	return f, resp.StatusCode
+}
+
+func listFiles(t *testing.T, url, query string) []files.File {
+	t.Helper()
+	resp, err := http.Get(url + "/v1/files?" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Data []files.File `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	return list.Data
+}
+
+func TestProjectFileLifecycle(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	f, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "demo", "path": "src/data.csv"}, "a,b\n")
+	if status != http.StatusOK || f.Purpose != files.PurposeProject || f.ProjectID != "demo" || f.Path != "src/data.csv" || f.Bytes != 4 {
+		t.Fatalf("upload = %+v (%d)", f, status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "demo", "src", "data.csv")); string(data) != "a,b\n" {
+		t.Errorf("project file = %q", data)
+	}
+
+	// Uploading to the same path replaces the file
+	f2, _ := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "demo", "path": "src/data.csv"}, "a,b\n1,2\n")
+	uploadProjectFile(t, srv.URL, map[string]string{"project_id": "other"}, "x")
+	list := listFiles(t, srv.URL, "project_id=demo")
+	if len(list) != 1 || list[0].ID != f2.ID || list[0].Bytes != 8 {
+		t.Errorf("list = %+v", list)
+	}
+	if all := listFiles(t, srv.URL, "purpose=project"); len(all) != 2 {
+		t.Errorf("all project files = %+v", all)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/files/" + f2.ID + "/content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "a,b\n1,2\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/v1/files/"+f2.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("delete status = %d", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(root, "demo", "src", "data.csv")); !os.IsNotExist(err) {
+		t.Errorf("file still in project after delete: %v", err)
+	}
+	resp, _ = http.Get(srv.URL + "/v1/files/" + f2.ID)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestProjectFileValidation(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "FILE_MAX_BYTES": "16"})
+
+	os.MkdirAll(filepath.Join(root, "demo"), 0755)
+	os.Symlink(outside, filepath.Join(root, "demo", "escape"))
+
+	for _, fields := range []map[string]string{
+		{"project_id": "demo", "path": "../evil.txt"},
+		{"project_id": "demo", "path": "/etc/evil.txt"},
+		{"project_id": "demo", "path": "a/../../evil.txt"},
+		{"project_id": "demo", "path": "escape/evil.txt"},
+		{"project_id": "..", "path": "evil.txt"},
+		{"project_id": "demo/../x", "path": "evil.txt"},
+		{"purpose": "project", "path": "evil.txt"},
+	} {
+		if _, status := uploadProjectFile(t, srv.URL, fields, "x"); status != http.StatusBadRequest {
+			t.Errorf("%v: status = %d, want 400", fields, status)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("upload escaped the project: %v", entries)
+	}
+
+	if _, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "demo", "path": "big.txt"}, "0123456789abcdefg"); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload status = %d, want 413", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "demo", "big.txt")); !os.IsNotExist(err) {
+		t.Error("oversized upload was written")
+	}
+}
+
+func TestProjectFileQuota(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "PROJECT_QUOTA_BYTES": "100"})
+	writeProject(t, root, "big", 200)
+
+	if _, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "big", "path": "more.txt"}, "x"); status != http.StatusInsufficientStorage {
+		t.Errorf("upload over quota status = %d, want 507", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "big", "more.txt")); !os.IsNotExist(err) {
+		t.Error("upload over quota was written")
+	}
+	if _, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "small", "path": "a.txt"}, "x"); status != http.StatusOK {
+		t.Errorf("upload under quota status = %d", status)
+	}
+}
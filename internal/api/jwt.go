@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/jwtauth"
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/projects"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// looksLikeJWT tells JWTs from API keys.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// claim returns the claim at a dotted path such as realm_access.roles.
+func claim(claims jwt.MapClaims, path string) (interface{}, bool) {
+	var v interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// claimStrings reads a claim holding a space-separated string or a list of
+// strings.
+func claimStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// jwtCaller maps the claims of a verified token to the caller: its
+// principal, its key with the scopes granted, and its project namespace.
+func jwtCaller(cfg *config.Config, claims jwt.MapClaims) (string, keys.Key, string, error) {
+	v, _ := claim(claims, cfg.JWTPrincipalClaim)
+	p, _ := v.(string)
+	if p == "" {
+		return "", keys.Key{}, "", fmt.Errorf("token has no %s claim", cfg.JWTPrincipalClaim)
+	}
+
+	scopes := cfg.JWTDefaultScopes
+	if v, ok := claim(claims, cfg.JWTScopesClaim); ok {
+		scopes = nil
+		for _, s := range claimStrings(v) {
+			if s, ok := strings.CutPrefix(s, cfg.JWTScopePrefix); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+
+	// A token must not pass for an API key
+	if strings.HasPrefix(p, keys.PrincipalPrefix) {
+		return "", keys.Key{}, "", fmt.Errorf("token principal %q is reserved for API keys", p)
+	}
+
+	// A key group is configured by the operator and kept as is; namespaces
+	// taken from the token are prefixed so they cannot name an API key's
+	// namespace or a key group
+	ns := cfg.KeyNamespace(p, p)
+	fromToken := ns == p
+	if cfg.JWTNamespaceClaim != "" {
+		if v, ok := claim(claims, cfg.JWTNamespaceClaim); ok {
+			// The first entry of a list, such as a groups claim
+			if names := claimStrings(v); len(names) > 0 {
+				ns, fromToken = names[0], true
+				if !projects.ValidID(ns) {
+					return "", keys.Key{}, "", fmt.Errorf("token names invalid namespace %q", ns)
+				}
+			}
+		}
+	}
+	if fromToken {
+		ns = config.JWTNamespacePrefix + ns
+		if !projects.ValidID(ns) {
+			// Principals such as e-mail addresses cannot name a directory
+			sum := sha256.Sum256([]byte(ns))
+			ns = config.JWTNamespacePrefix + hex.EncodeToString(sum[:6])
+		}
+	}
+
+	return p, keys.Key{ID: p, Name: p, Scopes: scopes}, ns, nil
+}
+
+// jwtOptions configures JWT verification.
+func jwtOptions(cfg *config.Config) jwtauth.Options {
+	return jwtauth.Options{
+		JWKSFile: cfg.JWKSFile,
+		JWKSURL:  cfg.JWKSURL,
+		CacheTTL: time.Duration(cfg.JWKSCacheSecs) * time.Second,
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   time.Duration(cfg.JWTLeewaySecs) * time.Second,
+	}
+}
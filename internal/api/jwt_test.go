@@ -0,0 +1,276 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "claude-gateway"
+)
+
+// writeJWKS publishes the public halves of keys by key ID in path.
+func writeJWKS(t *testing.T, path string, keys map[string]crypto.Signer) {
+	t.Helper()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	var set []map[string]string
+	for kid, k := range keys {
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": set})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Duration(len(keys)) * time.Minute)
+	os.Chtimes(path, future, future)
+}
+
+// signToken issues a token for sub signed with key, valid for an hour.
+// extra claims are added or override the defaults; nil ones are removed.
+func signToken(t *testing.T, kid string, key crypto.Signer, sub string, extra jwt.MapClaims) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": sub,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	var method jwt.SigningMethod = jwt.SigningMethodRS256
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newJWTServer starts a server in the given auth mode trusting the keys of
+// the JWKS file it returns.
+func newJWTServer(t *testing.T, mode string, env map[string]string) (url, jwksFile string) {
+	t.Helper()
+	jwksFile = filepath.Join(t.TempDir(), "jwks.json")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"unused": rsaKey})
+	vars := map[string]string{
+		"REQUIRE_AUTH":        "true",
+		"AUTH_MODE":           mode,
+		"API_KEYS":            "static-key",
+		"JWT_JWKS_FILE":       jwksFile,
+		"JWT_ISSUER":          testIssuer,
+		"JWT_AUDIENCE":        "other-app," + testAudience,
+		"JWT_NAMESPACE_CLAIM": "groups",
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+	return newTestServer(t, vars).URL, jwksFile
+}
+
+func TestJWTAuth(t *testing.T) {
+	root := t.TempDir()
+	url, jwksFile := newJWTServer(t, "jwt", map[string]string{"PROJECT_ROOT": root})
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"rsa-1": rsaKey, "ec-1": ecKey})
+
+	// The namespace comes from the first group
+	token := signToken(t, "rsa-1", rsaKey, "alice@example.com", jwt.MapClaims{"groups": []string{"research", "all"}})
+	if status := keyRequest(t, token, "POST", url+"/v1/chat/completions", projectChatBody("notes", "hello", false), nil); status != http.StatusOK {
+		t.Fatalf("RS256 chat status = %d", status)
+	}
+	var p projects.Project
+	if status := keyRequest(t, token, "GET", url+"/v1/projects/notes", "", &p); status != http.StatusOK || p.Namespace != "jwt_research" || p.Owner != "alice@example.com" {
+		t.Errorf("project = %+v (%d)", p, status)
+	}
+	if _, err := os.Stat(filepath.Join(projects.NamespaceRoot(root, "jwt_research"), "notes")); err != nil {
+		t.Errorf("project not in the jwt_research namespace: %v", err)
+	}
+
+	// Without a namespace claim, callers get a namespace of their own
+	token = signToken(t, "ec-1", ecKey, "bob@example.com", nil)
+	var list struct {
+		Data []projects.Project `json:"data"`
+	}
+	if status := keyRequest(t, token, "GET", url+"/v1/projects", "", &list); status != http.StatusOK || len(list.Data) != 0 {
+		t.Errorf("ES256 projects = %+v (%d)", list.Data, status)
+	}
+
+	rogue, _ := rsa.GenerateKey(rand.Reader, 2048)
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": testIssuer, "aud": testAudience, "sub": "mallory", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	hmacToken, _ := hmac.SignedString([]byte("secret"))
+	rejected := map[string]string{
+		"wrong issuer":     signToken(t, "rsa-1", rsaKey, "alice", jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"wrong audience":   signToken(t, "rsa-1", rsaKey, "alice", jwt.MapClaims{"aud": "someone-else"}),
+		"expired":          signToken(t, "rsa-1", rsaKey, "alice", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":        signToken(t, "rsa-1", rsaKey, "alice", jwt.MapClaims{"exp": nil}),
+		"unknown key":      signToken(t, "rsa-1", rogue, "alice", nil),
+		"HS256":            hmacToken,
+		"no subject":       signToken(t, "rsa-1", rsaKey, "", nil),
+		"invalid group":    signToken(t, "rsa-1", rsaKey, "alice", jwt.MapClaims{"groups": "../etc"}),
+		"key principal":    signToken(t, "rsa-1", rsaKey, keyPrincipal("static-key"), nil),
+		"static key (jwt)": "static-key",
+	}
+	for name, token := range rejected {
+		var out models.ErrorResponse
+		if status := keyRequest(t, token, "GET", url+"/v1/projects", "", &out); status != http.StatusUnauthorized || out.Error.Type != "authentication_error" {
+			t.Errorf("%s: %+v (%d)", name, out.Error, status)
+		}
+	}
+
+	// Rotated keys are picked up from the file
+	rotated, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"rsa-1": rsaKey, "ec-1": ecKey, "ec-2": rotated})
+	if status := keyRequest(t, signToken(t, "ec-2", rotated, "alice", nil), "GET", url+"/v1/projects", "", nil); status != http.StatusOK {
+		t.Errorf("rotated key status = %d", status)
+	}
+}
+
+func TestJWTNamespaceCollisions(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := fmt.Sprintf(`key_groups:
+  team: [%s, erin]
+`, keyPrincipal("static-key"))
+	if err := os.WriteFile(configFile, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	url, jwksFile := newJWTServer(t, "any", map[string]string{"API_KEYS": "static-key,solo", "CONFIG_FILE": configFile})
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"k": key})
+
+	for _, k := range []string{"static-key", "solo"} {
+		if status := keyRequest(t, k, "POST", url+"/v1/projects", `{"id":"app"}`, nil); status != http.StatusOK {
+			t.Fatalf("%s create status = %d", k, status)
+		}
+	}
+
+	// Namespaces from tokens cannot name a key group or an API key's
+	// namespace
+	for _, ns := range []string{"team", keyPrincipal("solo")} {
+		token := signToken(t, "k", key, "mallory", jwt.MapClaims{"groups": ns})
+		if status := keyRequest(t, token, "GET", url+"/v1/projects/app", "", nil); status != http.StatusNotFound {
+			t.Errorf("groups claim %s: status = %d, want 404", ns, status)
+		}
+	}
+	token := signToken(t, "k", key, "mallory", nil)
+	if status := keyRequest(t, token, "GET", url+"/v1/projects/app", "", nil); status != http.StatusNotFound {
+		t.Errorf("principal namespace: status = %d, want 404", status)
+	}
+
+	// Principals the operator put in a key group keep the group
+	var p projects.Project
+	token = signToken(t, "k", key, "erin", nil)
+	if status := keyRequest(t, token, "GET", url+"/v1/projects/app", "", &p); status != http.StatusOK || p.Namespace != "team" {
+		t.Errorf("key group member: %+v (%d)", p, status)
+	}
+}
+
+func TestJWTScopes(t *testing.T) {
+	url, jwksFile := newJWTServer(t, "any", map[string]string{
+		"JWT_SCOPES_CLAIM": "realm_access.roles",
+		"JWT_SCOPE_PREFIX": "claude:",
+	})
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"k": key})
+
+	token := signToken(t, "k", key, "carol", jwt.MapClaims{
+		"realm_access": map[string]interface{}{"roles": []string{"claude:chat", "models", "offline_access"}},
+	})
+	if status := keyRequest(t, token, "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusOK {
+		t.Errorf("chat status = %d", status)
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, token, "GET", url+"/v1/models", "", &out); status != http.StatusForbidden || out.Error.Code != "insufficient_scope" {
+		t.Errorf("models = %+v (%d)", out.Error, status)
+	}
+
+	// Tokens without the claim get the default scopes
+	token = signToken(t, "k", key, "carol", nil)
+	if status := keyRequest(t, token, "GET", url+"/v1/models", "", nil); status != http.StatusOK {
+		t.Errorf("default scopes models status = %d", status)
+	}
+	if status := keyRequest(t, token, "POST", url+"/v1/storage/gc", "", &out); status != http.StatusForbidden {
+		t.Errorf("default scopes gc status = %d", status)
+	}
+
+	// In mode any, API keys still work
+	if status := keyRequest(t, "static-key", "POST", url+"/v1/storage/gc", "", nil); status != http.StatusOK {
+		t.Errorf("static key status = %d", status)
+	}
+}
+
+func TestJWKSURL(t *testing.T) {
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	first, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"first": first})
+	var fetches atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.ServeFile(w, r, jwksFile)
+	}))
+	t.Cleanup(idp.Close)
+
+	url, _ := newJWTServer(t, "jwt", map[string]string{"JWT_JWKS_FILE": "", "JWT_JWKS_URL": idp.URL})
+	for i := 0; i < 3; i++ {
+		if status := keyRequest(t, signToken(t, "first", first, "alice", nil), "GET", url+"/v1/projects", "", nil); status != http.StatusOK {
+			t.Fatalf("status = %d", status)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+
+	// A key the cached set does not know is not fetched again right away
+	second, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]crypto.Signer{"first": first, "second": second})
+	if status := keyRequest(t, signToken(t, "second", second, "alice", nil), "GET", url+"/v1/projects", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("uncached key status = %d", status)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
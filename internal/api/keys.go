@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeysHandler manages the API keys of the key store.
+type KeysHandler struct {
+	keys *keys.Store
+}
+
+// NewKeysHandler creates a new keys handler. store may be nil when no key
+// store is configured.
+func NewKeysHandler(store *keys.Store) *KeysHandler {
+	return &KeysHandler{keys: store}
+}
+
+// enabled reports a missing key store to the caller.
+func (h *KeysHandler) enabled(c *gin.Context) bool {
+	if h.keys != nil {
+		return true
+	}
+	c.JSON(http.StatusNotImplemented, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: "No key store is configured. Set KEYS_FILE to manage API keys.",
+			Type:    "invalid_request_error",
+			Code:    "key_store_disabled",
+		},
+	})
+	return false
+}
+
+func keyError(c *gin.Context, id string, err error) {
+	if errors.Is(err, keys.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("No API key found with id '%s'.", id),
+				Type:    "invalid_request_error",
+				Code:    "key_not_found",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("Failed to access key store: %v", err),
+			Type:    "api_error",
+		},
+	})
+}
+
+// HandleCreateKey handles POST /v1/admin/keys
+//
+// The key itself is part of the response and cannot be retrieved later.
+func (h *KeysHandler) HandleCreateKey(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	var req models.CreateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	k, secret, err := h.keys.Create(keys.Options{
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Scopes:    req.Scopes,
+		Models:    req.Models,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if errors.Is(err, keys.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+				Code:    "invalid_key_options",
+			},
+		})
+		return
+	}
+	if err != nil {
+		keyError(c, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.CreateKeyResponse{Key: k, Secret: secret})
+}
+
+// HandleListKeys handles GET /v1/admin/keys
+func (h *KeysHandler) HandleListKeys(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	list, err := h.keys.List()
+	if err != nil {
+		keyError(c, "", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+}
+
+// HandleGetKey handles GET /v1/admin/keys/:key_id
+func (h *KeysHandler) HandleGetKey(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	id := c.Param("key_id")
+	k, err := h.keys.Get(id)
+	if err != nil {
+		keyError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, k)
+}
+
+// HandleRevokeKey handles POST /v1/admin/keys/:key_id/revoke
+func (h *KeysHandler) HandleRevokeKey(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	id := c.Param("key_id")
+	k, err := h.keys.Revoke(id)
+	if err != nil {
+		keyError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, k)
+}
+
+// HandleDeleteKey handles DELETE /v1/admin/keys/:key_id
+func (h *KeysHandler) HandleDeleteKey(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	id := c.Param("key_id")
+	if err := h.keys.Delete(id); err != nil {
+		keyError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "api_key", "deleted": true})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/models"
+)
+
+// newKeysServer starts a server that requires authentication, with "root"
+// as its only static key and a key store in keysFile.
+func newKeysServer(t *testing.T) (url, keysFile string) {
+	t.Helper()
+	keysFile = filepath.Join(t.TempDir(), "keys.json")
+	srv := newTestServer(t, map[string]string{
+		"REQUIRE_AUTH": "true",
+		"API_KEYS":     "root",
+		"KEYS_FILE":    keysFile,
+	})
+	return srv.URL, keysFile
+}
+
+func TestManagedKeys(t *testing.T) {
+	url, _ := newKeysServer(t)
+	model := "claude-sonnet-4-5-20250929"
+
+	var created models.CreateKeyResponse
+	body := fmt.Sprintf(`{"name": "ci", "owner": "build team", "scopes": ["chat"], "models": [%q], "expires_at": %d}`, model, time.Now().Add(time.Hour).Unix())
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", body, &created); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	if !strings.HasPrefix(created.Secret, "sk-cc-") || created.ID != keyPrincipal(created.Secret) || !strings.HasPrefix(created.Secret, created.Prefix) {
+		t.Fatalf("created = %+v", created)
+	}
+
+	// The key works within its scopes and models
+	if status := keyRequest(t, created.Secret, "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusOK {
+		t.Errorf("chat status = %d", status)
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, created.Secret, "GET", url+"/v1/projects", "", &out); status != http.StatusForbidden || out.Error.Code != "insufficient_scope" {
+		t.Errorf("projects = %+v (%d)", out.Error, status)
+	}
+	if status := keyRequest(t, created.Secret, "GET", url+"/v1/admin/keys", "", &out); status != http.StatusForbidden || out.Error.Code != "insufficient_scope" {
+		t.Errorf("admin = %+v (%d)", out.Error, status)
+	}
+	other := strings.Replace(chatBody("hello", false), model, "claude-opus-4-1-20250805", 1)
+	if status := keyRequest(t, created.Secret, "POST", url+"/v1/chat/completions", other, &out); status != http.StatusForbidden || out.Error.Code != "model_not_allowed" {
+		t.Errorf("other model = %+v (%d)", out.Error, status)
+	}
+
+	// Listing never reveals the key or its hash
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	keyRequest(t, "root", "GET", url+"/v1/admin/keys", "", &list)
+	if len(list.Data) != 1 || list.Data[0]["id"] != created.ID || list.Data[0]["owner"] != "build team" {
+		t.Fatalf("list = %+v", list.Data)
+	}
+	if _, ok := list.Data[0]["hash"]; ok {
+		t.Error("list exposes the key hash")
+	}
+	if _, ok := list.Data[0]["key"]; ok {
+		t.Error("list exposes the key")
+	}
+
+	// Revoked keys are rejected
+	var revoked keys.Key
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys/"+created.ID+"/revoke", "", &revoked); status != http.StatusOK || !revoked.Disabled || revoked.RevokedAt == 0 {
+		t.Errorf("revoke = %+v (%d)", revoked, status)
+	}
+	if status := keyRequest(t, created.Secret, "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked chat status = %d", status)
+	}
+
+	if status := keyRequest(t, "root", "DELETE", url+"/v1/admin/keys/"+created.ID, "", nil); status != http.StatusOK {
+		t.Errorf("delete status = %d", status)
+	}
+	if status := keyRequest(t, "root", "GET", url+"/v1/admin/keys/"+created.ID, "", &out); status != http.StatusNotFound || out.Error.Code != "key_not_found" {
+		t.Errorf("get deleted = %+v (%d)", out.Error, status)
+	}
+}
+
+func TestManagedKeyValidationAndExpiry(t *testing.T) {
+	url, keysFile := newKeysServer(t)
+
+	var out models.ErrorResponse
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", `{"name": "x", "scopes": ["everything"]}`, &out); status != http.StatusBadRequest || out.Error.Code != "invalid_key_options" {
+		t.Errorf("unknown scope = %+v (%d)", out.Error, status)
+	}
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", `{"name": "x", "expires_at": 1}`, &out); status != http.StatusBadRequest {
+		t.Errorf("past expiry status = %d", status)
+	}
+
+	var created models.CreateKeyResponse
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", `{"name": "default"}`, &created); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	if strings.Join(created.Scopes, ",") != strings.Join(keys.DefaultScopes, ",") {
+		t.Errorf("scopes = %v", created.Scopes)
+	}
+	if status := keyRequest(t, created.Secret, "GET", url+"/v1/projects", "", nil); status != http.StatusOK {
+		t.Errorf("projects status = %d", status)
+	}
+	if status := keyRequest(t, "wrong", "GET", url+"/v1/projects", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("unknown key status = %d", status)
+	}
+
+	// Changes to the file, such as those of the keys CLI, apply at once
+	data, err := os.ReadFile(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), `"created_at"`, `"expires_at": 1, "created_at"`, 1))
+	if err := os.WriteFile(keysFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(keysFile, future, future)
+	if status := keyRequest(t, created.Secret, "GET", url+"/v1/projects", "", &out); status != http.StatusUnauthorized || !strings.Contains(out.Error.Message, "expired") {
+		t.Errorf("expired key = %+v (%d)", out.Error, status)
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/streaming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessagesHandler handles Anthropic Messages API requests.
+type MessagesHandler struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+}
+
+// NewMessagesHandler creates a new messages handler.
+func NewMessagesHandler(cfg *config.Reloader, backends *backend.Registry) *MessagesHandler {
+	return &MessagesHandler{cfg: cfg, backends: backends}
+}
+
+func anthropicError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, models.AnthropicErrorResponse{
+		Type:  "error",
+		Error: models.AnthropicErrorDetail{Type: errType, Message: message},
+	})
+}
+
+func anthropicUsage(u backend.Usage) models.AnthropicUsage {
+	return models.AnthropicUsage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}
+}
+
+// HandleMessages handles POST /v1/messages
+func (h *MessagesHandler) HandleMessages(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	var req models.AnthropicMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	// The CLI runs a single prompt; use the latest user turn
+	last := req.Messages[len(req.Messages)-1]
+	prompt := last.Content.Text()
+	if last.Role != "user" || prompt == "" {
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", "The last message must be a user message with text content")
+		return
+	}
+
+	var systemPrompt string
+	if req.System != nil {
+		systemPrompt = req.System.Text()
+	}
+
+	projectID := req.ProjectID
+	if projectID == "" {
+		projectID = defaultProjectID
+	}
+
+	run, cancel, serr := startSession(c, cfg, h.backends, backend.Request{
+		Model:        model,
+		Prompt:       prompt,
+		SystemPrompt: systemPrompt,
+		ProjectID:    projectID,
+	})
+	if serr != nil {
+		errType := "api_error"
+		if serr.Status == http.StatusBadRequest {
+			errType = "invalid_request_error"
+		} else if serr.Status == http.StatusServiceUnavailable {
+			errType = "overloaded_error"
+		}
+		anthropicError(c, serr.Status, errType, serr.Message)
+		return
+	}
+	defer cancel()
+
+	if req.Stream {
+		h.handleStreamingMessages(c, run, model, projectID)
+	} else {
+		h.handleNonStreamingMessages(c, run, model, projectID)
+	}
+}
+
+func (h *MessagesHandler) handleStreamingMessages(c *gin.Context, run backend.Run, model, projectID string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Project-ID", projectID)
+
+	// Wait for the session to start so message_start can carry its ID
+	events := run.Events()
+	first, ok := <-events
+	encoder := streaming.NewAnthropicEncoder(model, first.SessionID, projectID)
+	if first.SessionID != "" {
+		c.Header("X-Session-ID", first.SessionID)
+	}
+
+	c.Writer.WriteString(encoder.MessageStart(0))
+	c.Writer.WriteString(encoder.Ping())
+	c.Writer.Flush()
+
+	handle := func(ev backend.Event) bool {
+		switch ev.Type {
+		case backend.EventText:
+			c.Writer.WriteString(encoder.Text(ev.Text))
+			c.Writer.Flush()
+		case backend.EventWorkspaceChanges:
+			c.Writer.WriteString(encoder.Extension(string(backend.EventWorkspaceChanges), ev.Changes))
+			c.Writer.Flush()
+		case backend.EventError:
+			c.Writer.WriteString(encoder.Error("api_error", ev.Error))
+			c.Writer.Flush()
+			return false
+		}
+		return true
+	}
+
+	if ok && !handle(first) {
+		return
+	}
+	for ev := range events {
+		if !handle(ev) {
+			return
+		}
+	}
+
+	c.Writer.WriteString(encoder.Finish("end_turn", anthropicUsage(run.Usage())))
+	c.Writer.Flush()
+}
+
+func (h *MessagesHandler) handleNonStreamingMessages(c *gin.Context, run backend.Run, model, projectID string) {
+	content := []models.AnthropicContentBlock{}
+	var sessionID string
+	var changes *backend.WorkspaceChanges
+
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
+		}
+		switch ev.Type {
+		case backend.EventText:
+			content = append(content, models.AnthropicContentBlock{Type: "text", Text: ev.Text})
+		case backend.EventWorkspaceChanges:
+			changes = ev.Changes
+		case backend.EventError:
+			anthropicError(c, http.StatusBadGateway, "api_error", ev.Error)
+			return
+		}
+	}
+
+	stopReason := "end_turn"
+	c.JSON(http.StatusOK, models.AnthropicMessageResponse{
+		ID:               streaming.NewAnthropicMessageID(),
+		Type:             "message",
+		Role:             "assistant",
+		Model:            model,
+		Content:          content,
+		StopReason:       &stopReason,
+		Usage:            anthropicUsage(run.Usage()),
+		SessionID:        sessionID,
+		ProjectID:        projectID,
+		WorkspaceChanges: changes,
+	})
+}
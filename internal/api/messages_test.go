@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func postMessages(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestMessagesNonStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postMessages(t, srv.URL, `{
+		"model": "claude-sonnet-4-5-20250929",
+		"max_tokens": 1024,
+		"system": [{"type": "text", "text": "be brief"}],
+		"messages": [{"role": "user", "content": [{"type": "text", "text": "[scenario:tools] look around"}]}]
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var out models.AnthropicMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "message" || out.Role != "assistant" || !strings.HasPrefix(out.ID, "msg_") {
+		t.Errorf("unexpected envelope: %+v", out)
+	}
+	if len(out.Content) != 2 || out.Content[1].Text != "The project has two files." {
+		t.Errorf("content = %+v", out.Content)
+	}
+	if out.StopReason == nil || *out.StopReason != "end_turn" {
+		t.Errorf("stop_reason = %v", out.StopReason)
+	}
+	if out.Usage.InputTokens != 12 || out.Usage.OutputTokens != 34 || out.Usage.CacheReadInputTokens != 100 {
+		t.Errorf("usage = %+v", out.Usage)
+	}
+}
+
+func TestMessagesStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postMessages(t, srv.URL, `{
+		"model": "claude-sonnet-4-5-20250929",
+		"max_tokens": 1024,
+		"stream": true,
+		"messages": [{"role": "user", "content": "[scenario:tools] look around"}]
+	}`)
+
+	var names []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+	}
+
+	want := []string{
+		"message_start", "ping",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v\nwant     %v", names, want)
+	}
+}
+
+func TestMessagesError(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postMessages(t, srv.URL, `{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"[scenario:crash] go"}]}`)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", resp.StatusCode)
+	}
+	var out models.AnthropicErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "error" || out.Error.Type != "api_error" {
+		t.Errorf("error = %+v", out)
+	}
+}
@@ -2,34 +2,42 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"claude-code-api/internal/config"
+	"claude-code-api/internal/jwtauth"
+	"claude-code-api/internal/keys"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
+// originAllowed reports whether origin is listed in AllowedOrigins.
+func originAllowed(cfg *config.Config, origin string) bool {
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
 // CORSMiddleware adds CORS headers.
-func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
+func CORSMiddleware(cfg *config.Reloader) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		// Check if origin is allowed
-		allowed := false
-		for _, o := range cfg.AllowedOrigins {
-			if o == "*" || o == origin {
-				allowed = true
-				break
-			}
-		}
-
-		if allowed {
+		if originAllowed(cfg.Get(), origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Api-Key, Anthropic-Version")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -40,6 +48,66 @@ func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// RequestIDMiddleware assigns every request an ID, honouring a client-supplied
+// X-Request-ID header, and echoes it back in the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" {
+			id = uuid.New().String()
+		}
+		c.Set("request_id", id)
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// requestID returns the ID assigned by RequestIDMiddleware.
+func requestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// principal identifies the caller authenticated by AuthMiddleware, or is
+// empty when authentication is off.
+func principal(c *gin.Context) string {
+	return c.GetString("principal")
+}
+
+// namespace returns the project namespace of the caller authenticated by
+// AuthMiddleware, or is empty when authentication is off.
+func namespace(c *gin.Context) string {
+	return c.GetString("namespace")
+}
+
+// keyPrincipal derives a stable principal from an API key without exposing
+// the key.
+func keyPrincipal(key string) string {
+	return keys.Principal(key)
+}
+
+// callerKey returns the key the caller authenticated with. It reports false
+// when authentication is off.
+func callerKey(c *gin.Context) (keys.Key, bool) {
+	v, ok := c.Get("key")
+	if !ok {
+		return keys.Key{}, false
+	}
+	return v.(keys.Key), true
+}
+
+// modelError reports a model the caller's key may not use, or nil.
+func modelError(c *gin.Context, model string) *sessionError {
+	if k, ok := callerKey(c); ok && !k.AllowsModel(model) {
+		return &sessionError{
+			Status:  http.StatusForbidden,
+			Type:    "permission_error",
+			Code:    "model_not_allowed",
+			Message: fmt.Sprintf("This API key may not use model '%s'", model),
+		}
+	}
+	return nil
+}
+
 // LoggingMiddleware logs requests.
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -57,26 +125,40 @@ func LoggingMiddleware() gin.HandlerFunc {
 			Int("status", status).
 			Dur("latency", latency).
 			Str("client_ip", c.ClientIP()).
+			Str("request_id", requestID(c)).
 			Msg("Request completed")
 	}
 }
 
-// AuthMiddleware handles API key authentication.
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// unauthorized rejects a request that failed authentication.
+func unauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(401, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    "authentication_error",
+		},
+	})
+}
+
+// AuthMiddleware handles authentication with API keys and, depending on
+// AUTH_MODE, JWTs checked by verifier. Keys listed in API_KEYS have every
+// scope; keys from the key store, which may be nil, have the scopes and
+// models they were created with; JWTs have the scopes of their claims.
+func AuthMiddleware(cfg *config.Reloader, store *keys.Store, verifier *jwtauth.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !cfg.RequireAuth {
+		current := cfg.Get()
+		if !current.RequireAuth {
 			c.Next()
 			return
 		}
 
+		// Anthropic SDK clients send the key in x-api-key
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(401, gin.H{
-				"error": gin.H{
-					"message": "Missing Authorization header",
-					"type":    "authentication_error",
-				},
-			})
+			authHeader = c.GetHeader("X-Api-Key")
+		}
+		if authHeader == "" {
+			unauthorized(c, "Missing Authorization header")
 			return
 		}
 
@@ -86,25 +168,86 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			token = authHeader[7:]
 		}
 
-		// Check against configured API keys
+		if current.AcceptsJWT() && (looksLikeJWT(token) || !current.AcceptsKeys()) {
+			if verifier == nil {
+				unauthorized(c, "JWT authentication is not available")
+				return
+			}
+			claims, err := verifier.Verify(token)
+			var p, ns string
+			var key keys.Key
+			if err == nil {
+				p, key, ns, err = jwtCaller(current, claims)
+			}
+			if err != nil {
+				log.Debug().Err(err).Str("request_id", requestID(c)).Msg("Rejected token")
+				unauthorized(c, "Invalid or expired token")
+				return
+			}
+			c.Set("principal", p)
+			c.Set("key", key)
+			c.Set("namespace", ns)
+			c.Next()
+			return
+		}
+
+		// Check against every configured API key, so the time taken does
+		// not tell which one matched
 		valid := false
-		for _, key := range cfg.APIKeys {
-			if key == token {
+		for _, key := range current.APIKeys {
+			if keys.Equal(key, token) {
 				valid = true
-				break
+			}
+		}
+		key := keys.Key{ID: keyPrincipal(token), Scopes: keys.AllScopes}
+
+		message := "Invalid API key"
+		if !valid && store != nil {
+			k, err := store.Verify(token)
+			switch {
+			case err == nil:
+				key, valid = k, true
+			case errors.Is(err, keys.ErrExpired), errors.Is(err, keys.ErrDisabled):
+				message = "API key has expired or been revoked"
+			case !errors.Is(err, keys.ErrInvalid):
+				log.Error().Err(err).Msg("Failed to verify API key")
 			}
 		}
 
 		if !valid {
-			c.AbortWithStatusJSON(401, gin.H{
-				"error": gin.H{
-					"message": "Invalid API key",
-					"type":    "authentication_error",
-				},
-			})
+			unauthorized(c, message)
 			return
 		}
 
+		p := keyPrincipal(token)
+		c.Set("principal", p)
+		c.Set("key", key)
+		c.Set("namespace", current.KeyNamespace(token, p))
 		c.Next()
 	}
 }
+
+// RequireScope lets through callers whose key has any of scopes. It has no
+// effect when authentication is off.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := callerKey(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		for _, s := range scopes {
+			if k.HasScope(s) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("This API key lacks the %s scope", strings.Join(scopes, " or ")),
+				"type":    "permission_error",
+				"code":    "insufficient_scope",
+			},
+		})
+	}
+}
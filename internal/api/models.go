@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"time"
 
-	"claude-code-api/internal/claude"
+	"claude-code-api/internal/backend"
 	"claude-code-api/internal/config"
 	"claude-code-api/internal/models"
 
@@ -14,33 +14,41 @@ import (
 
 // ModelsHandler handles models-related requests.
 type ModelsHandler struct {
-	cfg     *config.Config
-	manager *claude.Manager
+	cfg      *config.Reloader
+	backends *backend.Registry
 }
 
 // NewModelsHandler creates a new models handler.
-func NewModelsHandler(cfg *config.Config, manager *claude.Manager) *ModelsHandler {
-	return &ModelsHandler{cfg: cfg, manager: manager}
+func NewModelsHandler(cfg *config.Reloader, backends *backend.Registry) *ModelsHandler {
+	return &ModelsHandler{cfg: cfg, backends: backends}
+}
+
+// ownedBy reports the owner string for a model, derived from the version of
+// the backend serving it.
+func (h *ModelsHandler) ownedBy(modelID string) string {
+	b, err := h.backends.ForModel(modelID)
+	if err != nil {
+		return "anthropic"
+	}
+	version, _ := b.GetVersion()
+	if version == "" {
+		return "anthropic"
+	}
+	return "anthropic-claude-" + version
 }
 
 // HandleListModels handles GET /v1/models
 // Returns models from config file
 func (h *ModelsHandler) HandleListModels(c *gin.Context) {
-	claudeVersion, _ := h.manager.GetVersion()
-	ownedBy := "anthropic"
-	if claudeVersion != "" {
-		ownedBy = "anthropic-claude-" + claudeVersion
-	}
-
 	baseTimestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
 
 	var modelObjects []models.ModelObject
-	for i, m := range h.cfg.Models {
+	for i, m := range h.cfg.Get().Models {
 		modelObjects = append(modelObjects, models.ModelObject{
 			ID:      m.ID,
 			Object:  "model",
 			Created: baseTimestamp + int64(i),
-			OwnedBy: ownedBy,
+			OwnedBy: h.ownedBy(m.ID),
 		})
 	}
 
@@ -55,17 +63,11 @@ func (h *ModelsHandler) HandleListModels(c *gin.Context) {
 func (h *ModelsHandler) HandleGetModel(c *gin.Context) {
 	modelID := c.Param("model_id")
 
-	claudeVersion, _ := h.manager.GetVersion()
-	ownedBy := "anthropic"
-	if claudeVersion != "" {
-		ownedBy = "anthropic-claude-" + claudeVersion
-	}
-
 	c.JSON(http.StatusOK, models.ModelObject{
 		ID:      modelID,
 		Object:  "model",
 		Created: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
-		OwnedBy: ownedBy,
+		OwnedBy: h.ownedBy(modelID),
 	})
 }
 
@@ -73,13 +75,18 @@ func (h *ModelsHandler) HandleGetModel(c *gin.Context) {
 func (h *ModelsHandler) HandleModelCapabilities(c *gin.Context) {
 	var capabilities []map[string]interface{}
 
-	for _, m := range h.cfg.Models {
+	for _, m := range h.cfg.Get().Models {
+		backendName := m.Backend
+		if b, err := h.backends.ForModel(m.ID); err == nil {
+			backendName = b.Name()
+		}
 		cap := map[string]interface{}{
 			"id":                 m.ID,
 			"name":               m.Name,
 			"description":        m.Description,
 			"supports_streaming": true,
 			"supports_tools":     true,
+			"backend":            backendName,
 		}
 		capabilities = append(capabilities, cap)
 	}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/streaming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ollamaModifiedAt is reported as the modification time of every model.
+var ollamaModifiedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339Nano)
+
+// OllamaHandler handles Ollama API requests.
+type OllamaHandler struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+}
+
+// NewOllamaHandler creates a new Ollama handler.
+func NewOllamaHandler(cfg *config.Reloader, backends *backend.Registry) *OllamaHandler {
+	return &OllamaHandler{cfg: cfg, backends: backends}
+}
+
+func ollamaError(c *gin.Context, status int, message string) {
+	c.JSON(status, models.OllamaErrorResponse{Error: message})
+}
+
+// ollamaModelName strips the default tag Ollama clients append to names.
+func ollamaModelName(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
+
+func ollamaNow() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func ollamaDetails() models.OllamaModelDetails {
+	return models.OllamaModelDetails{
+		Format:   "claude",
+		Family:   "claude",
+		Families: []string{"claude"},
+	}
+}
+
+// ollamaMetrics reports the token counts of a run. The CLI does not time
+// prompt evaluation separately, so the whole run counts as evaluation.
+func ollamaMetrics(u backend.Usage, start time.Time) models.OllamaMetrics {
+	elapsed := time.Since(start).Nanoseconds()
+	return models.OllamaMetrics{
+		TotalDuration:   elapsed,
+		PromptEvalCount: u.PromptTokens(),
+		EvalCount:       u.OutputTokens,
+		EvalDuration:    elapsed,
+	}
+}
+
+// ollamaRun describes how a request's output is framed, so /api/chat and
+// /api/generate can share the streaming machinery.
+type ollamaRun struct {
+	chunk func(text string) interface{}
+	final func(text string, metrics models.OllamaMetrics) interface{}
+}
+
+// HandleChat handles POST /api/chat
+func (h *OllamaHandler) HandleChat(c *gin.Context) {
+	start := time.Now()
+	cfg := h.cfg.Get()
+
+	var req models.OllamaChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	var systemParts []string
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			systemParts = append(systemParts, msg.Content)
+		}
+	}
+
+	// The CLI runs a single prompt; use the latest user turn
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != "user" || last.Content == "" {
+		ollamaError(c, http.StatusBadRequest, "the last message must be a user message with content")
+		return
+	}
+
+	model := ollamaModelName(req.Model)
+	breq := backend.Request{
+		Model:        model,
+		Prompt:       last.Content,
+		SystemPrompt: strings.Join(systemParts, "\n\n"),
+		ProjectID:    req.ProjectID,
+	}
+
+	h.run(c, cfg, breq, req.Stream == nil || *req.Stream, start, ollamaRun{
+		chunk: func(text string) interface{} {
+			return models.OllamaChatResponse{
+				Model:     req.Model,
+				CreatedAt: ollamaNow(),
+				Message:   &models.OllamaMessage{Role: "assistant", Content: text},
+			}
+		},
+		final: func(text string, metrics models.OllamaMetrics) interface{} {
+			return models.OllamaChatResponse{
+				Model:         req.Model,
+				CreatedAt:     ollamaNow(),
+				Message:       &models.OllamaMessage{Role: "assistant", Content: text},
+				Done:          true,
+				DoneReason:    "stop",
+				OllamaMetrics: metrics,
+			}
+		},
+	})
+}
+
+// HandleGenerate handles POST /api/generate
+func (h *OllamaHandler) HandleGenerate(c *gin.Context) {
+	start := time.Now()
+	cfg := h.cfg.Get()
+
+	var req models.OllamaGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	// An empty prompt asks Ollama to load the model; there is nothing to load
+	if req.Prompt == "" {
+		c.JSON(http.StatusOK, models.OllamaGenerateResponse{
+			Model:      req.Model,
+			CreatedAt:  ollamaNow(),
+			Done:       true,
+			DoneReason: "load",
+		})
+		return
+	}
+
+	// A suffix turns the request into a completion; the client's system
+	// prompt still comes first
+	systemPrompt := req.System
+	if req.Suffix != "" {
+		systemPrompt = strings.TrimSpace(req.System + "\n\n" + completionSystemPrompt(req.Suffix))
+	}
+
+	breq := backend.Request{
+		Model:        ollamaModelName(req.Model),
+		Prompt:       req.Prompt,
+		SystemPrompt: systemPrompt,
+		ProjectID:    req.ProjectID,
+	}
+
+	h.run(c, cfg, breq, req.Stream == nil || *req.Stream, start, ollamaRun{
+		chunk: func(text string) interface{} {
+			return models.OllamaGenerateResponse{
+				Model:     req.Model,
+				CreatedAt: ollamaNow(),
+				Response:  text,
+			}
+		},
+		final: func(text string, metrics models.OllamaMetrics) interface{} {
+			return models.OllamaGenerateResponse{
+				Model:         req.Model,
+				CreatedAt:     ollamaNow(),
+				Response:      text,
+				Done:          true,
+				DoneReason:    "stop",
+				OllamaMetrics: metrics,
+			}
+		},
+	})
+}
+
+// run starts a session and writes its output either as an NDJSON stream or
+// as a single response. Ollama streams unless told otherwise.
+func (h *OllamaHandler) run(c *gin.Context, cfg *config.Config, breq backend.Request, stream bool, start time.Time, out ollamaRun) {
+	if breq.ProjectID == "" {
+		breq.ProjectID = defaultProjectID
+	}
+
+	run, cancel, serr := startSession(c, cfg, h.backends, breq)
+	if serr != nil {
+		ollamaError(c, serr.Status, serr.Message)
+		return
+	}
+	defer cancel()
+
+	if !stream {
+		var parts []string
+		for ev := range run.Events() {
+			switch ev.Type {
+			case backend.EventText:
+				parts = append(parts, ev.Text)
+			case backend.EventError:
+				ollamaError(c, http.StatusBadGateway, ev.Error)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, out.final(strings.Join(parts, "\n"), ollamaMetrics(run.Usage(), start)))
+		return
+	}
+
+	c.Header("Content-Type", streaming.NDJSONContentType)
+	c.Header("X-Project-ID", breq.ProjectID)
+	formatter := &streaming.NDJSONFormatter{}
+
+	first := true
+	for ev := range run.Events() {
+		switch ev.Type {
+		case backend.EventText:
+			text := ev.Text
+			if !first {
+				text = "\n" + text
+			}
+			first = false
+			c.Writer.WriteString(formatter.FormatEvent(out.chunk(text)))
+			c.Writer.Flush()
+		case backend.EventError:
+			c.Writer.WriteString(formatter.FormatError(ev.Error))
+			c.Writer.Flush()
+			return
+		}
+	}
+
+	c.Writer.WriteString(formatter.FormatEvent(out.final("", ollamaMetrics(run.Usage(), start))))
+	c.Writer.Flush()
+}
+
+// HandleTags handles GET /api/tags
+// Lists the models from the config file
+func (h *OllamaHandler) HandleTags(c *gin.Context) {
+	list := []models.OllamaModel{}
+	for _, m := range h.cfg.Get().Models {
+		digest := sha256.Sum256([]byte(m.ID))
+		list = append(list, models.OllamaModel{
+			Name:       m.ID,
+			Model:      m.ID,
+			ModifiedAt: ollamaModifiedAt,
+			Digest:     hex.EncodeToString(digest[:]),
+			Details:    ollamaDetails(),
+		})
+	}
+	c.JSON(http.StatusOK, models.OllamaTagsResponse{Models: list})
+}
+
+// HandleShow handles POST /api/show
+func (h *OllamaHandler) HandleShow(c *gin.Context) {
+	var req models.OllamaShowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	name := req.Model
+	if name == "" {
+		name = req.Name
+	}
+	id := ollamaModelName(name)
+
+	for _, m := range h.cfg.Get().Models {
+		if m.ID != id {
+			continue
+		}
+		backendName := m.Backend
+		if b, err := h.backends.ForModel(m.ID); err == nil {
+			backendName = b.Name()
+		}
+		c.JSON(http.StatusOK, models.OllamaShowResponse{
+			Modelfile: fmt.Sprintf("FROM %s\n", m.ID),
+			Template:  "{{ .Prompt }}",
+			Details:   ollamaDetails(),
+			ModelInfo: map[string]interface{}{
+				"general.architecture": "claude",
+				"general.basename":     m.Name,
+				"general.description":  m.Description,
+				"claude.backend":       backendName,
+			},
+			Capabilities: []string{"completion", "insert"},
+			ModifiedAt:   ollamaModifiedAt,
+		})
+		return
+	}
+
+	ollamaError(c, http.StatusNotFound, fmt.Sprintf("model '%s' not found", name))
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func postOllama(t *testing.T, url, path, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestOllamaChatStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	// Ollama streams by default
+	resp := postOllama(t, srv.URL, "/api/chat", `{
+		"model": "claude-sonnet-4-5-20250929:latest",
+		"messages": [{"role": "user", "content": "[scenario:tools] look"}]
+	}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var chunks []models.OllamaChatResponse
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var chunk models.OllamaChatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	var text strings.Builder
+	for _, chunk := range chunks[:2] {
+		if chunk.Done {
+			t.Error("intermediate chunk marked done")
+		}
+		text.WriteString(chunk.Message.Content)
+	}
+	if text.String() != "Let me look at the project.\nThe project has two files." {
+		t.Errorf("text = %q", text.String())
+	}
+	final := chunks[2]
+	if !final.Done || final.DoneReason != "stop" || final.EvalCount == 0 || final.PromptEvalCount == 0 {
+		t.Errorf("final chunk = %+v", final)
+	}
+}
+
+func TestOllamaGenerate(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postOllama(t, srv.URL, "/api/generate", `{"model":"claude-sonnet-4-5-20250929","prompt":"hello","stream":false}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got models.OllamaGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Response != "You said: hello" || !got.Done || got.DoneReason != "stop" {
+		t.Errorf("response = %+v", got)
+	}
+}
+
+func TestOllamaGenerateSystemAndSuffix(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	// fakeclaude counts the words of the prompt and system prompt as input
+	// tokens, so the system prompt shows up in prompt_eval_count
+	promptTokens := func(body string) int {
+		t.Helper()
+		resp := postOllama(t, srv.URL, "/api/generate", body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		var got models.OllamaGenerateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got.PromptEvalCount
+	}
+	suffixOnly := promptTokens(`{"model":"claude-sonnet-4-5-20250929","prompt":"func add(","suffix":"return a + b","stream":false}`)
+	both := promptTokens(`{"model":"claude-sonnet-4-5-20250929","prompt":"func add(","suffix":"return a + b","system":"write idiomatic Go","stream":false}`)
+	if both != suffixOnly+3 {
+		t.Errorf("prompt tokens with a system prompt = %d, want %d", both, suffixOnly+3)
+	}
+}
+
+func TestOllamaTagsAndShow(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp, err := http.Get(srv.URL + "/api/tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var tags models.OllamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Models) == 0 || tags.Models[0].Digest == "" {
+		t.Fatalf("tags = %+v", tags)
+	}
+
+	show := postOllama(t, srv.URL, "/api/show", `{"model":"`+tags.Models[0].Name+`"}`)
+	if show.StatusCode != http.StatusOK {
+		t.Errorf("show status = %d, want 200", show.StatusCode)
+	}
+	missing := postOllama(t, srv.URL, "/api/show", `{"name":"llama3"}`)
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown model status = %d, want 404", missing.StatusCode)
+	}
+}
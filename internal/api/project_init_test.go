@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"claude-code-api/internal/projects"
+)
+
+// projectInitServer starts a server with a template and a git repository
+// configured for new projects.
+func projectInitServer(t *testing.T) (url, root, repo string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	base := t.TempDir()
+	tmpl := filepath.Join(base, "template")
+	os.MkdirAll(filepath.Join(tmpl, "src"), 0755)
+	os.WriteFile(filepath.Join(tmpl, "CLAUDE.md"), []byte("# Conventions\n"), 0644)
+	os.WriteFile(filepath.Join(tmpl, "src", "main.go"), []byte("package main\n"), 0644)
+
+	// A bare repository with a main branch and a feature branch
+	repo = filepath.Join(base, "app.git")
+	work := filepath.Join(base, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run(base, "init", "--quiet", "--bare", repo)
+	run(base, "clone", "--quiet", repo, work)
+	os.WriteFile(filepath.Join(work, "README.md"), []byte("main\n"), 0644)
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "initial")
+	run(work, "checkout", "--quiet", "-b", "feature")
+	os.WriteFile(filepath.Join(work, "FEATURE.md"), []byte("feature\n"), 0644)
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "feature")
+	run(work, "push", "--quiet", "origin", "HEAD:refs/heads/feature")
+	run(work, "push", "--quiet", "origin", "HEAD~1:refs/heads/main")
+	run(base, "--git-dir", repo, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	configFile := filepath.Join(base, "config.yaml")
+	os.WriteFile(configFile, []byte(fmt.Sprintf("project_templates:\n  service: %s\nproject_repos:\n  - %s\ndefault_project_template: service\n", tmpl, repo)), 0644)
+
+	root = t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "CONFIG_FILE": configFile})
+	return srv.URL, root, repo
+}
+
+func TestProjectFromTemplate(t *testing.T) {
+	url, root, _ := projectInitServer(t)
+
+	var p projects.Project
+	if status := projectRequest(t, "POST", url+"/v1/projects", `{"id":"svc","template":"service"}`, &p); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	if p.Source == nil || p.Source.Template != "service" || p.FileCount != 2 {
+		t.Errorf("project = %+v", p)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "svc", "CLAUDE.md")); string(data) != "# Conventions\n" {
+		t.Errorf("CLAUDE.md = %q", data)
+	}
+
+	for body, want := range map[string]int{
+		`{"id":"x1","template":"missing"}`:                       http.StatusBadRequest,
+		`{"id":"x2","template":"service","repository":"/tmp/r"}`: http.StatusBadRequest,
+		`{"id":"x3","template":"service","ref":"main"}`:          http.StatusBadRequest,
+	} {
+		if status := projectRequest(t, "POST", url+"/v1/projects", body, nil); status != want {
+			t.Errorf("%s: status = %d, want %d", body, status, want)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, ".projects")); len(entries) != 1 {
+		t.Errorf("leftovers in metadata dir: %v", entries)
+	}
+
+	// First use of a project applies the default template
+	chat := `{"model":"claude-sonnet-4-5-20250929","project_id":"fresh","messages":[{"role":"user","content":"hi"}]}`
+	if status := projectRequest(t, "POST", url+"/v1/chat/completions", chat, nil); status != http.StatusOK {
+		t.Fatalf("chat status = %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "fresh", "src", "main.go")); err != nil {
+		t.Errorf("default template not applied: %v", err)
+	}
+
+	// So does uploading into it
+	if _, status := uploadProjectFile(t, url, map[string]string{"project_id": "uploaded", "path": "notes.txt"}, "x"); status != http.StatusOK {
+		t.Fatalf("upload status = %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "uploaded", "src", "main.go")); err != nil {
+		t.Errorf("default template not applied on upload: %v", err)
+	}
+}
+
+func TestProjectFromRepository(t *testing.T) {
+	url, root, repo := projectInitServer(t)
+
+	var p projects.Project
+	if status := projectRequest(t, "POST", url+"/v1/projects", `{"id":"app","repository":"`+repo+`"}`, &p); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "app", "FEATURE.md")); !os.IsNotExist(err) {
+		t.Errorf("default branch clone has feature file: %v", err)
+	}
+
+	if status := projectRequest(t, "POST", url+"/v1/projects", `{"id":"app-feature","repository":"`+repo+`","ref":"feature"}`, &p); status != http.StatusOK {
+		t.Fatalf("create at ref status = %d", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "app-feature", "FEATURE.md")); string(data) != "feature\n" {
+		t.Errorf("FEATURE.md = %q", data)
+	}
+	if p.Source == nil || p.Source.Ref != "feature" {
+		t.Errorf("source = %+v", p.Source)
+	}
+
+	other := t.TempDir()
+	for body, want := range map[string]int{
+		`{"id":"r1","repository":"` + other + `"}`:                        http.StatusBadRequest,
+		`{"id":"r2","repository":"` + repo + `/../app.git/.."}`:           http.StatusBadRequest,
+		`{"id":"r3","repository":"` + repo + `","ref":"--upload-pack=x"}`: http.StatusBadRequest,
+		`{"id":"r4","repository":"` + repo + `","ref":"no-such-branch"}`:  http.StatusBadRequest,
+	} {
+		if status := projectRequest(t, "POST", url+"/v1/projects", body, nil); status != want {
+			t.Errorf("%s: status = %d, want %d", body, status, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "r4")); !os.IsNotExist(err) {
+		t.Errorf("failed clone left a project behind: %v", err)
+	}
+}
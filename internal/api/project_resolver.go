@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/projects"
+)
+
+// projectIDGrammar describes valid project IDs in error messages.
+const projectIDGrammar = "1-128 letters, digits, '.', '_' or '-', starting with a letter or digit"
+
+// projectAccess checks a project reference supplied by a caller in
+// namespace ns and returns the project it names, provided the caller has at
+// least access need to it. Projects the caller cannot see at all are
+// reported as not found.
+func projectAccess(cfg *config.Config, ns, ref string, need projects.Access) (projects.Ref, *sessionError) {
+	r, err := projects.ParseRef(ns, ref)
+	if err != nil {
+		return projects.Ref{}, projectIDError(ref, err)
+	}
+	access := projects.AccessTo(cfg, ns, r)
+	switch {
+	case access == projects.AccessNone:
+		return projects.Ref{}, &sessionError{
+			Status:  http.StatusNotFound,
+			Type:    "invalid_request_error",
+			Code:    "project_not_found",
+			Message: fmt.Sprintf("Project '%s' not found", ref),
+		}
+	case access < need && access == projects.AccessRead:
+		return projects.Ref{}, &sessionError{
+			Status:  http.StatusForbidden,
+			Type:    "permission_error",
+			Code:    "project_read_only",
+			Message: fmt.Sprintf("Project '%s' is shared read-only; only read_only runs and downloads are allowed", ref),
+		}
+	case access < need:
+		return projects.Ref{}, &sessionError{
+			Status:  http.StatusForbidden,
+			Type:    "permission_error",
+			Code:    "project_not_owned",
+			Message: fmt.Sprintf("Project '%s' can only be renamed or deleted by its own namespace", ref),
+		}
+	}
+	return r, nil
+}
+
+// resolveProject checks a project reference supplied by a caller in
+// namespace ns and returns the project and its directory, which is
+// guaranteed to lie inside the project root of the project's namespace
+// once symlinks are resolved. write says whether the run may modify the
+// project. Every request that runs in a project goes through it before the
+// directory is created or used; projects of other namespaces are never
+// created on first use.
+func resolveProject(cfg *config.Config, ns, ref string, write bool) (projects.Ref, string, *sessionError) {
+	need := projects.AccessRead
+	if write {
+		need = projects.AccessWrite
+	}
+	r, serr := projectAccess(cfg, ns, ref, need)
+	if serr != nil {
+		return projects.Ref{}, "", serr
+	}
+	dir, err := projects.Resolve(projects.NamespaceRoot(cfg.ProjectRoot, r.Namespace), r.ID)
+	if err != nil {
+		return projects.Ref{}, "", projectIDError(ref, err)
+	}
+	if r.Namespace != ns {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return projects.Ref{}, "", &sessionError{
+				Status:  http.StatusNotFound,
+				Type:    "invalid_request_error",
+				Code:    "project_not_found",
+				Message: fmt.Sprintf("Project '%s' not found", ref),
+			}
+		}
+	}
+	return r, dir, nil
+}
+
+// projectIDError describes why a project ID was rejected.
+func projectIDError(id string, err error) *sessionError {
+	switch {
+	case errors.Is(err, projects.ErrInvalidID):
+		return &sessionError{
+			Status:  http.StatusBadRequest,
+			Type:    "invalid_request_error",
+			Code:    "invalid_project_id",
+			Message: fmt.Sprintf("Invalid project_id '%s': use %s, prefixed with 'namespace:' for a project shared from another namespace", id, projectIDGrammar),
+		}
+	case errors.Is(err, projects.ErrOutsideRoot):
+		return &sessionError{
+			Status:  http.StatusBadRequest,
+			Type:    "invalid_request_error",
+			Code:    "invalid_project_id",
+			Message: fmt.Sprintf("Project '%s' resolves outside the project root", id),
+		}
+	}
+	return &sessionError{
+		Status:  http.StatusInternalServerError,
+		Type:    "api_error",
+		Code:    "project_unavailable",
+		Message: fmt.Sprintf("Failed to resolve project '%s': %v", id, err),
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func TestProjectIDValidation(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "projects")
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	for _, id := range []string{
+		"../../etc",
+		"..",
+		"/etc",
+		"a/b",
+		`a\b`,
+		".hidden",
+		"-rf",
+		"with space",
+		"tab\tid",
+		strings.Repeat("a", 129),
+	} {
+		var out models.ErrorResponse
+		status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody(id, "hello", false), &out)
+		if status != http.StatusBadRequest || out.Error.Code != "invalid_project_id" {
+			t.Errorf("project_id %q: status = %d, error = %+v", id, status, out.Error)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "etc")); !os.IsNotExist(err) {
+		t.Errorf("directory created outside the project root: %v", err)
+	}
+
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("my-app_v1.2", "hello", false), nil); status != http.StatusOK {
+		t.Errorf("valid project_id status = %d", status)
+	}
+
+	var out models.ErrorResponse
+	status := projectRequest(t, "POST", srv.URL+"/v1/tasks", `{"prompt": "hello", "project_id": "../up"}`, &out)
+	if status != http.StatusBadRequest || out.Error.Code != "invalid_project_id" {
+		t.Errorf("task: status = %d, error = %+v", status, out.Error)
+	}
+	if _, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": "../up", "path": "x.txt"}, "x"); status != http.StatusBadRequest {
+		t.Errorf("upload status = %d", status)
+	}
+}
+
+func TestProjectSymlinkContainment(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	// Project directories may not lead out of the root through a symlink,
+	// even a dangling one
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"escape", "dangling"} {
+		var out models.ErrorResponse
+		status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody(id, "[scenario:edit] hi", false), &out)
+		if status != http.StatusBadRequest || out.Error.Code != "invalid_project_id" {
+			t.Errorf("%s: status = %d, error = %+v", id, status, out.Error)
+		}
+		if status := projectRequest(t, "GET", srv.URL+"/v1/projects/"+id+"/tree", "", nil); status != http.StatusBadRequest {
+			t.Errorf("%s tree status = %d", id, status)
+		}
+		if _, status := uploadProjectFile(t, srv.URL, map[string]string{"project_id": id, "path": "x.txt"}, "x"); status != http.StatusBadRequest {
+			t.Errorf("%s upload status = %d", id, status)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside the project root: %v", entries)
+	}
+
+	// Links between projects stay inside the root and are allowed
+	os.Mkdir(filepath.Join(root, "real"), 0o755)
+	os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "alias"))
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("alias", "hello", false), nil); status != http.StatusOK {
+		t.Errorf("alias status = %d", status)
+	}
+}
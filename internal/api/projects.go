@@ -0,0 +1,422 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/files"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// maxTreeEntries bounds a single project tree listing.
+const maxTreeEntries = 10000
+
+// ProjectsHandler handles project management. Callers see the projects of
+// their own namespace and those shared with it.
+type ProjectsHandler struct {
+	cfg      *config.Reloader
+	projects *projects.Manager
+	files    *files.Store
+}
+
+// NewProjectsHandler creates a new projects handler.
+func NewProjectsHandler(cfg *config.Reloader, manager *projects.Manager, store *files.Store) *ProjectsHandler {
+	return &ProjectsHandler{cfg: cfg, projects: manager, files: store}
+}
+
+// project resolves the project named in the URL for the caller, who needs
+// at least access need to it. If the project is out of reach it reports
+// the error and returns a nil manager; otherwise it returns the manager of
+// the project's namespace and the project.
+func (h *ProjectsHandler) project(c *gin.Context, need projects.Access) (*projects.Manager, projects.Ref) {
+	ref, serr := projectAccess(h.cfg.Get(), namespace(c), c.Param("project_id"), need)
+	if serr != nil {
+		c.JSON(serr.Status, serr.openAIError())
+		return nil, ref
+	}
+	return h.projects.Namespace(ref.Namespace), ref
+}
+
+func projectNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("Project '%s' not found", id),
+			Type:    "invalid_request_error",
+			Code:    "project_not_found",
+		},
+	})
+}
+
+func projectExists(c *gin.Context, id string) {
+	c.JSON(http.StatusConflict, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("Project '%s' already exists", id),
+			Type:    "invalid_request_error",
+			Code:    "project_exists",
+		},
+	})
+}
+
+// projectError reports an error from the projects manager.
+func projectError(c *gin.Context, id string, err error) {
+	switch {
+	case errors.Is(err, projects.ErrNotFound):
+		projectNotFound(c, id)
+	case errors.Is(err, projects.ErrInvalidID), errors.Is(err, projects.ErrOutsideRoot):
+		c.JSON(http.StatusBadRequest, projectIDError(id, err).openAIError())
+	case errors.Is(err, projects.ErrExists):
+		projectExists(c, id)
+	case errors.Is(err, projects.ErrUnknownTemplate):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Unknown project template",
+				Type:    "invalid_request_error",
+				Code:    "unknown_template",
+			},
+		})
+	case errors.Is(err, projects.ErrRepoNotAllowed):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Repository is not in the project_repos allowlist",
+				Type:    "invalid_request_error",
+				Code:    "repository_not_allowed",
+			},
+		})
+	case errors.Is(err, projects.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+				Code:    "invalid_ref",
+			},
+		})
+	case errors.Is(err, projects.ErrInvalidSource):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Specify either a template or a repository (with an optional ref)",
+				Type:    "invalid_request_error",
+				Code:    "invalid_source",
+			},
+		})
+	case errors.Is(err, projects.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Snapshot '%s' not found", c.Param("snapshot_id")),
+				Type:    "invalid_request_error",
+				Code:    "snapshot_not_found",
+			},
+		})
+	case errors.Is(err, projects.ErrProjectInUse):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "A session is running in the project; try again once the session has ended",
+				Type:    "invalid_request_error",
+				Code:    "project_in_use",
+			},
+		})
+	case errors.Is(err, projects.ErrBadPath):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Path must stay inside the project directory",
+				Type:    "invalid_request_error",
+				Code:    "invalid_path",
+			},
+		})
+	default:
+		log.Error().Err(err).Str("project_id", id).Msg("Project operation failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Project operation failed: %v", err),
+				Type:    "api_error",
+			},
+		})
+	}
+}
+
+// HandleCreateProject handles POST /v1/projects
+func (h *ProjectsHandler) HandleCreateProject(c *gin.Context) {
+	var req models.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if req.ID == "" {
+		req.ID = "proj_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+
+	src := projects.Source{Template: req.Template, Repository: req.Repository, Ref: req.Ref}
+	p, err := h.projects.Namespace(namespace(c)).Create(c.Request.Context(), req.ID, principal(c), req.Metadata, src)
+	if err != nil {
+		projectError(c, req.ID, err)
+		return
+	}
+	log.Info().Str("project_id", p.ID).Str("namespace", p.Namespace).Str("owner", p.Owner).Str("template", src.Template).Str("repository", src.Repository).Str("ref", src.Ref).Msg("Project created")
+	c.JSON(http.StatusOK, p)
+}
+
+// HandleListProjects handles GET /v1/projects
+//
+// It lists the caller's projects, followed by the projects other
+// namespaces share with the caller.
+func (h *ProjectsHandler) HandleListProjects(c *gin.Context) {
+	ns := namespace(c)
+	list, err := h.projects.Namespace(ns).List()
+	if err != nil {
+		projectError(c, "", err)
+		return
+	}
+	if ns != "" {
+		for _, s := range h.cfg.Get().SharedProjects {
+			grant := s.Grants[ns]
+			if grant == "" || s.Namespace == ns {
+				continue
+			}
+			if p, err := h.projects.Namespace(s.Namespace).Get(s.Project); err == nil {
+				p.Access = grant
+				list = append(list, p)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+}
+
+// HandleGetProject handles GET /v1/projects/:project_id
+func (h *ProjectsHandler) HandleGetProject(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	p, err := m.Get(ref.ID)
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// HandleRenameProject handles POST /v1/projects/:project_id/rename
+func (h *ProjectsHandler) HandleRenameProject(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessOwner)
+	if m == nil {
+		return
+	}
+	var req models.RenameProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	p, err := m.Rename(ref.ID, req.ID)
+	if err != nil {
+		if errors.Is(err, projects.ErrNotFound) {
+			projectError(c, c.Param("project_id"), err)
+		} else {
+			projectError(c, req.ID, err)
+		}
+		return
+	}
+	if err := h.files.RenameProject(ref.Namespace, ref.ID, req.ID); err != nil {
+		log.Error().Err(err).Str("project_id", req.ID).Msg("Failed to move project file records")
+	}
+	log.Info().Str("project_id", ref.ID).Str("new_project_id", req.ID).Msg("Project renamed")
+	c.JSON(http.StatusOK, p)
+}
+
+// HandleDeleteProject handles DELETE /v1/projects/:project_id
+func (h *ProjectsHandler) HandleDeleteProject(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessOwner)
+	if m == nil {
+		return
+	}
+	if err := m.Delete(ref.ID); err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	if err := h.files.ForgetProject(ref.Namespace, ref.ID); err != nil {
+		log.Error().Err(err).Str("project_id", ref.ID).Msg("Failed to drop project file records")
+	}
+	log.Info().Str("project_id", ref.ID).Msg("Project deleted")
+	c.JSON(http.StatusOK, gin.H{"id": c.Param("project_id"), "object": "project", "deleted": true})
+}
+
+// HandleProjectTree handles GET /v1/projects/:project_id/tree?path=
+func (h *ProjectsHandler) HandleProjectTree(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	entries, truncated, err := m.Tree(ref.ID, c.Query("path"), maxTreeEntries)
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": entries, "truncated": truncated})
+}
+
+// HandleProjectFile handles GET /v1/projects/:project_id/files/*path
+func (h *ProjectsHandler) HandleProjectFile(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	f, info, err := m.Open(ref.ID, c.Param("path"))
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(info.Name()))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	c.DataFromReader(http.StatusOK, info.Size(), contentType, f, nil)
+}
+
+// HandleProjectCommits handles GET /v1/projects/:project_id/commits
+//
+// It lists the checkpoint history, newest first; request_id narrows it to
+// the commits made for one request.
+func (h *ProjectsHandler) HandleProjectCommits(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	dir, err := m.Workspace(ref.ID)
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	commits, err := projects.History(c.Request.Context(), dir, limit, c.Query("request_id"))
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": commits})
+}
+
+// HandleListSnapshots handles GET /v1/projects/:project_id/snapshots
+func (h *ProjectsHandler) HandleListSnapshots(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	list, err := m.Snapshots(ref.ID)
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+}
+
+// HandleCreateSnapshot handles POST /v1/projects/:project_id/snapshots
+func (h *ProjectsHandler) HandleCreateSnapshot(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessWrite)
+	if m == nil {
+		return
+	}
+	s, err := m.Snapshot(ref.ID, projects.ReasonManual, requestID(c))
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// HandleRestoreSnapshot handles
+// POST /v1/projects/:project_id/snapshots/:snapshot_id/restore
+func (h *ProjectsHandler) HandleRestoreSnapshot(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessWrite)
+	if m == nil {
+		return
+	}
+	p, s, err := m.RestoreSnapshot(ref.ID, c.Param("snapshot_id"))
+	if err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	log.Info().Str("project_id", ref.ID).Str("snapshot_id", s.ID).Msg("Project restored from snapshot")
+	c.JSON(http.StatusOK, gin.H{"project": p, "snapshot": s})
+}
+
+// HandleDeleteSnapshot handles
+// DELETE /v1/projects/:project_id/snapshots/:snapshot_id
+func (h *ProjectsHandler) HandleDeleteSnapshot(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessWrite)
+	if m == nil {
+		return
+	}
+	snapID := c.Param("snapshot_id")
+	if err := m.DeleteSnapshot(ref.ID, snapID); err != nil {
+		projectError(c, c.Param("project_id"), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": snapID, "object": "project.snapshot", "deleted": true})
+}
+
+// HandleProjectArchive handles GET /v1/projects/:project_id/archive?format=
+func (h *ProjectsHandler) HandleProjectArchive(c *gin.Context) {
+	m, ref := h.project(c, projects.AccessRead)
+	if m == nil {
+		return
+	}
+	format := c.DefaultQuery("format", projects.FormatTarGz)
+
+	var contentType string
+	switch format {
+	case projects.FormatTarGz:
+		contentType = "application/gzip"
+	case projects.FormatZip:
+		contentType = "application/zip"
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Unsupported archive format '%s'. Supported: %s, %s.", format, projects.FormatTarGz, projects.FormatZip),
+				Type:    "invalid_request_error",
+				Code:    "invalid_format",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(ref.ID+"."+format))
+	c.Status(http.StatusOK)
+	if err := m.WriteArchive(ref.ID, format, c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			projectError(c, c.Param("project_id"), err)
+			return
+		}
+		// Headers are out; the client sees a truncated archive
+		log.Error().Err(err).Str("project_id", ref.ID).Msg("Failed to write project archive")
+	}
+}
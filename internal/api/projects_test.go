@@ -0,0 +1,190 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/projects"
+)
+
+// projectRequest sends an authenticated request and decodes the JSON reply
+// into out.
+func projectRequest(t *testing.T, method, url, body string, out interface{}) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestProjectLifecycle(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "REQUIRE_AUTH": "true", "API_KEYS": "secret"})
+	// The key's projects live in its namespace
+	root = projects.NamespaceRoot(root, keyPrincipal("secret"))
+
+	var p projects.Project
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects", `{"id":"demo","metadata":{"team":"data"}}`, &p); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	if p.ID != "demo" || p.Owner != keyPrincipal("secret") || p.Metadata["team"] != "data" {
+		t.Errorf("created = %+v", p)
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects", `{"id":"demo"}`, nil); status != http.StatusConflict {
+		t.Errorf("duplicate create status = %d, want 409", status)
+	}
+	for _, id := range []string{".projects", "a/b", ".."} {
+		if status := projectRequest(t, "POST", srv.URL+"/v1/projects", `{"id":"`+id+`"}`, nil); status != http.StatusBadRequest {
+			t.Errorf("create %q status = %d, want 400", id, status)
+		}
+	}
+
+	os.MkdirAll(filepath.Join(root, "demo", "src"), 0755)
+	os.WriteFile(filepath.Join(root, "demo", "src", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(root, "demo", "README.md"), []byte("# demo\n"), 0644)
+
+	if status := projectRequest(t, "GET", srv.URL+"/v1/projects/demo", "", &p); status != http.StatusOK || p.SizeBytes != 20 || p.FileCount != 2 {
+		t.Errorf("get = %+v (%d)", p, status)
+	}
+
+	// A chat request creates a project implicitly and marks it used
+	chat := `{"model":"claude-sonnet-4-5-20250929","project_id":"chatty","messages":[{"role":"user","content":"hello"}]}`
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", chat, nil); status != http.StatusOK {
+		t.Fatalf("chat status = %d", status)
+	}
+	var list struct {
+		Data []projects.Project `json:"data"`
+	}
+	projectRequest(t, "GET", srv.URL+"/v1/projects", "", &list)
+	if len(list.Data) != 2 || list.Data[0].ID != "chatty" {
+		t.Errorf("list = %+v", list.Data)
+	}
+
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/demo/rename", `{"id":"chatty"}`, nil); status != http.StatusConflict {
+		t.Errorf("rename onto existing status = %d, want 409", status)
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/demo/rename", `{"id":"renamed"}`, &p); status != http.StatusOK || p.ID != "renamed" || p.Owner == "" {
+		t.Errorf("rename = %+v (%d)", p, status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "renamed", "README.md")); err != nil {
+		t.Errorf("renamed project missing files: %v", err)
+	}
+
+	var deleted map[string]interface{}
+	if status := projectRequest(t, "DELETE", srv.URL+"/v1/projects/renamed", "", &deleted); status != http.StatusOK || deleted["deleted"] != true {
+		t.Errorf("delete = %v (%d)", deleted, status)
+	}
+	if status := projectRequest(t, "GET", srv.URL+"/v1/projects/renamed", "", nil); status != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", status)
+	}
+}
+
+func TestProjectBrowsing(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	dir := filepath.Join(root, "demo")
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo\n"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+	os.Symlink(outside, filepath.Join(dir, "escape"))
+
+	var tree struct {
+		Data      []projects.Entry `json:"data"`
+		Truncated bool             `json:"truncated"`
+	}
+	projectRequest(t, "GET", srv.URL+"/v1/projects/demo/tree", "", &tree)
+	var paths []string
+	for _, e := range tree.Data {
+		paths = append(paths, e.Path+":"+e.Type)
+	}
+	if got := strings.Join(paths, " "); got != "escape:symlink notes.txt:file src:dir src/main.go:file" {
+		t.Errorf("tree = %s", got)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/projects/demo/files/src/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != "package main\n" {
+		t.Errorf("read file = %q (%d)", data, resp.StatusCode)
+	}
+	for path, want := range map[string]int{
+		"escape/secret":     http.StatusBadRequest,
+		"src/../../x":       http.StatusBadRequest,
+		"missing.txt":       http.StatusNotFound,
+		"src":               http.StatusNotFound,
+		"%2e%2e/%2e%2e/etc": http.StatusBadRequest,
+	} {
+		resp, _ := http.Get(srv.URL + "/v1/projects/demo/files/" + path)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("read %s status = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+
+	// tar.gz keeps the symlink as a link; neither archive includes its target
+	resp, _ = http.Get(srv.URL + "/v1/projects/demo/archive?format=tar.gz")
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	resp.Body.Close()
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "demo/ demo/escape demo/notes.txt demo/src/ demo/src/main.go" {
+		t.Errorf("tar entries = %s", got)
+	}
+
+	resp, _ = http.Get(srv.URL + "/v1/projects/demo/archive?format=zip")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "demo/ demo/notes.txt demo/src/ demo/src/main.go" {
+		t.Errorf("zip entries = %s", got)
+	}
+
+	resp, _ = http.Get(srv.URL + "/v1/projects/missing/archive")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("Content-Type") == "application/gzip" {
+		t.Errorf("missing archive status = %d (%s)", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"claude-code-api/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryAfter formats d as whole seconds for the Retry-After header.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+// resetIn formats the time until a budget is replenished the way OpenAI
+// does, such as 20ms, 6s or 1h2m3s.
+func resetIn(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// RateLimitMiddleware limits the requests of each API key and reports its
+// request and token budgets in x-ratelimit-* headers.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := limiter.Allow(principal(c))
+		if st.RequestLimit > 0 {
+			c.Header("x-ratelimit-limit-requests", strconv.Itoa(st.RequestLimit))
+			c.Header("x-ratelimit-remaining-requests", strconv.Itoa(st.RequestsRemaining))
+			c.Header("x-ratelimit-reset-requests", resetIn(st.RequestsReset))
+		}
+		if st.TokenLimit > 0 {
+			c.Header("x-ratelimit-limit-tokens", strconv.FormatInt(st.TokenLimit, 10))
+			c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(st.TokensRemaining, 10))
+			c.Header("x-ratelimit-reset-tokens", resetIn(st.TokensReset))
+		}
+		if !st.Allowed {
+			c.Header("Retry-After", retryAfter(st.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"message": fmt.Sprintf("Rate limit reached: %d requests per minute. Try again in %s.", st.RequestLimit, resetIn(st.RetryAfter)),
+					"type":    "rate_limit_error",
+					"code":    "rate_limit_exceeded",
+				},
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitError maps a run refused by the rate limiter to a 429.
+func rateLimitError(err error) *sessionError {
+	var le *ratelimit.LimitError
+	if !errors.As(err, &le) {
+		return nil
+	}
+	code, message := "session_limit_exceeded", fmt.Sprintf("This API key may run %d sessions at once; wait for one to finish", le.Limit)
+	if errors.Is(err, ratelimit.ErrTokenLimit) {
+		code, message = "token_limit_exceeded", fmt.Sprintf("This API key used its %d tokens for today; the budget resets in %s", le.Limit, resetIn(le.RetryAfter))
+	}
+	return &sessionError{
+		Status:  http.StatusTooManyRequests,
+		Type:    "rate_limit_error",
+		Code:    code,
+		Message: message,
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/batches"
+	"claude-code-api/internal/models"
+)
+
+// limitedRequest sends a request as key and returns the response, whose
+// body is closed by the test's cleanup.
+func limitedRequest(t *testing.T, key, method, url, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRequestRateLimit(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := fmt.Sprintf("rate_limits:\n  %s:\n    requests_per_minute: 0\n", keyPrincipal("unlimited"))
+	if err := os.WriteFile(configFile, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	url := newTestServer(t, map[string]string{
+		"REQUIRE_AUTH":                   "true",
+		"API_KEYS":                       "alice,bob,unlimited",
+		"CONFIG_FILE":                    configFile,
+		"RATE_LIMIT_REQUESTS_PER_MINUTE": "2",
+	}).URL
+
+	for i, want := range []string{"1", "0"} {
+		resp := limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("hello", false))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d", i, resp.StatusCode)
+		}
+		if got := resp.Header.Get("x-ratelimit-limit-requests"); got != "2" {
+			t.Errorf("limit header = %q", got)
+		}
+		if got := resp.Header.Get("x-ratelimit-remaining-requests"); got != want {
+			t.Errorf("request %d remaining = %q, want %s", i, got, want)
+		}
+		if resp.Header.Get("x-ratelimit-reset-requests") == "" {
+			t.Error("missing reset header")
+		}
+	}
+
+	resp := limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("hello", false))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("over limit status = %d", resp.StatusCode)
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || secs < 1 || secs > 30 {
+		t.Errorf("Retry-After = %q", resp.Header.Get("Retry-After"))
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, "alice", "POST", url+"/v1/messages", `{"model":"claude-sonnet-4-5-20250929","max_tokens":100,"messages":[{"role":"user","content":"hello"}]}`, &out); status != http.StatusTooManyRequests || out.Error.Code != "rate_limit_exceeded" {
+		t.Errorf("over limit = %+v (%d)", out.Error, status)
+	}
+
+	// Only requests that start runs are limited, so polling goes on
+	for _, path := range []string{"/v1/projects", "/v1/tasks/task_missing", "/v1/batches", "/v1/usage"} {
+		resp := limitedRequest(t, "alice", "GET", url+path, "")
+		if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("x-ratelimit-limit-requests") != "" {
+			t.Errorf("GET %s = %d %v", path, resp.StatusCode, resp.Header)
+		}
+	}
+
+	// Each key has its own budget, and rate_limits may lift it
+	if status := keyRequest(t, "bob", "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusOK {
+		t.Errorf("bob status = %d", status)
+	}
+	for i := 0; i < 5; i++ {
+		resp := limitedRequest(t, "unlimited", "POST", url+"/v1/chat/completions", chatBody("hello", false))
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("x-ratelimit-limit-requests") != "" {
+			t.Fatalf("unlimited request %d = %d %v", i, resp.StatusCode, resp.Header)
+		}
+	}
+}
+
+func TestSessionAndTokenLimits(t *testing.T) {
+	url := newTestServer(t, map[string]string{
+		"REQUIRE_AUTH":                   "true",
+		"API_KEYS":                       "alice,bob",
+		"RATE_LIMIT_CONCURRENT_SESSIONS": "1",
+		"RATE_LIMIT_TOKENS_PER_DAY":      "40",
+		"STREAMING_TIMEOUT_SECONDS":      "1",
+	}).URL
+
+	// Hold a session open until the streaming timeout ends it
+	slow := limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("[scenario:slow] take your time", true))
+	scanner := bufio.NewScanner(slow.Body)
+	for scanner.Scan() && !strings.Contains(scanner.Text(), "tick") {
+	}
+
+	resp := limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("hello", false))
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("second session = %d (Retry-After %q)", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if status := keyRequest(t, "bob", "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusOK {
+		t.Errorf("bob status = %d", status)
+	}
+	for scanner.Scan() {
+	}
+
+	// The session is free again; the prompt uses up the daily tokens
+	var out models.ErrorResponse
+	resp = limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody(strings.Repeat("word ", 50), false))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("third session status = %d", resp.StatusCode)
+	}
+	resp = limitedRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("hello", false))
+	if got := resp.Header.Get("x-ratelimit-remaining-tokens"); got != "0" || resp.Header.Get("x-ratelimit-limit-tokens") != "40" {
+		t.Errorf("token headers = %v", resp.Header)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != http.StatusTooManyRequests || out.Error.Code != "token_limit_exceeded" {
+		t.Errorf("over token limit = %+v (%d)", out.Error, resp.StatusCode)
+	}
+}
+
+func TestEarlyReturnReleasesSession(t *testing.T) {
+	url := newTestServer(t, map[string]string{
+		"REQUIRE_AUTH":                   "true",
+		"API_KEYS":                       "alice",
+		"RATE_LIMIT_CONCURRENT_SESSIONS": "1",
+	}).URL
+
+	// The stop sequence ends the stream before the run's last event is read
+	resp := limitedRequest(t, "alice", "POST", url+"/v1/completions", `{"prompt":"[scenario:tools] look","stream":true,"stop":["project."]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("completion status = %d", resp.StatusCode)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	// The run's hooks finish shortly after the handler returns
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := keyRequest(t, "alice", "POST", url+"/v1/chat/completions", chatBody("hello", false), nil)
+		if status == http.StatusOK {
+			break
+		}
+		if status != http.StatusTooManyRequests || time.Now().After(deadline) {
+			t.Fatalf("session not released: status = %d", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestTokenLimitAcrossRestartsAndBatches(t *testing.T) {
+	env := map[string]string{
+		"RATE_LIMIT_TOKENS_PER_DAY": "40",
+		"USAGE_DIR":                 t.TempDir(),
+	}
+	url := newTestServer(t, env).URL
+	if status := keyRequest(t, "", "POST", url+"/v1/chat/completions", chatBody(strings.Repeat("word ", 50), false), nil); status != http.StatusOK {
+		t.Fatalf("first request status = %d", status)
+	}
+
+	// A restarted server counts the tokens already used today
+	url = newTestServer(t, env).URL
+	var out models.ErrorResponse
+	if status := keyRequest(t, "", "POST", url+"/v1/chat/completions", chatBody("hello", false), &out); status != http.StatusTooManyRequests || out.Error.Code != "token_limit_exceeded" {
+		t.Fatalf("after restart = %+v (%d)", out.Error, status)
+	}
+
+	// Batch requests wait for the budget to reset rather than fail
+	f, _ := uploadFile(t, url, "batch", "input.jsonl", batchLine("a", "hello"))
+	b := createBatch(t, url, f.ID)
+	time.Sleep(time.Second)
+	resp, err := http.Get(url + "/v1/batches/" + b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var cur batches.Batch
+	json.NewDecoder(resp.Body).Decode(&cur)
+	if cur.Status != batches.StatusInProgress || cur.RequestCounts.Failed != 0 || cur.RequestCounts.Completed != 0 {
+		t.Errorf("batch over the token limit = %+v", cur)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/cache"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+	"claude-code-api/internal/ratelimit"
+	"claude-code-api/internal/streaming"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Values of the X-Cache response header.
+const (
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+)
+
+// cacheKey derives the response cache key from the parts of a request that
+// affect the completion, including the project it is asked about. Message
+// text is whitespace-normalized so trivially different renderings of the
+// same prompt share an entry.
+func cacheKey(req *models.ChatCompletionRequest, model string, project projects.Ref) string {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	key := struct {
+		Model        string    `json:"model"`
+		Namespace    string    `json:"namespace"`
+		Project      string    `json:"project"`
+		SystemPrompt string    `json:"system_prompt,omitempty"`
+		Messages     []message `json:"messages"`
+		Temperature  *float64  `json:"temperature,omitempty"`
+		TopP         *float64  `json:"top_p,omitempty"`
+		MaxTokens    *int      `json:"max_tokens,omitempty"`
+		Stop         any       `json:"stop,omitempty"`
+	}{
+		Model:        model,
+		Namespace:    project.Namespace,
+		Project:      project.ID,
+		SystemPrompt: normalizeText(req.SystemPrompt),
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		MaxTokens:    req.MaxTokens,
+		Stop:         req.Stop,
+	}
+	for _, m := range req.Messages {
+		key.Messages = append(key.Messages, message{Role: m.Role, Content: normalizeText(m.GetTextContent())})
+	}
+
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cacheDirectives reports whether the client allows serving from and storing
+// into the cache, per its Cache-Control header.
+func cacheDirectives(c *gin.Context) (lookup, store bool) {
+	lookup, store = true, true
+	for _, d := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "no-cache":
+			lookup = false
+		case "no-store":
+			lookup, store = false, false
+		}
+	}
+	return lookup, store
+}
+
+// chargeCacheHit passes a request answered from the cache through the run
+// hooks, so it counts against the caller's rate limits and is recorded in
+// usage like a run. The cached tokens are charged; the cost is not, as no
+// model ran.
+func chargeCacheHit(c *gin.Context, backends *backend.Registry, model, prompt string, project projects.Ref, entry cache.Entry) *sessionError {
+	usage := entry.Usage
+	usage.CostUSD, usage.DurationMs = 0, 0
+	err := backends.Charge(backend.Request{
+		RequestID: requestID(c),
+		Principal: principal(c),
+		Namespace: project.Namespace,
+		ProjectID: project.ID,
+		Model:     model,
+		Prompt:    prompt,
+		ReadOnly:  true,
+	}, backend.Result{SessionID: entry.SessionID, Usage: usage})
+
+	var limited *ratelimit.LimitError
+	if errors.As(err, &limited) {
+		c.Header("Retry-After", retryAfter(limited.RetryAfter))
+		return rateLimitError(err)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to charge cached response")
+	}
+	return nil
+}
+
+// writeCachedResponse replays a cached entry in the requested format.
+func writeCachedResponse(c *gin.Context, entry cache.Entry, model, projectID string, stream bool) {
+	if !stream {
+		c.JSON(200, completionResponse(model, entry.Content, entry.Usage, entry.SessionID, projectID))
+		return
+	}
+
+	setStreamingHeaders(c, entry.SessionID, projectID)
+
+	formatter := &streaming.SSEFormatter{}
+	converter := streaming.NewConverter(model, entry.SessionID)
+
+	c.Writer.WriteString(formatter.FormatEvent(converter.CreateInitialChunk()))
+	c.Writer.WriteString(formatter.FormatEvent(converter.CreateContentChunk(entry.Content)))
+	c.Writer.WriteString(formatter.FormatEvent(converter.CreateFinalChunk()))
+	c.Writer.WriteString(formatter.FormatDone())
+	c.Writer.Flush()
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func postChatWithHeaders(t *testing.T, url, body string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func readOnlyBody(prompt string, stream bool) string {
+	data, _ := json.Marshal(models.ChatCompletionRequest{
+		Model:    "claude-sonnet-4-5-20250929",
+		Messages: []models.ChatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+		ReadOnly: true,
+	})
+	return string(data)
+}
+
+func TestResponseCache(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"RESPONSE_CACHE_ENABLED": "true"})
+
+	first := postChatWithHeaders(t, srv.URL, readOnlyBody("Generate a title", false), nil)
+	if got := first.Header.Get("X-Cache"); got != cacheMiss {
+		t.Fatalf("first X-Cache = %q, want MISS", got)
+	}
+	var miss models.ChatCompletionResponse
+	_ = json.NewDecoder(first.Body).Decode(&miss)
+
+	// Whitespace differences normalize to the same key
+	second := postChatWithHeaders(t, srv.URL, readOnlyBody("  Generate   a title ", false), nil)
+	if got := second.Header.Get("X-Cache"); got != cacheHit {
+		t.Fatalf("second X-Cache = %q, want HIT", got)
+	}
+	var hit models.ChatCompletionResponse
+	_ = json.NewDecoder(second.Body).Decode(&hit)
+	if hit.Choices[0].Message.Content != miss.Choices[0].Message.Content || hit.SessionID != miss.SessionID {
+		t.Errorf("cached response differs: %+v vs %+v", hit, miss)
+	}
+
+	// A cached entry replays as a stream
+	streamed := postChatWithHeaders(t, srv.URL, readOnlyBody("Generate a title", true), nil)
+	if got := streamed.Header.Get("X-Cache"); got != cacheHit {
+		t.Fatalf("streamed X-Cache = %q, want HIT", got)
+	}
+	events := readSSE(t, streamed)
+	if len(events) != 4 || !strings.Contains(events[1], "You said: Generate a title") || events[3] != "[DONE]" {
+		t.Errorf("unexpected cached stream: %v", events)
+	}
+
+	bypass := postChatWithHeaders(t, srv.URL, readOnlyBody("Generate a title", false), map[string]string{"Cache-Control": "no-cache"})
+	if got := bypass.Header.Get("X-Cache"); got != cacheBypass {
+		t.Errorf("no-cache X-Cache = %q, want BYPASS", got)
+	}
+
+	agentic := postChatWithHeaders(t, srv.URL, chatBody("Generate a title", false), nil)
+	if got := agentic.Header.Get("X-Cache"); got != cacheBypass {
+		t.Errorf("non read-only X-Cache = %q, want BYPASS", got)
+	}
+}
+
+func TestResponseCacheScopedToProject(t *testing.T) {
+	url := newTestServer(t, map[string]string{
+		"RESPONSE_CACHE_ENABLED": "true",
+		"REQUIRE_AUTH":           "true",
+		"API_KEYS":               "alice,bob",
+	}).URL
+	ask := func(key, project string) string {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"model":      "claude-sonnet-4-5-20250929",
+			"project_id": project,
+			"read_only":  true,
+			"messages":   []map[string]string{{"role": "user", "content": "What does this project do?"}},
+		})
+		resp := limitedRequest(t, key, "POST", url+"/v1/chat/completions", string(body))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s status = %d", key, project, resp.StatusCode)
+		}
+		return resp.Header.Get("X-Cache")
+	}
+
+	if got := ask("alice", "app"); got != cacheMiss {
+		t.Fatalf("first X-Cache = %q, want MISS", got)
+	}
+	// The same question about another project, or another tenant's project
+	// of the same name, is not answered from the cache
+	if got := ask("alice", "other"); got != cacheMiss {
+		t.Errorf("other project X-Cache = %q, want MISS", got)
+	}
+	if got := ask("bob", "app"); got != cacheMiss {
+		t.Errorf("other tenant X-Cache = %q, want MISS", got)
+	}
+	if got := ask("alice", "app"); got != cacheHit {
+		t.Errorf("repeat X-Cache = %q, want HIT", got)
+	}
+}
+
+func TestResponseCacheHitsAreCharged(t *testing.T) {
+	url := newTestServer(t, map[string]string{
+		"RESPONSE_CACHE_ENABLED":    "true",
+		"REQUIRE_AUTH":              "true",
+		"API_KEYS":                  "root",
+		"RATE_LIMIT_TOKENS_PER_DAY": "100",
+	}).URL
+	question := readOnlyBody(strings.Repeat("word ", 30), false)
+
+	resp := limitedRequest(t, "root", "POST", url+"/v1/chat/completions", question)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != cacheMiss {
+		t.Fatalf("first = %d %q", resp.StatusCode, resp.Header.Get("X-Cache"))
+	}
+
+	// Keys not allowed the model are refused before the cache is consulted
+	var created models.CreateKeyResponse
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", `{"name": "opus", "models": ["claude-opus-4-1-20250805"]}`, &created); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, created.Secret, "POST", url+"/v1/chat/completions", question, &out); status != http.StatusForbidden || out.Error.Code != "model_not_allowed" {
+		t.Errorf("other model = %+v (%d)", out.Error, status)
+	}
+
+	// The hit is recorded, with the cached tokens but no cost, and counts
+	// against the daily token budget
+	resp = limitedRequest(t, "root", "POST", url+"/v1/chat/completions", question)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != cacheHit {
+		t.Fatalf("second = %d %q", resp.StatusCode, resp.Header.Get("X-Cache"))
+	}
+	report := getUsage(t, "root", url, "")
+	if report.Total.Requests != 2 || report.Total.InputTokens < 60 {
+		t.Errorf("usage = %+v", report.Total)
+	}
+	if status := keyRequest(t, "root", "POST", url+"/v1/chat/completions", question, &out); status != http.StatusTooManyRequests || out.Error.Code != "token_limit_exceeded" {
+		t.Errorf("over budget = %+v (%d)", out.Error, status)
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/streaming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxStoredResponses bounds the in-memory response store.
+const maxStoredResponses = 1000
+
+// storedResponse is a response kept for retrieval and continuation by
+// callers in the namespace that created it.
+type storedResponse struct {
+	response  models.Response
+	sessionID string
+	projectID string
+	namespace string
+}
+
+// responseStore keeps recent responses in memory, evicting the oldest first.
+type responseStore struct {
+	mu    sync.Mutex
+	items map[string]storedResponse
+	order []string
+}
+
+func newResponseStore() *responseStore {
+	return &responseStore{items: make(map[string]storedResponse)}
+}
+
+// get returns response id if it was created in namespace ns.
+func (s *responseStore) get(ns, id string) (storedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.items[id]
+	if !ok || r.namespace != ns {
+		return storedResponse{}, false
+	}
+	return r, ok
+}
+
+func (s *responseStore) put(r storedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[r.response.ID]; !ok {
+		s.order = append(s.order, r.response.ID)
+	}
+	s.items[r.response.ID] = r
+
+	for len(s.order) > maxStoredResponses {
+		delete(s.items, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// ResponsesHandler handles OpenAI Responses API requests.
+type ResponsesHandler struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+	store    *responseStore
+}
+
+// NewResponsesHandler creates a new responses handler.
+func NewResponsesHandler(cfg *config.Reloader, backends *backend.Registry) *ResponsesHandler {
+	return &ResponsesHandler{cfg: cfg, backends: backends, store: newResponseStore()}
+}
+
+func responseUsage(u backend.Usage) *models.ResponseUsage {
+	usage := &models.ResponseUsage{
+		InputTokens:  u.PromptTokens(),
+		OutputTokens: u.OutputTokens,
+		TotalTokens:  u.PromptTokens() + u.OutputTokens,
+	}
+	usage.InputTokensDetails.CachedTokens = u.CacheReadInputTokens
+	return usage
+}
+
+// splitResponseInput derives the prompt and system prompt from the input
+// items. The CLI continues the conversation itself, so only the items after
+// the last assistant turn form the prompt.
+func splitResponseInput(items models.ResponseInput) (prompt, system string) {
+	var promptParts, systemParts []string
+	for _, item := range items {
+		switch {
+		case item.Role == "system" || item.Role == "developer":
+			systemParts = append(systemParts, item.Text())
+		case item.Role == "assistant":
+			promptParts = nil
+		case item.Role == "user" || item.Type == "function_call_output":
+			if text := item.Text(); text != "" {
+				promptParts = append(promptParts, text)
+			}
+		}
+	}
+	return strings.Join(promptParts, "\n\n"), strings.Join(systemParts, "\n\n")
+}
+
+// HandleCreateResponse handles POST /v1/responses
+func (h *ResponsesHandler) HandleCreateResponse(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	var req models.CreateResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	prompt, systemPrompt := splitResponseInput(req.Input)
+	if prompt == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: "Input must contain at least one user message",
+				Type:    "invalid_request_error",
+				Code:    "missing_user_message",
+			},
+		})
+		return
+	}
+	if req.Instructions != "" {
+		systemPrompt = req.Instructions
+	}
+
+	// Continue the Claude session of the previous response
+	projectID := req.ProjectID
+	var resumeSessionID string
+	if req.PreviousResponseID != "" {
+		prev, ok := h.store.get(namespace(c), req.PreviousResponseID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Message: fmt.Sprintf("Previous response with id '%s' not found.", req.PreviousResponseID),
+					Type:    "invalid_request_error",
+					Code:    "previous_response_not_found",
+				},
+			})
+			return
+		}
+		resumeSessionID = prev.sessionID
+		if projectID == "" {
+			projectID = prev.projectID
+		}
+	}
+	if projectID == "" {
+		projectID = defaultProjectID
+	}
+
+	run, cancel, serr := startSession(c, cfg, h.backends, backend.Request{
+		Model:           model,
+		Prompt:          prompt,
+		SystemPrompt:    systemPrompt,
+		ProjectID:       projectID,
+		ResumeSessionID: resumeSessionID,
+	})
+	if serr != nil {
+		c.JSON(serr.Status, serr.openAIError())
+		return
+	}
+	defer cancel()
+
+	resp := &models.Response{
+		ID:        streaming.NewResponseID(),
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Status:    "in_progress",
+		Model:     model,
+		Output:    []models.ResponseOutputItem{},
+		Metadata:  req.Metadata,
+		ProjectID: projectID,
+	}
+	if resp.Metadata == nil {
+		resp.Metadata = map[string]string{}
+	}
+	if req.Instructions != "" {
+		resp.Instructions = &req.Instructions
+	}
+	if req.PreviousResponseID != "" {
+		resp.PreviousResponseID = &req.PreviousResponseID
+	}
+
+	if req.Stream {
+		h.handleStreamingResponse(c, run, resp)
+	} else {
+		h.handleNonStreamingResponse(c, run, resp)
+	}
+
+	if req.Store == nil || *req.Store {
+		h.store.put(storedResponse{response: *resp, sessionID: resp.SessionID, projectID: projectID, namespace: namespace(c)})
+	}
+}
+
+func (h *ResponsesHandler) handleStreamingResponse(c *gin.Context, run backend.Run, resp *models.Response) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Project-ID", resp.ProjectID)
+
+	encoder := streaming.NewResponsesEncoder(resp)
+	c.Writer.WriteString(encoder.Created())
+	c.Writer.Flush()
+
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			resp.SessionID = ev.SessionID
+		}
+		switch ev.Type {
+		case backend.EventText:
+			c.Writer.WriteString(encoder.TextDelta(ev.Text))
+			c.Writer.Flush()
+		case backend.EventError:
+			c.Writer.WriteString(encoder.Failed("server_error", ev.Error))
+			c.Writer.Flush()
+			return
+		}
+	}
+
+	c.Writer.WriteString(encoder.Completed(responseUsage(run.Usage())))
+	c.Writer.Flush()
+}
+
+func (h *ResponsesHandler) handleNonStreamingResponse(c *gin.Context, run backend.Run, resp *models.Response) {
+	var parts []string
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			resp.SessionID = ev.SessionID
+		}
+		switch ev.Type {
+		case backend.EventText:
+			parts = append(parts, ev.Text)
+		case backend.EventError:
+			resp.Status = "failed"
+			resp.Error = &models.ResponseError{Code: "server_error", Message: ev.Error}
+			c.JSON(http.StatusBadGateway, models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Message: ev.Error,
+					Type:    "api_error",
+					Code:    "claude_error",
+				},
+			})
+			return
+		}
+	}
+
+	if len(parts) > 0 {
+		resp.Output = []models.ResponseOutputItem{
+			streaming.OutputMessage(streaming.NewOutputItemID(), strings.Join(parts, "\n")),
+		}
+	}
+	resp.Status = "completed"
+	resp.Usage = responseUsage(run.Usage())
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleGetResponse handles GET /v1/responses/:response_id
+func (h *ResponsesHandler) HandleGetResponse(c *gin.Context) {
+	id := c.Param("response_id")
+	stored, ok := h.store.get(namespace(c), id)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Response with id '%s' not found.", id),
+				Type:    "invalid_request_error",
+				Code:    "response_not_found",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, stored.response)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func postResponses(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/responses", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestResponsesContinuation(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postResponses(t, srv.URL, `{"model":"claude-sonnet-4-5-20250929","input":"first turn","instructions":"be brief"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var first models.Response
+	if err := json.NewDecoder(resp.Body).Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "completed" || len(first.Output) != 1 || first.Output[0].Content[0].Text != "You said: first turn" {
+		t.Fatalf("unexpected response: %+v", first)
+	}
+
+	// previous_response_id resumes the same Claude session
+	resp = postResponses(t, srv.URL, `{
+		"model": "claude-sonnet-4-5-20250929",
+		"previous_response_id": "`+first.ID+`",
+		"input": [{"role": "user", "content": [{"type": "input_text", "text": "second turn"}]}]
+	}`)
+	var second models.Response
+	if err := json.NewDecoder(resp.Body).Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if second.SessionID != first.SessionID {
+		t.Errorf("session_id = %q, want resumed %q", second.SessionID, first.SessionID)
+	}
+	if second.PreviousResponseID == nil || *second.PreviousResponseID != first.ID {
+		t.Errorf("previous_response_id = %v", second.PreviousResponseID)
+	}
+
+	get, err := http.Get(srv.URL + "/v1/responses/" + second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer get.Body.Close()
+	var fetched models.Response
+	_ = json.NewDecoder(get.Body).Decode(&fetched)
+	if fetched.ID != second.ID || fetched.Status != "completed" {
+		t.Errorf("GET returned %+v", fetched)
+	}
+
+	missing := postResponses(t, srv.URL, `{"input":"hi","previous_response_id":"resp_missing"}`)
+	if missing.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown previous_response_id status = %d, want 400", missing.StatusCode)
+	}
+}
+
+func TestResponsesStreaming(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp := postResponses(t, srv.URL, `{"input":"[scenario:tools] look","stream":true}`)
+
+	var names []string
+	var completed models.Response
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && strings.Contains(data, `"response.completed"`) {
+			var ev struct {
+				Response models.Response `json:"response"`
+			}
+			_ = json.Unmarshal([]byte(data), &ev)
+			completed = ev.Response
+		}
+	}
+
+	want := []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added",
+		"response.output_text.delta", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v\nwant     %v", names, want)
+	}
+	if got := completed.Output[0].Content[0].Text; got != "Let me look at the project.\nThe project has two files." {
+		t.Errorf("completed text = %q", got)
+	}
+	if completed.Usage == nil || completed.Usage.InputTokensDetails.CachedTokens != 100 {
+		t.Errorf("usage = %+v", completed.Usage)
+	}
+}
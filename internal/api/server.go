@@ -0,0 +1,271 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/batches"
+	"claude-code-api/internal/cache"
+	"claude-code-api/internal/claude"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/files"
+	"claude-code-api/internal/jwtauth"
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+	"claude-code-api/internal/ratelimit"
+	"claude-code-api/internal/tasks"
+	"claude-code-api/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server is the gateway: the backends and the hooks every run passes
+// through, the stores and managers behind the endpoints, and the router
+// serving them.
+type Server struct {
+	// Backends runs sessions for every endpoint
+	Backends *backend.Registry
+	// Router serves the API
+	Router *gin.Engine
+
+	tasks     *tasks.Manager
+	batches   *batches.Manager
+	collector *projects.Collector
+}
+
+// NewServer wires the gateway for the live configuration. Call Shutdown
+// once the router no longer serves requests.
+func NewServer(live *config.Reloader, version string) (*Server, error) {
+	cfg := live.Get()
+
+	// Backends, and the hooks in the order runs pass through them
+	backends := backend.NewRegistry(live)
+	backends.Register(claude.NewManager(live))
+	backends.Register(backend.NewEcho())
+	limiter := ratelimit.New(live)
+	backends.Use(limiter)
+	backends.Use(projects.NewCheckpointer(live))
+	backends.Use(projects.NewChangeTracker(live))
+	projectManager := projects.NewManager(live)
+	quota := projects.NewQuotaGuard(projectManager)
+	backends.Use(quota)
+	backends.Use(projects.NewSnapshotter(projectManager))
+	backends.Use(projects.NewSessionGuard())
+	usageStore, err := usage.NewStore(cfg.UsageDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage store: %w", err)
+	}
+	if err := limiter.Restore(usageStore); err != nil {
+		return nil, fmt.Errorf("failed to restore token budgets: %w", err)
+	}
+	backends.Use(usage.NewRecorder(usageStore))
+
+	// Open the key store
+	var keyStore *keys.Store
+	if cfg.KeysFile != "" {
+		keyStore, err = keys.Open(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key store: %w", err)
+		}
+	}
+
+	// Load the identity provider's signing keys
+	var verifier *jwtauth.Verifier
+	if cfg.AcceptsJWT() {
+		verifier, err = jwtauth.New(jwtOptions(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWKS: %w", err)
+		}
+	}
+
+	fileStore, err := files.NewStore(cfg.FilesDir, func() string { return live.Get().ProjectRoot })
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file store: %w", err)
+	}
+	taskManager, err := tasks.NewManager(live, backends)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start task manager: %w", err)
+	}
+	batchManager, err := batches.NewManager(live, fileStore, NewBatchExecutor(live, backends, keyStore))
+	if err != nil {
+		taskManager.Shutdown()
+		return nil, fmt.Errorf("failed to start batch manager: %w", err)
+	}
+	collector := projects.NewCollector(projectManager, fileStore.ForgetProject)
+
+	s := &Server{
+		Backends:  backends,
+		Router:    gin.New(),
+		tasks:     taskManager,
+		batches:   batchManager,
+		collector: collector,
+	}
+
+	router := s.Router
+	router.Use(gin.Recovery())
+	router.Use(RequestIDMiddleware())
+	router.Use(LoggingMiddleware())
+	router.Use(CORSMiddleware(live))
+	router.Use(AuthMiddleware(live, keyStore, verifier))
+
+	// Create handlers
+	responses := cache.New(
+		time.Duration(cfg.ResponseCacheTTLSecs)*time.Second,
+		cfg.ResponseCacheMaxEntries,
+		cfg.ResponseCacheMaxBytes,
+	)
+	chatHandler := NewChatHandler(live, backends, responses)
+	modelsHandler := NewModelsHandler(live, backends)
+	messagesHandler := NewMessagesHandler(live, backends)
+	responsesHandler := NewResponsesHandler(live, backends)
+	completionsHandler := NewCompletionsHandler(live, backends)
+	ollamaHandler := NewOllamaHandler(live, backends)
+	wsHandler := NewWebSocketHandler(live, backends)
+	tasksHandler := NewTasksHandler(live, taskManager)
+	filesHandler := NewFilesHandler(live, fileStore, quota)
+	batchesHandler := NewBatchesHandler(batchManager)
+	projectsHandler := NewProjectsHandler(live, projectManager, fileStore)
+	storageHandler := NewStorageHandler(projectManager, collector)
+	keysHandler := NewKeysHandler(keyStore)
+	usageHandler := NewUsageHandler(usageStore, keyStore)
+
+	// Root endpoint
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"name":        "Claude Code API Gateway",
+			"version":     version,
+			"description": "OpenAI-compatible API for Claude Code",
+			"endpoints": gin.H{
+				"chat":        "/v1/chat/completions",
+				"messages":    "/v1/messages",
+				"responses":   "/v1/responses",
+				"completions": "/v1/completions",
+				"sessions":    "/v1/sessions/ws",
+				"tasks":       "/v1/tasks",
+				"batches":     "/v1/batches",
+				"files":       "/v1/files",
+				"projects":    "/v1/projects",
+				"storage":     "/v1/storage",
+				"models":      "/v1/models",
+				"usage":       "/v1/usage",
+				"keys":        "/v1/admin/keys",
+				"ollama":      "/api",
+			},
+			"docs":   "/docs",
+			"health": "/health",
+		})
+	})
+
+	// Health check
+	router.GET("/health", func(c *gin.Context) {
+		b, err := backends.Default()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.HealthCheckResponse{
+				Status:  "unhealthy",
+				Version: version,
+			})
+			return
+		}
+		cv, err := b.GetVersion()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.HealthCheckResponse{
+				Status:  "unhealthy",
+				Version: version,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.HealthCheckResponse{
+			Status:         "healthy",
+			Version:        version,
+			ClaudeVersion:  cv,
+			ActiveSessions: backends.ActiveSessionCount(),
+		})
+	})
+
+	// API routes, each limited to keys with the matching scope. Only the
+	// routes that start runs count against a key's requests per minute, so
+	// polling tasks and batches does not use them up
+	limit := RateLimitMiddleware(limiter)
+	v1 := router.Group("/v1")
+	{
+		chat := v1.Group("", RequireScope(keys.ScopeChat))
+		chat.POST("/chat/completions", limit, chatHandler.HandleChatCompletion)
+		chat.POST("/messages", limit, messagesHandler.HandleMessages)
+		chat.POST("/responses", limit, responsesHandler.HandleCreateResponse)
+		chat.GET("/responses/:response_id", responsesHandler.HandleGetResponse)
+		chat.POST("/completions", limit, completionsHandler.HandleCompletion)
+		chat.GET("/sessions/ws", limit, wsHandler.HandleSession)
+		chat.POST("/tasks", limit, tasksHandler.HandleCreateTask)
+		chat.GET("/tasks/:task_id", tasksHandler.HandleGetTask)
+		chat.GET("/tasks/:task_id/events", tasksHandler.HandleTaskEvents)
+		chat.POST("/tasks/:task_id/cancel", tasksHandler.HandleCancelTask)
+		chat.POST("/batches", limit, batchesHandler.HandleCreateBatch)
+		chat.GET("/batches", batchesHandler.HandleListBatches)
+		chat.GET("/batches/:batch_id", batchesHandler.HandleGetBatch)
+		chat.POST("/batches/:batch_id/cancel", batchesHandler.HandleCancelBatch)
+
+		// Files serve both batches and projects
+		filesGroup := v1.Group("", RequireScope(keys.ScopeChat, keys.ScopeProjects))
+		filesGroup.POST("/files", filesHandler.HandleUploadFile)
+		filesGroup.GET("/files", filesHandler.HandleListFiles)
+		filesGroup.GET("/files/:file_id", filesHandler.HandleGetFile)
+		filesGroup.DELETE("/files/:file_id", filesHandler.HandleDeleteFile)
+		filesGroup.GET("/files/:file_id/content", filesHandler.HandleFileContent)
+
+		proj := v1.Group("", RequireScope(keys.ScopeProjects))
+		proj.POST("/projects", projectsHandler.HandleCreateProject)
+		proj.GET("/projects", projectsHandler.HandleListProjects)
+		proj.GET("/projects/:project_id", projectsHandler.HandleGetProject)
+		proj.DELETE("/projects/:project_id", projectsHandler.HandleDeleteProject)
+		proj.POST("/projects/:project_id/rename", projectsHandler.HandleRenameProject)
+		proj.GET("/projects/:project_id/tree", projectsHandler.HandleProjectTree)
+		proj.GET("/projects/:project_id/files/*path", projectsHandler.HandleProjectFile)
+		proj.GET("/projects/:project_id/archive", projectsHandler.HandleProjectArchive)
+		proj.GET("/projects/:project_id/commits", projectsHandler.HandleProjectCommits)
+		proj.GET("/projects/:project_id/snapshots", projectsHandler.HandleListSnapshots)
+		proj.POST("/projects/:project_id/snapshots", projectsHandler.HandleCreateSnapshot)
+		proj.POST("/projects/:project_id/snapshots/:snapshot_id/restore", projectsHandler.HandleRestoreSnapshot)
+		proj.DELETE("/projects/:project_id/snapshots/:snapshot_id", projectsHandler.HandleDeleteSnapshot)
+		proj.GET("/storage", storageHandler.HandleStorageUsage)
+
+		modelsGroup := v1.Group("", RequireScope(keys.ScopeModels))
+		modelsGroup.GET("/models", modelsHandler.HandleListModels)
+		modelsGroup.GET("/models/capabilities", modelsHandler.HandleModelCapabilities)
+		modelsGroup.GET("/models/:model_id", modelsHandler.HandleGetModel)
+
+		// Chat keys see their own usage, admin keys everyone's
+		v1.GET("/usage", RequireScope(keys.ScopeChat, keys.ScopeAdmin), usageHandler.HandleUsage)
+
+		admin := v1.Group("", RequireScope(keys.ScopeAdmin))
+		admin.POST("/storage/gc", storageHandler.HandleCollectGarbage)
+		admin.POST("/admin/keys", keysHandler.HandleCreateKey)
+		admin.GET("/admin/keys", keysHandler.HandleListKeys)
+		admin.GET("/admin/keys/:key_id", keysHandler.HandleGetKey)
+		admin.POST("/admin/keys/:key_id/revoke", keysHandler.HandleRevokeKey)
+		admin.DELETE("/admin/keys/:key_id", keysHandler.HandleDeleteKey)
+	}
+
+	// Ollama-compatible routes
+	ollama := router.Group("/api")
+	{
+		ollama.POST("/chat", RequireScope(keys.ScopeChat), limit, ollamaHandler.HandleChat)
+		ollama.POST("/generate", RequireScope(keys.ScopeChat), limit, ollamaHandler.HandleGenerate)
+		ollama.GET("/tags", RequireScope(keys.ScopeModels), ollamaHandler.HandleTags)
+		ollama.POST("/show", RequireScope(keys.ScopeModels), ollamaHandler.HandleShow)
+	}
+
+	return s, nil
+}
+
+// Shutdown stops the background work of tasks, batches and garbage
+// collection and ends every session.
+func (s *Server) Shutdown() {
+	s.collector.Shutdown()
+	s.batches.Shutdown()
+	s.tasks.Shutdown()
+	s.Backends.CleanupAll()
+}
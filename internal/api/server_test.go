@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"claude-code-api/internal/models"
+)
+
+func TestServerRoutes(t *testing.T) {
+	url := newTestServer(t, nil).URL
+
+	var health models.HealthCheckResponse
+	if status := keyRequest(t, "", "GET", url+"/health", "", &health); status != http.StatusOK || health.Status != "healthy" || health.Version != "test" {
+		t.Errorf("health = %+v (%d)", health, status)
+	}
+
+	var root struct {
+		Version   string            `json:"version"`
+		Endpoints map[string]string `json:"endpoints"`
+	}
+	if status := keyRequest(t, "", "GET", url+"/", "", &root); status != http.StatusOK || root.Endpoints["chat"] != "/v1/chat/completions" {
+		t.Errorf("root = %+v (%d)", root, status)
+	}
+
+	var caps struct {
+		Models []map[string]interface{} `json:"models"`
+		Total  int                      `json:"total"`
+	}
+	if status := keyRequest(t, "", "GET", url+"/v1/models/capabilities", "", &caps); status != http.StatusOK || caps.Total == 0 || caps.Models[0]["backend"] != "claude-cli" {
+		t.Errorf("capabilities = %+v (%d)", caps, status)
+	}
+
+	// CORS applies to every route
+	req, _ := http.NewRequest("OPTIONS", url+"/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("preflight headers = %v", resp.Header)
+	}
+
+	if status := keyRequest(t, "", "GET", url+"/v1/unknown", "", nil); status != http.StatusNotFound {
+		t.Errorf("unknown route status = %d", status)
+	}
+}
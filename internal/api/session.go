@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+	"claude-code-api/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultProjectID is used when a request does not name a project.
+const defaultProjectID = "default"
+
+// sessionError describes why a session could not be started, independent of
+// the wire format the calling endpoint reports errors in.
+type sessionError struct {
+	Status  int
+	Type    string
+	Code    string
+	Message string
+}
+
+// openAIError renders the error in OpenAI format.
+func (e *sessionError) openAIError() models.ErrorResponse {
+	return models.ErrorResponse{
+		Error: models.ErrorDetail{Message: e.Message, Type: e.Type, Code: e.Code},
+	}
+}
+
+// quotaError reports a run refused for exceeding a disk quota, or nil for
+// any other error.
+func quotaError(err error) *sessionError {
+	code := ""
+	switch {
+	case errors.Is(err, projects.ErrProjectQuota):
+		code = "project_quota_exceeded"
+	case errors.Is(err, projects.ErrDiskQuota):
+		code = "disk_quota_exceeded"
+	default:
+		return nil
+	}
+	return &sessionError{
+		Status:  http.StatusInsufficientStorage,
+		Type:    "insufficient_quota",
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+// prepareRequest resolves the project in the caller's namespace, creates
+// the project directory on first use and marks it used. Invalid project IDs
+// and projects out of the caller's reach are rejected. On return ProjectID
+// and Namespace name the project the request runs in.
+func prepareRequest(cfg *config.Config, req *backend.Request) *sessionError {
+	if req.ProjectID == "" {
+		req.ProjectID = defaultProjectID
+	}
+	ref, dir, serr := resolveProject(cfg, req.Namespace, req.ProjectID, !req.ReadOnly)
+	if serr != nil {
+		return serr
+	}
+	req.Namespace, req.ProjectID = ref.Namespace, ref.ID
+	if _, err := projects.Ensure(cfg, req.Namespace, req.ProjectID, req.Principal); err != nil {
+		log.Error().Err(err).Str("project_id", req.ProjectID).Msg("Failed to initialize project")
+	}
+	req.ProjectPath = dir
+	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
+		log.Error().Err(err).Msg("Failed to create project directory")
+	}
+	// The directory's mtime records when the project was last used
+	now := time.Now()
+	os.Chtimes(req.ProjectPath, now, now)
+	return nil
+}
+
+// startSession prepares the project directory, selects the backend for the
+// request's model and starts a run bounded by the streaming timeout. The
+// returned cancel func must be called once the caller is done with the run.
+func startSession(c *gin.Context, cfg *config.Config, backends *backend.Registry, req backend.Request) (backend.Run, context.CancelFunc, *sessionError) {
+	if req.RequestID == "" {
+		req.RequestID = requestID(c)
+	}
+	if req.Principal == "" {
+		req.Principal = principal(c)
+	}
+	if req.Namespace == "" {
+		req.Namespace = namespace(c)
+	}
+	if serr := modelError(c, req.Model); serr != nil {
+		return nil, nil, serr
+	}
+	if serr := prepareRequest(cfg, &req); serr != nil {
+		return nil, nil, serr
+	}
+
+	b, err := backends.ForModel(req.Model)
+	if err != nil {
+		return nil, nil, &sessionError{
+			Status:  http.StatusBadRequest,
+			Type:    "invalid_request_error",
+			Code:    "model_not_available",
+			Message: err.Error(),
+		}
+	}
+
+	// Create Claude session
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(cfg.StreamingTimeoutSecs)*time.Second)
+
+	run, err := b.CreateSession(ctx, req)
+	var limited *ratelimit.LimitError
+	if errors.As(err, &limited) {
+		cancel()
+		c.Header("Retry-After", retryAfter(limited.RetryAfter))
+		return nil, nil, rateLimitError(err)
+	}
+	if serr := quotaError(err); serr != nil {
+		cancel()
+		return nil, nil, serr
+	}
+	if errors.Is(err, projects.ErrSessionNotFound) {
+		cancel()
+		return nil, nil, &sessionError{
+			Status:  http.StatusNotFound,
+			Type:    "invalid_request_error",
+			Code:    "session_not_found",
+			Message: fmt.Sprintf("Session '%s' not found in project '%s'", req.ResumeSessionID, req.ProjectID),
+		}
+	}
+	if err != nil {
+		cancel()
+		log.Error().Err(err).Msg("Failed to create Claude session")
+		return nil, nil, &sessionError{
+			Status:  http.StatusServiceUnavailable,
+			Type:    "service_unavailable",
+			Code:    "claude_unavailable",
+			Message: fmt.Sprintf("Failed to start Claude: %v", err),
+		}
+	}
+
+	// Handlers may stop reading before the run's last event, as at a stop
+	// sequence; cancelling the run too lets its hooks finish regardless
+	stop := func() {
+		run.Cancel()
+		cancel()
+	}
+	return run, stop, nil
+}
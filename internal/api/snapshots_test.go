@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+)
+
+func listSnapshots(t *testing.T, url string) []projects.ProjectSnapshot {
+	t.Helper()
+	var list struct {
+		Data []projects.ProjectSnapshot `json:"data"`
+	}
+	if status := projectRequest(t, "GET", url+"/v1/projects/snap/snapshots", "", &list); status != http.StatusOK {
+		t.Fatalf("snapshots status = %d", status)
+	}
+	return list.Data
+}
+
+func TestProjectSnapshots(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":       root,
+		"PROJECT_SNAPSHOTS":  "true",
+		"SNAPSHOT_RETENTION": "3",
+	})
+
+	dir := filepath.Join(root, "snap")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("original\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("untouched\n"), 0o644)
+
+	chatInProject(t, srv.URL, "snap", "req-1", "[scenario:edit] rewrite")
+	// The edit changed the project, so the next session snapshots again;
+	// the one after has nothing new to save
+	chatInProject(t, srv.URL, "snap", "req-2", "hello")
+	chatInProject(t, srv.URL, "snap", "req-3", "hello")
+
+	list := listSnapshots(t, srv.URL)
+	if len(list) != 2 {
+		t.Fatalf("snapshots = %+v", list)
+	}
+	after, before := list[0], list[1]
+	if before.RequestID != "req-1" || before.Reason != projects.ReasonSession || before.FileCount != 2 || before.ProjectID != "snap" {
+		t.Errorf("first snapshot = %+v", before)
+	}
+	if after.RequestID != "req-2" || after.FileCount != 3 {
+		t.Errorf("second snapshot = %+v", after)
+	}
+
+	// Unchanged files are shared between snapshots
+	a, err := os.Stat(filepath.Join(root, ".snapshots", "snap", before.ID, "tree", "keep.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(root, ".snapshots", "snap", after.ID, "tree", "keep.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(a, b) {
+		t.Error("unchanged file was copied instead of linked")
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("broken\n"), 0o644)
+	var restored struct {
+		Project  projects.Project         `json:"project"`
+		Snapshot projects.ProjectSnapshot `json:"snapshot"`
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots/"+before.ID+"/restore", "", &restored); status != http.StatusOK {
+		t.Fatalf("restore status = %d", status)
+	}
+	if restored.Snapshot.ID != before.ID || restored.Project.ID != "snap" || restored.Project.FileCount != 2 {
+		t.Errorf("restore = %+v", restored)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "original\n" {
+		t.Errorf("notes.md = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs")); !os.IsNotExist(err) {
+		t.Errorf("docs survived restore: %v", err)
+	}
+
+	// Restoring first saved the state it replaced, so it can be undone
+	list = listSnapshots(t, srv.URL)
+	if len(list) != 3 || list[0].Reason != projects.ReasonRestore {
+		t.Fatalf("snapshots after restore = %+v", list)
+	}
+
+	// Retention keeps the newest three
+	os.WriteFile(filepath.Join(dir, "extra.txt"), []byte("more\n"), 0o644)
+	var manual projects.ProjectSnapshot
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots", "", &manual); status != http.StatusOK {
+		t.Fatalf("snapshot status = %d", status)
+	}
+	list = listSnapshots(t, srv.URL)
+	if len(list) != 3 || list[0].ID != manual.ID || list[0].Reason != projects.ReasonManual || list[2].ID != after.ID {
+		t.Errorf("snapshots after pruning = %+v", list)
+	}
+
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots/"+before.ID+"/restore", "", nil); status != http.StatusNotFound {
+		t.Errorf("restore pruned snapshot status = %d", status)
+	}
+	if status := projectRequest(t, "DELETE", srv.URL+"/v1/projects/snap/snapshots/"+manual.ID, "", nil); status != http.StatusOK {
+		t.Errorf("delete status = %d", status)
+	}
+	if status := projectRequest(t, "DELETE", srv.URL+"/v1/projects/snap/snapshots/"+manual.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("second delete status = %d", status)
+	}
+	if status := projectRequest(t, "GET", srv.URL+"/v1/projects/missing/snapshots", "", nil); status != http.StatusNotFound {
+		t.Errorf("missing project status = %d", status)
+	}
+
+	// Snapshots follow the project when it is renamed
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/rename", `{"id": "snap2"}`, nil); status != http.StatusOK {
+		t.Fatalf("rename status = %d", status)
+	}
+	var renamed struct {
+		Data []projects.ProjectSnapshot `json:"data"`
+	}
+	projectRequest(t, "GET", srv.URL+"/v1/projects/snap2/snapshots", "", &renamed)
+	if len(renamed.Data) != 2 || renamed.Data[0].ProjectID != "snap2" {
+		t.Errorf("renamed project snapshots = %+v", renamed.Data)
+	}
+}
+
+func TestProjectSnapshotsOff(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root})
+
+	chatInProject(t, srv.URL, "snap", "req-1", "[scenario:edit] rewrite")
+	if list := listSnapshots(t, srv.URL); len(list) != 0 {
+		t.Errorf("snapshots = %+v", list)
+	}
+}
+
+func TestRestoreOldestSnapshot(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":       root,
+		"PROJECT_SNAPSHOTS":  "true",
+		"SNAPSHOT_RETENTION": "2",
+	})
+
+	dir := filepath.Join(root, "snap")
+	os.MkdirAll(dir, 0o755)
+	var oldest, newest projects.ProjectSnapshot
+	for _, s := range []struct {
+		content string
+		out     *projects.ProjectSnapshot
+	}{{"first\n", &oldest}, {"second\n", &newest}} {
+		os.WriteFile(filepath.Join(dir, "notes.md"), []byte(s.content), 0o644)
+		if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots", "", s.out); status != http.StatusOK {
+			t.Fatalf("snapshot status = %d", status)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("third\n"), 0o644)
+
+	// The pre-restore snapshot prunes the one being restored
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots/"+oldest.ID+"/restore", "", nil); status != http.StatusOK {
+		t.Fatalf("restore status = %d", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "first\n" {
+		t.Errorf("notes.md = %q", data)
+	}
+	list := listSnapshots(t, srv.URL)
+	if len(list) != 2 || list[0].Reason != projects.ReasonRestore || list[1].ID != newest.ID {
+		t.Errorf("snapshots after restore = %+v", list)
+	}
+}
+
+func TestRestoreProjectInUse(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":              root,
+		"PROJECT_SNAPSHOTS":         "true",
+		"STREAMING_TIMEOUT_SECONDS": "1",
+	})
+
+	var snap projects.ProjectSnapshot
+	os.MkdirAll(filepath.Join(root, "snap"), 0o755)
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots", "", &snap); status != http.StatusOK {
+		t.Fatalf("snapshot status = %d", status)
+	}
+
+	// Hold a session open in the project until the streaming timeout ends it
+	slow := limitedRequest(t, "secret", "POST", srv.URL+"/v1/chat/completions", projectChatBody("snap", "[scenario:slow] take your time", true))
+	scanner := bufio.NewScanner(slow.Body)
+	for scanner.Scan() && !strings.Contains(scanner.Text(), "tick") {
+	}
+
+	for _, req := range []struct{ method, path, body string }{
+		{"POST", "/snapshots/" + snap.ID + "/restore", ""},
+		{"POST", "/rename", `{"id":"moved"}`},
+		{"DELETE", "", ""},
+	} {
+		var out models.ErrorResponse
+		if status := projectRequest(t, req.method, srv.URL+"/v1/projects/snap"+req.path, req.body, &out); status != http.StatusConflict || out.Error.Code != "project_in_use" {
+			t.Errorf("%s %s in use = %+v (%d)", req.method, req.path, out.Error, status)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "snap")); err != nil {
+		t.Errorf("project in use moved: %v", err)
+	}
+	for scanner.Scan() {
+	}
+
+	// The session's hooks finish shortly after its stream ends
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := projectRequest(t, "POST", srv.URL+"/v1/projects/snap/snapshots/"+snap.ID+"/restore", "", nil)
+		if status == http.StatusOK {
+			break
+		}
+		if status != http.StatusConflict || time.Now().After(deadline) {
+			t.Fatalf("restore after session status = %d", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
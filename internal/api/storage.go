@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageHandler reports and reclaims the disk used by projects.
+type StorageHandler struct {
+	projects  *projects.Manager
+	collector *projects.Collector
+}
+
+// NewStorageHandler creates a new storage handler.
+func NewStorageHandler(manager *projects.Manager, collector *projects.Collector) *StorageHandler {
+	return &StorageHandler{projects: manager, collector: collector}
+}
+
+func storageError(c *gin.Context, err error) {
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("Failed to read project storage: %v", err),
+			Type:    "api_error",
+		},
+	})
+}
+
+// HandleStorageUsage handles GET /v1/storage
+//
+// It reports the disk used by each project and by each API key's projects.
+// Authenticated callers see only the projects of their namespace; the
+// totals always cover the whole project root, which the quotas apply to.
+func (h *StorageHandler) HandleStorageUsage(c *gin.Context) {
+	usage, err := h.projects.DiskUsage()
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	if ns := namespace(c); ns != "" {
+		owners := make(map[string]bool)
+		mine := []projects.ProjectUsage{}
+		for _, p := range usage.Projects {
+			if p.Namespace == ns {
+				mine = append(mine, p)
+				owners[p.Owner] = true
+			}
+		}
+		usage.Projects = mine
+		ownerUsage := []projects.OwnerUsage{}
+		for _, o := range usage.Owners {
+			if owners[o.Owner] {
+				ownerUsage = append(ownerUsage, o)
+			}
+		}
+		usage.Owners = ownerUsage
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// HandleCollectGarbage handles POST /v1/storage/gc
+//
+// It removes expired projects now instead of waiting for the next scheduled
+// collection. Authenticated callers are told only about their namespace.
+func (h *StorageHandler) HandleCollectGarbage(c *gin.Context) {
+	collected, err := h.collector.Collect()
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	if ns := namespace(c); ns != "" {
+		mine := []projects.Collected{}
+		for _, p := range collected {
+			if p.Namespace == ns {
+				mine = append(mine, p)
+			}
+		}
+		collected = mine
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": collected})
+}
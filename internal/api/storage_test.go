@@ -0,0 +1,138 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+)
+
+// writeProject creates a project directory holding one file of size bytes.
+func writeProject(t *testing.T, root, id string, size int) {
+	t.Helper()
+	dir := filepath.Join(root, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskQuotas(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "PROJECT_QUOTA_BYTES": "100"})
+	writeProject(t, root, "big", 200)
+	writeProject(t, root, "small", 50)
+
+	var out models.ErrorResponse
+	status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("big", "hello", false), &out)
+	if status != http.StatusInsufficientStorage || out.Error.Code != "project_quota_exceeded" {
+		t.Errorf("over quota: status = %d, error = %+v", status, out.Error)
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("small", "hello", false), nil); status != http.StatusOK {
+		t.Errorf("under quota status = %d", status)
+	}
+	// Read-only runs write nothing, so they are let through
+	readOnly := strings.Replace(projectChatBody("big", "hello", false), "{", `{"read_only": true, `, 1)
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", readOnly, nil); status != http.StatusOK {
+		t.Errorf("read-only status = %d", status)
+	}
+
+	root = t.TempDir()
+	srv = newTestServer(t, map[string]string{"PROJECT_ROOT": root, "PROJECT_QUOTA_BYTES": "0", "DISK_QUOTA_BYTES": "150"})
+	writeProject(t, root, "big", 200)
+	status = projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("fresh", "hello", false), &out)
+	if status != http.StatusInsufficientStorage || out.Error.Code != "disk_quota_exceeded" {
+		t.Errorf("over global quota: status = %d, error = %+v", status, out.Error)
+	}
+}
+
+func TestStorageUsage(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT": root,
+		"REQUIRE_AUTH": "true",
+		"API_KEYS":     "secret",
+	})
+	// Authenticated callers only see their own namespace
+	writeProject(t, projects.NamespaceRoot(root, keyPrincipal("secret")), "unowned", 300)
+	writeProject(t, root, "elsewhere", 500)
+
+	// Projects created on first use belong to the calling key
+	if status := projectRequest(t, "POST", srv.URL+"/v1/chat/completions", projectChatBody("mine", "[scenario:edit] hi", false), nil); status != http.StatusOK {
+		t.Fatalf("chat status = %d", status)
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/projects", `{"id": "created"}`, nil); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+
+	var usage projects.DiskUsage
+	if status := projectRequest(t, "GET", srv.URL+"/v1/storage", "", &usage); status != http.StatusOK {
+		t.Fatalf("storage status = %d", status)
+	}
+	if len(usage.Projects) != 3 || usage.Projects[0].ProjectID != "unowned" || usage.Projects[0].SizeBytes != 300 {
+		t.Fatalf("projects = %+v", usage.Projects)
+	}
+	mine := usage.Projects[1]
+	if mine.ProjectID != "mine" || mine.Owner != keyPrincipal("secret") || mine.FileCount != 2 {
+		t.Errorf("mine = %+v", mine)
+	}
+	if usage.TotalBytes != 500+300+mine.SizeBytes {
+		t.Errorf("total = %d", usage.TotalBytes)
+	}
+	want := projects.OwnerUsage{Owner: keyPrincipal("secret"), SizeBytes: mine.SizeBytes, ProjectCount: 2}
+	if len(usage.Owners) != 1 || usage.Owners[0] != want {
+		t.Errorf("owners = %+v", usage.Owners)
+	}
+}
+
+func TestProjectGC(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":        root,
+		"PROJECT_TTL_HOURS":   "1",
+		"PROJECT_GC_ACTION":   "archive",
+		"PROJECT_ARCHIVE_DIR": archives,
+	})
+	writeProject(t, root, "stale", 10)
+	writeProject(t, root, "recent", 10)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(root, "stale"), old, old)
+
+	var list struct {
+		Data []projects.Collected `json:"data"`
+	}
+	if status := projectRequest(t, "POST", srv.URL+"/v1/storage/gc", "", &list); status != http.StatusOK {
+		t.Fatalf("gc status = %d", status)
+	}
+	if len(list.Data) != 1 || list.Data[0].ProjectID != "stale" || list.Data[0].Action != projects.GCArchive || list.Data[0].SizeBytes != 10 {
+		t.Fatalf("collected = %+v", list.Data)
+	}
+	if filepath.Dir(list.Data[0].Archive) != archives {
+		t.Errorf("archive = %q", list.Data[0].Archive)
+	}
+	if info, err := os.Stat(list.Data[0].Archive); err != nil || info.Size() == 0 {
+		t.Errorf("archive missing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "stale")); !os.IsNotExist(err) {
+		t.Errorf("stale project survived: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "recent")); err != nil {
+		t.Errorf("recent project collected: %v", err)
+	}
+
+	// Nothing expires while PROJECT_TTL_HOURS is 0
+	srv = newTestServer(t, map[string]string{"PROJECT_ROOT": root, "PROJECT_TTL_HOURS": "0"})
+	os.Chtimes(filepath.Join(root, "recent"), old, old)
+	projectRequest(t, "POST", srv.URL+"/v1/storage/gc", "", &list)
+	if len(list.Data) != 0 {
+		t.Errorf("collected with TTL off = %+v", list.Data)
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/streaming"
+	"claude-code-api/internal/tasks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TasksHandler handles asynchronous task requests.
+type TasksHandler struct {
+	cfg   *config.Reloader
+	tasks *tasks.Manager
+}
+
+// NewTasksHandler creates a new tasks handler.
+func NewTasksHandler(cfg *config.Reloader, tasks *tasks.Manager) *TasksHandler {
+	return &TasksHandler{cfg: cfg, tasks: tasks}
+}
+
+func taskNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Message: fmt.Sprintf("Task with id '%s' not found.", id),
+			Type:    "invalid_request_error",
+			Code:    "task_not_found",
+		},
+	})
+}
+
+// HandleCreateTask handles POST /v1/tasks
+func (h *TasksHandler) HandleCreateTask(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	var req models.CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	ns := namespace(c)
+	breq := backend.Request{
+		Model:           model,
+		Prompt:          req.Prompt,
+		SystemPrompt:    req.SystemPrompt,
+		ProjectID:       req.ProjectID,
+		ResumeSessionID: req.SessionID,
+		RequestID:       requestID(c),
+		Principal:       principal(c),
+		Namespace:       ns,
+	}
+	if serr := modelError(c, model); serr != nil {
+		c.JSON(serr.Status, serr.openAIError())
+		return
+	}
+	if serr := prepareRequest(cfg, &breq); serr != nil {
+		c.JSON(serr.Status, serr.openAIError())
+		return
+	}
+
+	task, err := h.tasks.Create(ns, breq, req.Metadata)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to create task: %v", err),
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
+
+// HandleGetTask handles GET /v1/tasks/:task_id
+func (h *TasksHandler) HandleGetTask(c *gin.Context) {
+	id := c.Param("task_id")
+	task, err := h.tasks.Get(namespace(c), id)
+	if err != nil {
+		taskNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// HandleCancelTask handles POST /v1/tasks/:task_id/cancel
+func (h *TasksHandler) HandleCancelTask(c *gin.Context) {
+	id := c.Param("task_id")
+	task, err := h.tasks.Cancel(namespace(c), id)
+	switch {
+	case errors.Is(err, tasks.ErrNotFound):
+		taskNotFound(c, id)
+	case errors.Is(err, tasks.ErrFinished):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Task '%s' has already %s.", id, task.Status),
+				Type:    "invalid_request_error",
+				Code:    "task_finished",
+			},
+		})
+	default:
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// HandleTaskEvents handles GET /v1/tasks/:task_id/events
+//
+// Events after ?after= (or the Last-Event-ID header) are returned as a JSON
+// list, or as an SSE stream that follows the task until it finishes when
+// requested with ?stream=true or Accept: text/event-stream.
+func (h *TasksHandler) HandleTaskEvents(c *gin.Context) {
+	id, ns := c.Param("task_id"), namespace(c)
+
+	afterParam := c.Query("after")
+	if afterParam == "" {
+		afterParam = c.GetHeader("Last-Event-ID")
+	}
+	after, _ := strconv.Atoi(afterParam)
+
+	stream := c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+
+	events, done, changed, err := h.tasks.Events(ns, id, after)
+	if errors.Is(err, tasks.ErrNotFound) {
+		taskNotFound(c, id)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to read task events: %v", err),
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	if !stream {
+		if events == nil {
+			events = []tasks.Event{}
+		}
+		c.JSON(http.StatusOK, gin.H{"object": "list", "data": events})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	formatter := &streaming.SSEFormatter{}
+	for {
+		for _, ev := range events {
+			c.Writer.WriteString(formatter.FormatNamedEvent(strconv.Itoa(ev.Seq), string(ev.Type), ev))
+			after = ev.Seq
+		}
+		c.Writer.Flush()
+		if done {
+			break
+		}
+
+		select {
+		case <-changed:
+		case <-c.Request.Context().Done():
+			return
+		}
+		if events, done, changed, err = h.tasks.Events(ns, id, after); err != nil {
+			return
+		}
+	}
+
+	c.Writer.WriteString(formatter.FormatDone())
+	c.Writer.Flush()
+}
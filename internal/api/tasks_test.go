@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/tasks"
+)
+
+func createTask(t *testing.T, url, body string) tasks.Task {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/tasks", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	var task tasks.Task
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func getTask(t *testing.T, url, id string) tasks.Task {
+	t.Helper()
+	resp, err := http.Get(url + "/v1/tasks/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var task tasks.Task
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func waitForTask(t *testing.T, url, id string) tasks.Task {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if task := getTask(t, url, id); task.Status.Terminal() {
+			return task
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("task %s did not finish", id)
+	return tasks.Task{}
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	created := createTask(t, srv.URL, `{"prompt":"[scenario:tools] look","metadata":{"ticket":"42"}}`)
+	if created.Status != tasks.StatusQueued || created.ID == "" {
+		t.Fatalf("created = %+v", created)
+	}
+
+	task := waitForTask(t, srv.URL, created.ID)
+	if task.Status != tasks.StatusSucceeded {
+		t.Fatalf("task = %+v", task)
+	}
+	if task.Result != "Let me look at the project.\nThe project has two files." || task.SessionID == "" {
+		t.Errorf("result = %q, session_id = %q", task.Result, task.SessionID)
+	}
+	if task.Progress.ToolCalls != 1 || task.Progress.TextBlocks != 2 || task.Usage == nil {
+		t.Errorf("progress = %+v, usage = %+v", task.Progress, task.Usage)
+	}
+
+	// Replay events after the first one
+	resp, err := http.Get(srv.URL + "/v1/tasks/" + created.ID + "/events?after=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Data []tasks.Event `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	if len(list.Data) == 0 || list.Data[0].Seq != 2 || list.Data[0].Status != tasks.StatusRunning {
+		t.Fatalf("events = %+v", list.Data)
+	}
+	if last := list.Data[len(list.Data)-1]; last.Status != tasks.StatusSucceeded {
+		t.Errorf("last event = %+v", last)
+	}
+
+	// Finished tasks cannot be cancelled
+	cancel, err := http.Post(srv.URL+"/v1/tasks/"+created.ID+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel.Body.Close()
+	if cancel.StatusCode != http.StatusConflict {
+		t.Errorf("cancel finished task status = %d, want 409", cancel.StatusCode)
+	}
+
+	// Tasks survive a restart
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := tasks.NewManager(config.NewReloader(cfg), backend.NewRegistry(config.NewReloader(cfg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Shutdown()
+	reloaded, err := restarted.Get("", created.ID)
+	if err != nil || reloaded.Result != task.Result || reloaded.Status != tasks.StatusSucceeded {
+		t.Errorf("reloaded = %+v, %v", reloaded, err)
+	}
+}
+
+func TestTaskEventStreamAndCancel(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	created := createTask(t, srv.URL, `{"prompt":"[scenario:slow] wait"}`)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/tasks/"+created.ID+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var names []string
+	cancelled := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+			if name == "text" && !cancelled {
+				cancelled = true
+				go http.Post(srv.URL+"/v1/tasks/"+created.ID+"/cancel", "application/json", nil)
+			}
+		}
+		if line == "data: [DONE]" {
+			break
+		}
+	}
+
+	if len(names) == 0 || names[0] != "status" || names[len(names)-1] != "status" {
+		t.Fatalf("events = %v", names)
+	}
+	if task := getTask(t, srv.URL, created.ID); task.Status != tasks.StatusCancelled {
+		t.Errorf("status = %s, want cancelled", task.Status)
+	}
+
+	missing, _ := http.Get(srv.URL + "/v1/tasks/task_missing")
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("missing task status = %d, want 404", missing.StatusCode)
+	}
+}
+
+func TestTaskWaitsForCapacity(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"MAX_CONCURRENT_SESSIONS": "1"})
+
+	slow := createTask(t, srv.URL, `{"prompt":"[scenario:slow] wait"}`)
+	for getTask(t, srv.URL, slow.ID).Status != tasks.StatusRunning {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The second task stays queued while the only slot is taken
+	fast := createTask(t, srv.URL, `{"prompt":"hello"}`)
+	time.Sleep(200 * time.Millisecond)
+	if status := getTask(t, srv.URL, fast.ID).Status; status != tasks.StatusQueued {
+		t.Fatalf("second task status = %s, want queued", status)
+	}
+
+	cancel, err := http.Post(srv.URL+"/v1/tasks/"+slow.ID+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel.Body.Close()
+
+	if task := waitForTask(t, srv.URL, fast.ID); task.Status != tasks.StatusSucceeded || task.Result != "You said: hello" {
+		t.Errorf("second task = %+v", task)
+	}
+}
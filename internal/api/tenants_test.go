@@ -0,0 +1,271 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/files"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/projects"
+	"claude-code-api/internal/tasks"
+
+	"github.com/gorilla/websocket"
+)
+
+// keyRequest sends a request authenticated with key and decodes the JSON
+// reply into out.
+func keyRequest(t *testing.T, key, method, url, body string, out interface{}) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// keyUpload uploads content into a project as key.
+func keyUpload(t *testing.T, key, url, project, content string) (files.File, int) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("project_id", project)
+	part, _ := w.CreateFormFile("file", "notes.txt")
+	part.Write([]byte(content))
+	w.Close()
+
+	req, _ := http.NewRequest("POST", url+"/v1/files", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var f files.File
+	json.NewDecoder(resp.Body).Decode(&f)
+	return f, resp.StatusCode
+}
+
+// newTenantServer starts a server where alice and bob share the team
+// namespace, which shares its project "shared" read-only with carol and
+// read-write with dave.
+func newTenantServer(t *testing.T, root string) string {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := fmt.Sprintf(`require_auth: true
+api_keys: [alice, bob, carol, dave]
+key_groups:
+  team: [alice, %s]
+shared_projects:
+  - namespace: team
+    project: shared
+    grants:
+      %s: read
+      %s: write
+`, keyPrincipal("bob"), keyPrincipal("carol"), keyPrincipal("dave"))
+	if err := os.WriteFile(configFile, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return newTestServer(t, map[string]string{"PROJECT_ROOT": root, "CONFIG_FILE": configFile}).URL
+}
+
+func TestTenantIsolation(t *testing.T) {
+	root := t.TempDir()
+	url := newTenantServer(t, root)
+
+	if status := keyRequest(t, "alice", "POST", url+"/v1/chat/completions", projectChatBody("app", "[scenario:edit] hi", false), nil); status != http.StatusOK {
+		t.Fatalf("alice chat status = %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(projects.NamespaceRoot(root, "team"), "app")); err != nil {
+		t.Fatalf("project not created in the team namespace: %v", err)
+	}
+
+	// Bob is in alice's key group; carol is not
+	var p projects.Project
+	if status := keyRequest(t, "bob", "GET", url+"/v1/projects/app", "", &p); status != http.StatusOK || p.Namespace != "team" || p.Owner != keyPrincipal("alice") {
+		t.Errorf("bob get = %+v (%d)", p, status)
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, "carol", "GET", url+"/v1/projects/app", "", &out); status != http.StatusNotFound {
+		t.Errorf("carol get status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/projects/team:app", "", &out); status != http.StatusNotFound || out.Error.Code != "project_not_found" {
+		t.Errorf("carol get unshared = %+v (%d)", out.Error, status)
+	}
+
+	// The same project ID names carol's own project
+	if status := keyRequest(t, "carol", "POST", url+"/v1/chat/completions", projectChatBody("app", "hello", false), nil); status != http.StatusOK {
+		t.Fatalf("carol chat status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/projects/app", "", &p); status != http.StatusOK || p.Namespace != keyPrincipal("carol") || p.FileCount != 0 {
+		t.Errorf("carol's app = %+v (%d)", p, status)
+	}
+
+	// Files follow their project's namespace
+	f, status := keyUpload(t, "alice", url, "app", "team notes")
+	if status != http.StatusOK {
+		t.Fatalf("upload status = %d", status)
+	}
+	if status := keyRequest(t, "bob", "GET", url+"/v1/files/"+f.ID, "", nil); status != http.StatusOK {
+		t.Errorf("bob file status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/files/"+f.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("carol file status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "DELETE", url+"/v1/files/"+f.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("carol delete file status = %d", status)
+	}
+	var list struct {
+		Data []files.File `json:"data"`
+	}
+	keyRequest(t, "carol", "GET", url+"/v1/files", "", &list)
+	if len(list.Data) != 0 {
+		t.Errorf("carol sees files %+v", list.Data)
+	}
+	if _, status := keyUpload(t, "carol", url, "team:app", "intruder"); status != http.StatusNotFound {
+		t.Errorf("carol upload to team:app status = %d", status)
+	}
+}
+
+func TestSharedProjects(t *testing.T) {
+	root := t.TempDir()
+	url := newTenantServer(t, root)
+
+	if status := keyRequest(t, "alice", "POST", url+"/v1/projects", `{"id": "shared"}`, nil); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	f, status := keyUpload(t, "alice", url, "shared", "shared notes")
+	if status != http.StatusOK {
+		t.Fatalf("upload status = %d", status)
+	}
+
+	// Carol has a read grant: she may look and run read-only
+	var list struct {
+		Data []projects.Project `json:"data"`
+	}
+	keyRequest(t, "carol", "GET", url+"/v1/projects", "", &list)
+	if len(list.Data) != 1 || list.Data[0].ID != "shared" || list.Data[0].Namespace != "team" || list.Data[0].Access != "read" {
+		t.Errorf("carol's projects = %+v", list.Data)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/projects/team:shared/files/notes.txt", "", nil); status != http.StatusOK {
+		t.Errorf("carol read status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/files/"+f.ID+"/content", "", nil); status != http.StatusOK {
+		t.Errorf("carol file content status = %d", status)
+	}
+	readOnly := strings.Replace(projectChatBody("team:shared", "hello", false), "{", `{"read_only": true, `, 1)
+	if status := keyRequest(t, "carol", "POST", url+"/v1/chat/completions", readOnly, nil); status != http.StatusOK {
+		t.Errorf("carol read-only chat status = %d", status)
+	}
+	var out models.ErrorResponse
+	if status := keyRequest(t, "carol", "POST", url+"/v1/chat/completions", projectChatBody("team:shared", "hello", false), &out); status != http.StatusForbidden || out.Error.Code != "project_read_only" {
+		t.Errorf("carol chat = %+v (%d)", out.Error, status)
+	}
+	if _, status := keyUpload(t, "carol", url, "team:shared", "edit"); status != http.StatusForbidden {
+		t.Errorf("carol upload status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "DELETE", url+"/v1/files/"+f.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("carol delete file status = %d", status)
+	}
+
+	// Dave has a write grant: he may run and upload, but not rename or
+	// delete the project
+	if status := keyRequest(t, "dave", "POST", url+"/v1/chat/completions", projectChatBody("team:shared", "[scenario:edit] hi", false), nil); status != http.StatusOK {
+		t.Errorf("dave chat status = %d", status)
+	}
+	if _, status := keyUpload(t, "dave", url, "team:shared", "more notes"); status != http.StatusOK {
+		t.Errorf("dave upload status = %d", status)
+	}
+	if status := keyRequest(t, "dave", "DELETE", url+"/v1/projects/team:shared", "", &out); status != http.StatusForbidden || out.Error.Code != "project_not_owned" {
+		t.Errorf("dave delete = %+v (%d)", out.Error, status)
+	}
+	if _, err := os.Stat(filepath.Join(projects.NamespaceRoot(root, keyPrincipal("dave")), "shared")); !os.IsNotExist(err) {
+		t.Errorf("dave's run created a project of his own: %v", err)
+	}
+
+	// Shared projects are never created on first use
+	if status := keyRequest(t, "dave", "POST", url+"/v1/chat/completions", projectChatBody("team:other", "hello", false), nil); status != http.StatusNotFound {
+		t.Errorf("chat in unshared project status = %d", status)
+	}
+}
+
+func TestTenantTasksResponsesAndSessions(t *testing.T) {
+	url := newTenantServer(t, t.TempDir())
+
+	var task tasks.Task
+	if status := keyRequest(t, "alice", "POST", url+"/v1/tasks", `{"prompt":"hello"}`, &task); status != http.StatusAccepted {
+		t.Fatalf("create task status = %d", status)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !task.Status.Terminal() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		// Bob shares alice's namespace
+		if status := keyRequest(t, "bob", "GET", url+"/v1/tasks/"+task.ID, "", &task); status != http.StatusOK {
+			t.Fatalf("bob get task status = %d", status)
+		}
+	}
+	if task.SessionID == "" {
+		t.Fatalf("task = %+v", task)
+	}
+
+	var out models.ErrorResponse
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/v1/tasks/" + task.ID},
+		{"GET", "/v1/tasks/" + task.ID + "/events"},
+		{"POST", "/v1/tasks/" + task.ID + "/cancel"},
+	} {
+		if status := keyRequest(t, "carol", r.method, url+r.path, "", &out); status != http.StatusNotFound || out.Error.Code != "task_not_found" {
+			t.Errorf("carol %s %s = %+v (%d)", r.method, r.path, out.Error, status)
+		}
+	}
+
+	var resp models.Response
+	if status := keyRequest(t, "alice", "POST", url+"/v1/responses", `{"input":"hello"}`, &resp); status != http.StatusOK {
+		t.Fatalf("create response status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "GET", url+"/v1/responses/"+resp.ID, "", &out); status != http.StatusNotFound {
+		t.Errorf("carol get response status = %d", status)
+	}
+	if status := keyRequest(t, "carol", "POST", url+"/v1/responses", `{"input":"hi","previous_response_id":"`+resp.ID+`"}`, &out); status != http.StatusBadRequest || out.Error.Code != "previous_response_not_found" {
+		t.Errorf("carol continue response = %+v (%d)", out.Error, status)
+	}
+	if status := keyRequest(t, "bob", "GET", url+"/v1/responses/"+resp.ID, "", nil); status != http.StatusOK {
+		t.Errorf("bob get response status = %d", status)
+	}
+
+	// Sessions resume only in the project they ran in
+	resume := func(key, sessionID string) []models.SessionEvent {
+		t.Helper()
+		header := http.Header{"Authorization": {"Bearer " + key}}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/v1/sessions/ws?session_id="+sessionID, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		var created models.SessionEvent
+		conn.ReadJSON(&created)
+		conn.WriteJSON(models.SessionClientMessage{Type: "user_message", Content: "what did I say?"})
+		return readUntilStatus(t, conn)
+	}
+	if events := resume("carol", task.SessionID); events[0].Type != "error" || events[0].Code != "session_not_found" {
+		t.Errorf("carol resume = %s %+v", eventTypes(events), events[0])
+	}
+	if events := resume("bob", task.SessionID); events[len(events)-1].SessionID != task.SessionID {
+		t.Errorf("bob resume = %s %+v", eventTypes(events), events[len(events)-1])
+	}
+}
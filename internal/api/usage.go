@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/keys"
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageDays is the period reported when no start is given.
+const defaultUsageDays = 30
+
+// maxUsageDays bounds the period of one usage query, as each day is a file
+// to read.
+const maxUsageDays = 366
+
+// UsageHandler reports the recorded usage of runs.
+type UsageHandler struct {
+	usage *usage.Store
+	keys  *keys.Store
+}
+
+// NewUsageHandler creates a new usage handler. keyStore, which may be nil,
+// names the keys in reports.
+func NewUsageHandler(store *usage.Store, keyStore *keys.Store) *UsageHandler {
+	return &UsageHandler{usage: store, keys: keyStore}
+}
+
+// parseUsageTime parses a date (YYYY-MM-DD, UTC) or Unix time. Dates given
+// as end include the whole day.
+func parseUsageTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or a Unix time", s)
+}
+
+func usageError(c *gin.Context, code, message string) {
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error: models.ErrorDetail{Message: message, Type: "invalid_request_error", Code: code},
+	})
+}
+
+// HandleUsage handles GET /v1/usage
+//
+// It adds up the usage recorded between start and end (default: the last
+// 30 days, at most a year), grouped by the comma-separated dimensions of
+// group_by; groups by key carry the key's name and owner. Keys with the
+// admin scope see every key's usage and may select one with key; other keys
+// see only their own. format=csv returns a CSV file.
+func (h *UsageHandler) HandleUsage(c *gin.Context) {
+	now := time.Now().UTC()
+	q := usage.Query{End: now.Truncate(24*time.Hour).AddDate(0, 0, 1)}
+	if s := c.Query("end"); s != "" {
+		t, err := parseUsageTime(s, true)
+		if err != nil {
+			usageError(c, "invalid_end", err.Error())
+			return
+		}
+		q.End = t
+	}
+	q.Start = q.End.AddDate(0, 0, -defaultUsageDays)
+	if s := c.Query("start"); s != "" {
+		t, err := parseUsageTime(s, false)
+		if err != nil {
+			usageError(c, "invalid_start", err.Error())
+			return
+		}
+		q.Start = t
+	}
+	if !q.Start.Before(q.End) {
+		usageError(c, "invalid_start", "start must be before end")
+		return
+	}
+	if q.Start.Before(time.Unix(0, 0)) {
+		usageError(c, "invalid_start", "start must not be before 1970-01-01")
+		return
+	}
+	if q.End.Sub(q.Start) > maxUsageDays*24*time.Hour {
+		usageError(c, "invalid_start", fmt.Sprintf("start must be at most %d days before end", maxUsageDays))
+		return
+	}
+	seen := make(map[string]bool)
+	for _, d := range strings.Split(c.Query("group_by"), ",") {
+		if d = strings.TrimSpace(d); d != "" && !seen[d] {
+			seen[d] = true
+			q.GroupBy = append(q.GroupBy, d)
+		}
+	}
+
+	q.Key = c.Query("key")
+	if k, ok := callerKey(c); ok && !k.HasScope(keys.ScopeAdmin) {
+		q.Key = principal(c)
+	}
+	q.Describe = h.describeKeys()
+
+	groups, err := h.usage.Query(q)
+	if errors.Is(err, usage.ErrInvalidDimension) {
+		usageError(c, "invalid_group_by", err.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Message: fmt.Sprintf("Failed to read usage: %v", err),
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		writeUsageCSV(c, q, groups)
+		return
+	}
+	var total usage.Totals
+	for _, g := range groups {
+		total.Add(g.Totals)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "usage",
+		"start":    q.Start.Unix(),
+		"end":      q.End.Unix(),
+		"group_by": append([]string{}, q.GroupBy...),
+		"data":     groups,
+		"total":    total,
+	})
+}
+
+// describeKeys returns a usage.Query Describe func looking keys up in the key
+// store. Static API keys and JWT callers are not in it, so their principal
+// stands for both name and owner, as it does for keys without an owner.
+func (h *UsageHandler) describeKeys() func(string) (string, string) {
+	type description struct{ name, owner string }
+	seen := make(map[string]description)
+	return func(key string) (string, string) {
+		d, ok := seen[key]
+		if !ok {
+			d = description{key, key}
+			if h.keys != nil {
+				if k, err := h.keys.Get(key); err == nil {
+					if k.Name != "" {
+						d.name = k.Name
+					}
+					if k.Owner != "" {
+						d.owner = k.Owner
+					}
+				}
+			}
+			seen[key] = d
+		}
+		return d.name, d.owner
+	}
+}
+
+// usageColumns lists the CSV columns describing a group: the dimensions
+// grouped by, with the name and owner of keys after the key.
+func usageColumns(groupBy []string) []string {
+	var columns []string
+	for _, d := range groupBy {
+		columns = append(columns, d)
+		if d == usage.ByKey {
+			columns = append(columns, "name")
+			if !slices.Contains(groupBy, usage.ByOwner) {
+				columns = append(columns, usage.ByOwner)
+			}
+		}
+	}
+	return columns
+}
+
+// writeUsageCSV writes groups as a CSV file with the columns of usageColumns
+// followed by the totals.
+func writeUsageCSV(c *gin.Context, q usage.Query, groups []usage.Group) {
+	name := fmt.Sprintf("usage_%s_%s.csv", q.Start.UTC().Format("2006-01-02"), q.End.UTC().Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	columns := usageColumns(q.GroupBy)
+	header := append(append([]string{}, columns...), "requests", "input_tokens", "output_tokens",
+		"cache_creation_input_tokens", "cache_read_input_tokens", "cost_usd", "duration_ms")
+	w.Write(header)
+	for _, g := range groups {
+		row := make([]string, 0, len(header))
+		for _, col := range columns {
+			if col == "name" {
+				row = append(row, g.Name)
+			} else {
+				row = append(row, g.Value(col))
+			}
+		}
+		row = append(row,
+			strconv.Itoa(g.Requests),
+			strconv.FormatInt(g.InputTokens, 10),
+			strconv.FormatInt(g.OutputTokens, 10),
+			strconv.FormatInt(g.CacheCreationInputTokens, 10),
+			strconv.FormatInt(g.CacheReadInputTokens, 10),
+			strconv.FormatFloat(g.CostUSD, 'f', 6, 64),
+			strconv.FormatInt(g.DurationMs, 10),
+		)
+		w.Write(row)
+	}
+	w.Flush()
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/models"
+	"claude-code-api/internal/usage"
+)
+
+type usageReport struct {
+	Object  string        `json:"object"`
+	GroupBy []string      `json:"group_by"`
+	Data    []usage.Group `json:"data"`
+	Total   usage.Totals  `json:"total"`
+}
+
+// getUsage fetches the usage report for query as key.
+func getUsage(t *testing.T, key, url, query string) usageReport {
+	t.Helper()
+	var report usageReport
+	if status := keyRequest(t, key, "GET", url+"/v1/usage"+query, "", &report); status != http.StatusOK {
+		t.Fatalf("usage%s status = %d", query, status)
+	}
+	return report
+}
+
+func TestUsage(t *testing.T) {
+	url, _ := newKeysServer(t)
+	var created models.CreateKeyResponse
+	if status := keyRequest(t, "root", "POST", url+"/v1/admin/keys", `{"name": "team", "owner": "data"}`, &created); status != http.StatusOK {
+		t.Fatalf("create status = %d", status)
+	}
+	team := created.Secret
+
+	opus := strings.Replace(chatBody("hello there", false), "claude-sonnet-4-5-20250929", "claude-opus-4-1-20250805", 1)
+	for key, body := range map[string]string{
+		"root": chatBody("hello", false),
+		team:   opus,
+	} {
+		if status := keyRequest(t, key, "POST", url+"/v1/chat/completions", body, nil); status != http.StatusOK {
+			t.Fatalf("chat status = %d", status)
+		}
+	}
+	if status := keyRequest(t, team, "POST", url+"/v1/chat/completions", projectChatBody("app", "one two three", false), nil); status != http.StatusOK {
+		t.Fatalf("project chat status = %d", status)
+	}
+
+	// Admins see every key
+	report := getUsage(t, "root", url, "?group_by=key")
+	if report.Object != "usage" || len(report.Data) != 2 || report.Total.Requests != 3 {
+		t.Fatalf("by key = %+v", report)
+	}
+	for _, g := range report.Data {
+		want := 1
+		if g.Key == created.ID {
+			want = 2
+			if g.Name != "team" || g.Owner != "data" {
+				t.Errorf("managed key named %q owned by %q", g.Name, g.Owner)
+			}
+		} else if g.Key != keyPrincipal("root") {
+			t.Errorf("unexpected key %q", g.Key)
+		} else if g.Name != g.Key || g.Owner != g.Key {
+			// Static keys are not in the key store
+			t.Errorf("static key named %q owned by %q", g.Name, g.Owner)
+		}
+		if g.Requests != want || g.InputTokens == 0 || g.OutputTokens == 0 || g.CostUSD <= 0 || g.DurationMs <= 0 {
+			t.Errorf("group = %+v", g)
+		}
+	}
+
+	report = getUsage(t, "root", url, "?group_by=owner")
+	if len(report.Data) != 2 || report.Data[0].Owner != "data" || report.Data[0].Requests != 2 || report.Data[0].Key != "" || report.Data[1].Owner != keyPrincipal("root") {
+		t.Errorf("by owner = %+v", report.Data)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	report = getUsage(t, "root", url, "?group_by=model,day")
+	if len(report.Data) != 2 || report.Data[0].Day != today || report.Data[0].Model != "claude-opus-4-1-20250805" || report.Data[1].Requests != 2 {
+		t.Errorf("by model and day = %+v", report.Data)
+	}
+	report = getUsage(t, "root", url, "?group_by=project&key="+created.ID)
+	if len(report.Data) != 2 || !strings.HasSuffix(report.Data[0].Project, ":app") || report.Data[0].Key != "" {
+		t.Errorf("by project = %+v", report.Data)
+	}
+
+	// Date ranges include whole days
+	report = getUsage(t, "root", url, "?start=2020-01-01&end=2020-01-31")
+	if len(report.Data) != 0 || report.Total.Requests != 0 {
+		t.Errorf("past range = %+v", report)
+	}
+	report = getUsage(t, "root", url, "?start="+today+"&end="+today)
+	if report.Total.Requests != 3 {
+		t.Errorf("today = %+v", report.Total)
+	}
+
+	// Other keys see only their own usage
+	report = getUsage(t, team, url, "?group_by=key&key="+keyPrincipal("root"))
+	if len(report.Data) != 1 || report.Data[0].Key != created.ID || report.Total.Requests != 2 {
+		t.Errorf("team usage = %+v", report)
+	}
+
+	var out models.ErrorResponse
+	if status := keyRequest(t, "root", "GET", url+"/v1/usage?group_by=team", "", &out); status != http.StatusBadRequest || out.Error.Code != "invalid_group_by" {
+		t.Errorf("bad group_by = %+v (%d)", out.Error, status)
+	}
+	for _, query := range []string{"start=yesterday", "start=-99999999999", "start=2020-01-01&end=2021-06-01", "end=2024-01-01&start=2024-02-01"} {
+		if status := keyRequest(t, "root", "GET", url+"/v1/usage?"+query, "", &out); status != http.StatusBadRequest || out.Error.Code != "invalid_start" {
+			t.Errorf("%s = %+v (%d)", query, out.Error, status)
+		}
+	}
+}
+
+func TestUsageCSV(t *testing.T) {
+	url, _ := newKeysServer(t)
+	if status := keyRequest(t, "root", "POST", url+"/v1/chat/completions", chatBody("hello", false), nil); status != http.StatusOK {
+		t.Fatalf("chat status = %d", status)
+	}
+
+	// Repeated dimensions are listed once
+	resp := limitedRequest(t, "root", "GET", url+"/v1/usage?group_by=day,model,day&format=csv", "")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv = %d %v", resp.StatusCode, resp.Header)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Disposition"), "attachment") {
+		t.Errorf("Content-Disposition = %q", resp.Header.Get("Content-Disposition"))
+	}
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0], ",") != "day,model,requests,input_tokens,output_tokens,cache_creation_input_tokens,cache_read_input_tokens,cost_usd,duration_ms" {
+		t.Fatalf("rows = %q", rows)
+	}
+	if rows[1][1] != "claude-sonnet-4-5-20250929" || rows[1][2] != "1" {
+		t.Errorf("row = %q", rows[1])
+	}
+
+	// Keys are followed by their name and owner
+	resp = limitedRequest(t, "root", "GET", url+"/v1/usage?group_by=key&format=csv", "")
+	rows, err = csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0][:4], ",") != "key,name,owner,requests" || rows[1][1] != keyPrincipal("root") {
+		t.Errorf("rows by key = %q", rows)
+	}
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+// Turn end reasons reported in status events.
+const (
+	wsStatusRunning     = "running"
+	wsStatusIdle        = "idle"
+	wsStatusInterrupted = "interrupted"
+	wsStatusCancelled   = "cancelled"
+)
+
+// WebSocketHandler serves bidirectional agent sessions over WebSocket.
+type WebSocketHandler struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates a new WebSocket handler.
+func NewWebSocketHandler(cfg *config.Reloader, backends *backend.Registry) *WebSocketHandler {
+	h := &WebSocketHandler{cfg: cfg, backends: backends}
+	h.upgrader = websocket.Upgrader{
+		// Non-browser clients send no Origin; browsers must match the CORS list
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || originAllowed(cfg.Get(), origin)
+		},
+	}
+	return h
+}
+
+// HandleSession handles GET /v1/sessions/ws
+//
+// Each user message runs as one turn of a Claude session; later turns resume
+// it. Messages sent while a turn is running are queued and run in order once
+// it ends, so a client that wants to redirect Claude interrupts first.
+func (h *WebSocketHandler) HandleSession(c *gin.Context) {
+	cfg := h.cfg.Get()
+
+	model := c.Query("model")
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		projectID = defaultProjectID
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		log.Warn().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	s := &wsSession{
+		h:         h,
+		c:         c,
+		conn:      conn,
+		model:     model,
+		projectID: projectID,
+		sessionID: c.Query("session_id"),
+	}
+	s.serve()
+}
+
+// wsSession is the state of one WebSocket connection.
+type wsSession struct {
+	h    *WebSocketHandler
+	c    *gin.Context
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+	turns   sync.WaitGroup
+
+	mu           sync.Mutex
+	model        string
+	projectID    string
+	systemPrompt string
+	sessionID    string
+	queue        []models.SessionClientMessage
+	busy         bool
+	run          backend.Run
+	stopReason   string
+	closed       bool
+}
+
+func (s *wsSession) send(ev models.SessionEvent) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := s.conn.WriteJSON(ev); err != nil {
+		log.Debug().Err(err).Msg("WebSocket write failed")
+	}
+}
+
+func (s *wsSession) sendError(code, message string) {
+	s.send(models.SessionEvent{Type: "error", Code: code, Error: message})
+}
+
+// serve reads client messages until the connection closes.
+func (s *wsSession) serve() {
+	s.send(models.SessionEvent{
+		Type:      "session.created",
+		SessionID: s.sessionID,
+		Model:     s.model,
+		ProjectID: s.projectID,
+	})
+
+	s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	stopPing := make(chan struct{})
+	go s.ping(stopPing)
+	defer close(stopPing)
+
+	for {
+		var msg models.SessionClientMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				log.Debug().Err(err).Msg("WebSocket read failed")
+			}
+			break
+		}
+		s.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		switch msg.Type {
+		case "user_message":
+			s.submit(msg)
+		case "interrupt":
+			s.stop(wsStatusInterrupted)
+		case "cancel":
+			s.stop(wsStatusCancelled)
+		default:
+			s.sendError("unknown_message_type", "Unknown message type: "+msg.Type)
+		}
+	}
+
+	s.close()
+}
+
+func (s *wsSession) ping(stop <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			s.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// submit runs msg now, or queues it behind the running turn.
+func (s *wsSession) submit(msg models.SessionClientMessage) {
+	if msg.Content == "" {
+		s.sendError("missing_content", "user_message requires content")
+		return
+	}
+
+	s.mu.Lock()
+	if s.busy {
+		s.queue = append(s.queue, msg)
+		queued := len(s.queue)
+		s.mu.Unlock()
+		s.send(models.SessionEvent{Type: "message.queued", Queued: queued})
+		return
+	}
+	s.busy = true
+	s.stopReason = ""
+	s.mu.Unlock()
+
+	s.turns.Add(1)
+	go s.runQueue(msg)
+}
+
+// runQueue runs msg and then any messages queued meanwhile.
+func (s *wsSession) runQueue(msg models.SessionClientMessage) {
+	defer s.turns.Done()
+
+	for {
+		s.runTurn(msg)
+
+		s.mu.Lock()
+		if s.closed || len(s.queue) == 0 {
+			s.busy = false
+			s.mu.Unlock()
+			return
+		}
+		msg = s.queue[0]
+		s.queue = s.queue[1:]
+		s.stopReason = ""
+		s.mu.Unlock()
+	}
+}
+
+// runTurn runs a single user message and streams its events.
+func (s *wsSession) runTurn(msg models.SessionClientMessage) {
+	s.mu.Lock()
+	if msg.SystemPrompt != "" {
+		s.systemPrompt = msg.SystemPrompt
+	}
+	req := backend.Request{
+		Model:           s.model,
+		Prompt:          msg.Content,
+		SystemPrompt:    s.systemPrompt,
+		ProjectID:       s.projectID,
+		ResumeSessionID: s.sessionID,
+	}
+	s.mu.Unlock()
+
+	run, cancel, serr := startSession(s.c, s.h.cfg.Get(), s.h.backends, req)
+	if serr != nil {
+		s.sendError(serr.Code, serr.Message)
+		s.send(models.SessionEvent{Type: "status", Status: wsStatusIdle, SessionID: req.ResumeSessionID})
+		return
+	}
+	defer cancel()
+
+	// A control message or close may have arrived while the run was starting
+	s.mu.Lock()
+	s.run = run
+	pending := s.stopReason
+	if s.closed {
+		pending = wsStatusCancelled
+	}
+	s.mu.Unlock()
+	if pending != "" {
+		stopRun(run, pending)
+	}
+
+	started := false
+	for ev := range run.Events() {
+		if ev.SessionID != "" {
+			s.mu.Lock()
+			s.sessionID = ev.SessionID
+			s.mu.Unlock()
+		}
+		if !started {
+			started = true
+			s.send(models.SessionEvent{Type: "status", Status: wsStatusRunning, SessionID: s.currentSessionID()})
+		}
+
+		switch ev.Type {
+		case backend.EventText:
+			s.send(models.SessionEvent{Type: "text_delta", Text: ev.Text})
+		case backend.EventToolCall:
+			s.send(models.SessionEvent{Type: "tool_call", ToolCall: &models.SessionToolCall{
+				ID:    ev.ToolCall.ID,
+				Name:  ev.ToolCall.Name,
+				Input: ev.ToolCall.Input,
+			}})
+		case backend.EventToolResult:
+			s.send(models.SessionEvent{Type: "tool_result", ToolResult: &models.SessionToolResult{
+				ToolUseID: ev.ToolResult.ToolUseID,
+				Content:   ev.ToolResult.Content,
+				IsError:   ev.ToolResult.IsError,
+			}})
+		case backend.EventError:
+			// Errors caused by interrupt or cancel are reported as status
+			s.mu.Lock()
+			stopped := s.stopReason != ""
+			s.mu.Unlock()
+			if !stopped {
+				s.sendError("claude_error", ev.Error)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.run = nil
+	status := s.stopReason
+	s.mu.Unlock()
+	if status == "" {
+		status = wsStatusIdle
+	}
+
+	u := run.Usage()
+	s.send(models.SessionEvent{Type: "usage", Usage: &models.SessionUsage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+		CostUSD:                  u.CostUSD,
+		DurationMs:               u.DurationMs,
+	}})
+	s.send(models.SessionEvent{Type: "status", Status: status, SessionID: s.currentSessionID()})
+}
+
+func (s *wsSession) currentSessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+// stop handles interrupt and cancel. Interrupt ends the running turn and
+// lets queued messages continue; cancel also drops the queue.
+func (s *wsSession) stop(reason string) {
+	s.mu.Lock()
+	if !s.busy {
+		s.mu.Unlock()
+		s.sendError("no_active_turn", "There is no running turn")
+		return
+	}
+	s.stopReason = reason
+	if reason == wsStatusCancelled {
+		s.queue = nil
+	}
+	run := s.run
+	s.mu.Unlock()
+
+	if run != nil {
+		stopRun(run, reason)
+	}
+}
+
+// stopRun forwards interrupt to runs that support it and cancels otherwise.
+func stopRun(run backend.Run, reason string) {
+	if reason == wsStatusInterrupted {
+		if i, ok := run.(backend.Interrupter); ok {
+			if err := i.Interrupt(); err == nil {
+				return
+			}
+		}
+	}
+	run.Cancel()
+}
+
+// close stops the running turn and waits for it to finish.
+func (s *wsSession) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	if s.busy {
+		s.stopReason = wsStatusCancelled
+	}
+	run := s.run
+	s.mu.Unlock()
+
+	if run != nil {
+		run.Cancel()
+	}
+	s.turns.Wait()
+}
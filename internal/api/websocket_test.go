@@ -0,0 +1,134 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialSession(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/v1/sessions/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntilStatus collects events until a status other than running.
+func readUntilStatus(t *testing.T, conn *websocket.Conn) []models.SessionEvent {
+	t.Helper()
+	var events []models.SessionEvent
+	for {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		var ev models.SessionEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatalf("read after %+v: %v", events, err)
+		}
+		events = append(events, ev)
+		if ev.Type == "status" && ev.Status != "running" {
+			return events
+		}
+	}
+}
+
+func eventTypes(events []models.SessionEvent) string {
+	var types []string
+	for _, ev := range events {
+		t := ev.Type
+		if ev.Type == "status" {
+			t += ":" + ev.Status
+		}
+		types = append(types, t)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestWebSocketSessionTurns(t *testing.T) {
+	srv := newTestServer(t, nil)
+	conn := dialSession(t, srv.URL)
+
+	var created models.SessionEvent
+	if err := conn.ReadJSON(&created); err != nil || created.Type != "session.created" {
+		t.Fatalf("first event = %+v, %v", created, err)
+	}
+
+	conn.WriteJSON(models.SessionClientMessage{Type: "user_message", Content: "[scenario:tools] look"})
+	first := readUntilStatus(t, conn)
+	want := "status:running,text_delta,tool_call,tool_result,text_delta,usage,status:idle"
+	if got := eventTypes(first); got != want {
+		t.Fatalf("events = %s\nwant     %s", got, want)
+	}
+	sessionID := first[len(first)-1].SessionID
+	if sessionID == "" {
+		t.Fatal("idle status carries no session_id")
+	}
+
+	// A follow-up turn resumes the same Claude session
+	conn.WriteJSON(models.SessionClientMessage{Type: "user_message", Content: "and now?"})
+	second := readUntilStatus(t, conn)
+	if last := second[len(second)-1]; last.SessionID != sessionID {
+		t.Errorf("follow-up session_id = %q, want %q", last.SessionID, sessionID)
+	}
+	if second[1].Text != "You said: and now?" {
+		t.Errorf("follow-up text = %q", second[1].Text)
+	}
+}
+
+func TestWebSocketInterruptAndQueue(t *testing.T) {
+	srv := newTestServer(t, nil)
+	conn := dialSession(t, srv.URL)
+
+	var created models.SessionEvent
+	conn.ReadJSON(&created)
+
+	conn.WriteJSON(models.SessionClientMessage{Type: "user_message", Content: "[scenario:slow] wait"})
+	conn.WriteJSON(models.SessionClientMessage{Type: "user_message", Content: "queued"})
+
+	// Interrupt once the slow turn has produced output
+	var events []models.SessionEvent
+	for {
+		var ev models.SessionEvent
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+		if ev.Type == "text_delta" {
+			break
+		}
+	}
+	start := time.Now()
+	conn.WriteJSON(models.SessionClientMessage{Type: "interrupt"})
+
+	events = append(events, readUntilStatus(t, conn)...)
+	if last := events[len(events)-1]; last.Status != "interrupted" {
+		t.Fatalf("events = %s, want interrupted status", eventTypes(events))
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("interrupt did not stop the slow turn")
+	}
+	for _, ev := range events {
+		if ev.Type == "error" {
+			t.Errorf("interrupt reported as error: %+v", ev)
+		}
+	}
+
+	// The queued message runs after the interrupted turn
+	queued := readUntilStatus(t, conn)
+	if queued[len(queued)-1].Status != "idle" || queued[1].Text != "You said: queued" {
+		t.Errorf("queued turn = %+v", queued)
+	}
+
+	conn.WriteJSON(models.SessionClientMessage{Type: "cancel"})
+	var ev models.SessionEvent
+	conn.ReadJSON(&ev)
+	if ev.Type != "error" || ev.Code != "no_active_turn" {
+		t.Errorf("cancel while idle = %+v", ev)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/models"
+)
+
+func projectChatBody(project, prompt string, stream bool) string {
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":      "claude-sonnet-4-5-20250929",
+		"project_id": project,
+		"stream":     stream,
+		"messages":   []map[string]string{{"role": "user", "content": prompt}},
+	})
+	return string(body)
+}
+
+func chatChanges(t *testing.T, url, body string) *backend.WorkspaceChanges {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var out models.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.WorkspaceChanges
+}
+
+func TestWorkspaceChanges(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": root, "WORKSPACE_CHANGES": "true"})
+
+	dir := filepath.Join(root, "wc")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("old notes\n"), 0o644)
+
+	// Runs that change nothing report an empty change set
+	if changes := chatChanges(t, srv.URL, projectChatBody("wc", "hello", false)); changes == nil || len(changes.Files) != 0 || changes.Diff != "" {
+		t.Fatalf("changes for unchanged run = %+v", changes)
+	}
+
+	changes := chatChanges(t, srv.URL, projectChatBody("wc", "[scenario:edit] new notes", false))
+	if changes == nil {
+		t.Fatal("workspace_changes missing")
+	}
+	want := []backend.FileChange{
+		{Path: "docs/log.txt", Status: "added"},
+		{Path: "notes.md", Status: "modified"},
+	}
+	if len(changes.Files) != len(want) || changes.Files[0] != want[0] || changes.Files[1] != want[1] {
+		t.Errorf("files = %+v", changes.Files)
+	}
+	for _, s := range []string{
+		"--- /dev/null\n+++ b/docs/log.txt\n",
+		"+edited\n",
+		"--- a/notes.md\n+++ b/notes.md\n",
+		"-old notes\n+[scenario:edit] new notes\n",
+	} {
+		if !strings.Contains(changes.Diff, s) {
+			t.Errorf("diff missing %q:\n%s", s, changes.Diff)
+		}
+	}
+	if changes.Truncated {
+		t.Error("diff truncated")
+	}
+
+	// Streaming responses carry the changes as a named event before [DONE]
+	resp := postChat(t, srv, projectChatBody("wc", "[scenario:edit] streamed", true))
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := string(raw)
+	idx := strings.Index(stream, "event: workspace_changes\ndata: ")
+	if idx < 0 || idx > strings.Index(stream, "data: [DONE]") {
+		t.Fatalf("stream lacks workspace_changes before [DONE]:\n%s", stream)
+	}
+	line := stream[idx+len("event: workspace_changes\ndata: "):]
+	line = line[:strings.Index(line, "\n")]
+	var streamed backend.WorkspaceChanges
+	if err := json.Unmarshal([]byte(line), &streamed); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed.Files) != 1 || streamed.Files[0] != (backend.FileChange{Path: "notes.md", Status: "modified"}) {
+		t.Errorf("streamed files = %+v", streamed.Files)
+	}
+}
+
+func TestWorkspaceChangesLimits(t *testing.T) {
+	root := t.TempDir()
+	srv := newTestServer(t, map[string]string{
+		"PROJECT_ROOT":             root,
+		"WORKSPACE_CHANGES":        "true",
+		"WORKSPACE_DIFF_MAX_BYTES": "64",
+	})
+
+	dir := filepath.Join(root, "lim")
+	os.MkdirAll(filepath.Join(dir, "docs"), 0o755)
+	os.WriteFile(filepath.Join(dir, "docs", "log.txt"), []byte{0, 1, 2}, 0o644)
+
+	changes := chatChanges(t, srv.URL, projectChatBody("lim", "[scenario:edit] a prompt long enough to overflow the diff limit", false))
+	if changes == nil || len(changes.Files) != 2 {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if f := changes.Files[0]; f.Path != "docs/log.txt" || f.Status != "modified" || !f.Binary {
+		t.Errorf("binary file = %+v", f)
+	}
+	if !strings.Contains(changes.Diff, "Binary files a/docs/log.txt and b/docs/log.txt differ") {
+		t.Errorf("diff = %q", changes.Diff)
+	}
+	if f := changes.Files[1]; f.Path != "notes.md" || !f.DiffOmitted {
+		t.Errorf("oversized file = %+v", f)
+	}
+	if !changes.Truncated {
+		t.Error("truncated = false")
+	}
+}
+
+func TestWorkspaceChangesOff(t *testing.T) {
+	srv := newTestServer(t, map[string]string{"PROJECT_ROOT": t.TempDir()})
+	if changes := chatChanges(t, srv.URL, projectChatBody("off", "[scenario:edit] x", false)); changes != nil {
+		t.Errorf("workspace_changes = %+v", changes)
+	}
+}
@@ -0,0 +1,142 @@
+// Package backend defines the interface between HTTP handlers and the agent
+// runners that execute requests.
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrBusy is returned by CreateSession when a backend has no free capacity.
+var ErrBusy = errors.New("backend at capacity")
+
+// Request describes a single agent run.
+type Request struct {
+	RequestID    string
+	Model        string
+	Prompt       string
+	SystemPrompt string
+	ProjectID    string
+	ProjectPath  string
+
+	// Principal identifies the caller; empty when auth is off.
+	Principal string
+	// Namespace is the namespace ProjectID belongs to. Handlers set the
+	// caller's namespace; resolving the project replaces it with the
+	// namespace of a shared project.
+	Namespace string
+
+	// ReadOnly disables tool use for the run.
+	ReadOnly bool
+
+	// ResumeSessionID continues an earlier session instead of starting anew.
+	ResumeSessionID string
+}
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// Event types emitted by a Run.
+const (
+	EventInit       EventType = "init"
+	EventText       EventType = "text"
+	EventToolCall   EventType = "tool_call"
+	EventToolResult EventType = "tool_result"
+	EventResult     EventType = "result"
+	EventError      EventType = "error"
+
+	// EventWorkspaceChanges is emitted after the run when workspace change
+	// tracking is on.
+	EventWorkspaceChanges EventType = "workspace_changes"
+)
+
+// ToolCall is a tool invocation made by the agent.
+type ToolCall struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ToolResult is the output of a tool invocation.
+type ToolResult struct {
+	ToolUseID string `json:"tool_use_id"`
+	Content   string `json:"content"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// Usage reports token consumption and cost of a run.
+type Usage struct {
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	DurationMs               int     `json:"duration_ms"`
+}
+
+// PromptTokens returns all input tokens including cache reads and writes.
+func (u Usage) PromptTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// FileChange is a file a run added, modified or deleted.
+type FileChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // added, modified or deleted
+	// Binary files are listed without a diff
+	Binary bool `json:"binary,omitempty"`
+	// DiffOmitted is set for files too large to diff
+	DiffOmitted bool `json:"diff_omitted,omitempty"`
+}
+
+// WorkspaceChanges describes what a run changed in its project directory.
+type WorkspaceChanges struct {
+	Files []FileChange `json:"files"`
+	// Diff is a unified diff of the text files
+	Diff string `json:"diff"`
+	// Truncated is set when files or diff hunks were left out to respect
+	// the size caps
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Event is a typed message produced by a Run.
+type Event struct {
+	Type       EventType         `json:"type"`
+	SessionID  string            `json:"session_id,omitempty"`
+	Model      string            `json:"model,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	ToolCall   *ToolCall         `json:"tool_call,omitempty"`
+	ToolResult *ToolResult       `json:"tool_result,omitempty"`
+	Usage      *Usage            `json:"usage,omitempty"`
+	Changes    *WorkspaceChanges `json:"workspace_changes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Run is a single in-flight agent execution.
+type Run interface {
+	// SessionID returns the backend session ID, empty until known.
+	SessionID() string
+	// Events streams the run's events; the channel is closed when the run ends.
+	Events() <-chan Event
+	// Cancel stops the run.
+	Cancel()
+	// Usage returns the usage reported so far.
+	Usage() Usage
+}
+
+// Interrupter is implemented by runs that can stop their current turn
+// gracefully, leaving the session resumable, rather than being killed by
+// Cancel.
+type Interrupter interface {
+	Interrupt() error
+}
+
+// Backend executes agent runs.
+type Backend interface {
+	Name() string
+	GetVersion() (string, error)
+	CreateSession(ctx context.Context, req Request) (Run, error)
+	ActiveSessionCount() int
+	CleanupAll()
+}
@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Echo is an in-process backend that replies with the prompt it was given.
+// It needs no external dependencies and is useful for smoke tests and demos.
+type Echo struct {
+	active atomic.Int64
+	mu     sync.Mutex
+	runs   map[*echoRun]struct{}
+}
+
+// NewEcho creates an echo backend.
+func NewEcho() *Echo {
+	return &Echo{runs: make(map[*echoRun]struct{})}
+}
+
+// Name returns "echo".
+func (e *Echo) Name() string { return "echo" }
+
+// GetVersion returns a fixed version string.
+func (e *Echo) GetVersion() (string, error) { return "echo", nil }
+
+// CreateSession starts a run that echoes req.Prompt.
+func (e *Echo) CreateSession(ctx context.Context, req Request) (Run, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &echoRun{
+		sessionID: uuid.New().String(),
+		events:    make(chan Event, 4),
+		cancel:    cancel,
+	}
+
+	e.mu.Lock()
+	e.runs[run] = struct{}{}
+	e.mu.Unlock()
+	e.active.Add(1)
+
+	go func() {
+		defer func() {
+			close(run.events)
+			e.mu.Lock()
+			delete(e.runs, run)
+			e.mu.Unlock()
+			e.active.Add(-1)
+		}()
+
+		start := time.Now()
+		words := len(strings.Fields(req.Prompt))
+		usage := Usage{InputTokens: words, OutputTokens: words}
+
+		events := []Event{
+			{Type: EventInit, SessionID: run.sessionID, Model: req.Model},
+			{Type: EventText, SessionID: run.sessionID, Text: req.Prompt},
+		}
+		for _, ev := range events {
+			select {
+			case run.events <- ev:
+			case <-ctx.Done():
+				run.events <- Event{Type: EventError, SessionID: run.sessionID, Error: ctx.Err().Error()}
+				return
+			}
+		}
+
+		usage.DurationMs = int(time.Since(start).Milliseconds())
+		run.setUsage(usage)
+		run.events <- Event{Type: EventResult, SessionID: run.sessionID, Text: req.Prompt, Usage: &usage}
+	}()
+
+	return run, nil
+}
+
+// ActiveSessionCount returns the number of running echo sessions.
+func (e *Echo) ActiveSessionCount() int { return int(e.active.Load()) }
+
+// CleanupAll cancels all running echo sessions.
+func (e *Echo) CleanupAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for run := range e.runs {
+		run.Cancel()
+	}
+}
+
+type echoRun struct {
+	sessionID string
+	events    chan Event
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	usage     Usage
+}
+
+func (r *echoRun) SessionID() string    { return r.sessionID }
+func (r *echoRun) Events() <-chan Event { return r.events }
+func (r *echoRun) Cancel()              { r.cancel() }
+
+func (r *echoRun) Usage() Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+func (r *echoRun) setUsage(u Usage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage = u
+}
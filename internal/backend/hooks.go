@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Result summarises a finished run for hooks.
+type Result struct {
+	SessionID string
+	Usage     Usage
+	Error     string
+}
+
+// Hook extends every run started through a Registry.
+type Hook interface {
+	// Start is called before a run starts. The returned Finish, if not nil,
+	// is called once the run has ended.
+	Start(req Request) Finish
+}
+
+// Guard is implemented by hooks that may refuse a run. Admit is called for
+// every guard before any hook starts; an error is returned from
+// CreateSession as is.
+type Guard interface {
+	Admit(req Request) error
+}
+
+// Releaser is implemented by guards that reserve something for a run in
+// Admit. Release is called when the run does not start after all, because
+// a later guard refused it or the backend failed; otherwise the guard's
+// Finish is responsible for giving the reservation back.
+type Releaser interface {
+	Release(req Request)
+}
+
+// Finish is called after a run's last event, before its event channel
+// closes. Events it returns are delivered as the run's final events.
+type Finish func(res Result) []Event
+
+// hookedBackend runs the registry's hooks around its backend's runs.
+type hookedBackend struct {
+	Backend
+	hooks []Hook
+	runs  *sync.WaitGroup
+}
+
+// admit asks every guard among hooks to admit req. If one refuses, the
+// guards admitted before it are released and its error returned; otherwise
+// the returned func releases them all.
+func admit(hooks []Hook, req Request) (release func(), err error) {
+	var admitted []Guard
+	release = func() {
+		for _, g := range admitted {
+			if r, ok := g.(Releaser); ok {
+				r.Release(req)
+			}
+		}
+	}
+	for _, h := range hooks {
+		if g, ok := h.(Guard); ok {
+			if err := g.Admit(req); err != nil {
+				release()
+				return nil, err
+			}
+			admitted = append(admitted, g)
+		}
+	}
+	return release, nil
+}
+
+// start calls every hook's Start and returns the Finish funcs.
+func start(hooks []Hook, req Request) []Finish {
+	var finish []Finish
+	for _, h := range hooks {
+		if f := h.Start(req); f != nil {
+			finish = append(finish, f)
+		}
+	}
+	return finish
+}
+
+func (b hookedBackend) CreateSession(ctx context.Context, req Request) (Run, error) {
+	release, err := admit(b.hooks, req)
+	if err != nil {
+		return nil, err
+	}
+	finish := start(b.hooks, req)
+
+	run, err := b.Backend.CreateSession(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	r := &hookedRun{
+		Run:     run,
+		events:  make(chan Event),
+		abandon: make(chan struct{}),
+	}
+	b.runs.Add(1)
+	go func() {
+		defer b.runs.Done()
+		r.relay(finish)
+	}()
+	return r, nil
+}
+
+// hookedRun relays a run's events and appends the events of its hooks.
+type hookedRun struct {
+	Run
+	events chan Event
+
+	// abandon is closed by Cancel; events are then dropped instead of
+	// waiting for a reader, but hooks still run
+	abandon chan struct{}
+	once    sync.Once
+}
+
+func (r *hookedRun) Events() <-chan Event {
+	return r.events
+}
+
+func (r *hookedRun) Cancel() {
+	r.once.Do(func() { close(r.abandon) })
+	r.Run.Cancel()
+}
+
+// Interrupt forwards to the wrapped run if it supports interrupting.
+func (r *hookedRun) Interrupt() error {
+	if i, ok := r.Run.(Interrupter); ok {
+		return i.Interrupt()
+	}
+	return errors.New("run cannot be interrupted")
+}
+
+func (r *hookedRun) relay(finish []Finish) {
+	defer close(r.events)
+
+	var res Result
+	for ev := range r.Run.Events() {
+		if ev.SessionID != "" {
+			res.SessionID = ev.SessionID
+		}
+		if ev.Type == EventError {
+			res.Error = ev.Error
+		}
+		r.send(ev)
+	}
+	if res.SessionID == "" {
+		res.SessionID = r.Run.SessionID()
+	}
+	res.Usage = r.Run.Usage()
+
+	for _, f := range finish {
+		for _, ev := range f(res) {
+			r.send(ev)
+		}
+	}
+}
+
+func (r *hookedRun) send(ev Event) {
+	select {
+	case r.events <- ev:
+	case <-r.abandon:
+	}
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"claude-code-api/internal/config"
+)
+
+// recorder is a hook and guard that logs its calls.
+type recorder struct {
+	name   string
+	log    *callLog
+	refuse error
+	events []Event
+	result Result
+}
+
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+func (l *callLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+func (h *recorder) Admit(req Request) error {
+	h.log.add(h.name + ".admit")
+	return h.refuse
+}
+
+func (h *recorder) Release(req Request) {
+	h.log.add(h.name + ".release")
+}
+
+func (h *recorder) Start(req Request) Finish {
+	h.log.add(h.name + ".start")
+	return func(res Result) []Event {
+		h.log.add(h.name + ".finish")
+		h.result = res
+		return h.events
+	}
+}
+
+// failing is a backend whose sessions never start.
+type failing struct{ *Echo }
+
+func (failing) Name() string { return "failing" }
+
+func (failing) CreateSession(context.Context, Request) (Run, error) {
+	return nil, errors.New("no binary")
+}
+
+func newTestRegistry(b Backend) *Registry {
+	r := NewRegistry(config.NewReloader(&config.Config{DefaultBackend: b.Name()}))
+	r.Register(b)
+	return r
+}
+
+func drain(t *testing.T, run Run) []Event {
+	t.Helper()
+	var events []Event
+	for ev := range run.Events() {
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestHooks(t *testing.T) {
+	log := &callLog{}
+	first := &recorder{name: "first", log: log}
+	second := &recorder{name: "second", log: log, events: []Event{{Type: EventWorkspaceChanges}}}
+	r := newTestRegistry(NewEcho())
+	r.Use(first)
+	r.Use(second)
+
+	b, _ := r.Default()
+	run, err := b.CreateSession(context.Background(), Request{Prompt: "hello there"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := drain(t, run)
+	if last := events[len(events)-1]; last.Type != EventWorkspaceChanges || events[len(events)-2].Type != EventResult {
+		t.Errorf("events = %+v, want the hook's event after the result", events)
+	}
+
+	want := []string{"first.admit", "second.admit", "first.start", "second.start", "first.finish", "second.finish"}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if res := first.result; res.SessionID != run.SessionID() || res.Usage.InputTokens != 2 || res.Error != "" {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestGuardRefusal(t *testing.T) {
+	log := &callLog{}
+	refused := errors.New("over quota")
+	r := newTestRegistry(NewEcho())
+	r.Use(&recorder{name: "first", log: log})
+	r.Use(&recorder{name: "second", log: log, refuse: refused})
+	r.Use(&recorder{name: "third", log: log})
+
+	b, _ := r.Default()
+	if _, err := b.CreateSession(context.Background(), Request{}); !errors.Is(err, refused) {
+		t.Fatalf("CreateSession = %v", err)
+	}
+	want := []string{"first.admit", "second.admit", "first.release"}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestBackendFailureReleases(t *testing.T) {
+	log := &callLog{}
+	r := newTestRegistry(failing{NewEcho()})
+	r.Use(&recorder{name: "guard", log: log})
+
+	b, _ := r.Default()
+	if _, err := b.CreateSession(context.Background(), Request{}); err == nil {
+		t.Fatal("CreateSession succeeded")
+	}
+	want := []string{"guard.admit", "guard.start", "guard.release"}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	log := &callLog{}
+	h := &recorder{name: "h", log: log}
+	r := newTestRegistry(NewEcho())
+	r.Use(h)
+
+	res := Result{SessionID: "cached", Usage: Usage{OutputTokens: 3}}
+	if err := r.Charge(Request{}, res); err != nil {
+		t.Fatal(err)
+	}
+	if h.result != res {
+		t.Errorf("result = %+v", h.result)
+	}
+	h.refuse = errors.New("busy")
+	if err := r.Charge(Request{}, res); !errors.Is(err, h.refuse) {
+		t.Errorf("refused Charge = %v", err)
+	}
+	want := []string{"h.admit", "h.start", "h.finish", "h.admit"}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	echo := NewEcho()
+	r := NewRegistry(config.NewReloader(&config.Config{
+		DefaultBackend: "echo",
+		Models:         []config.ModelConfig{{ID: "fake", Backend: "failing"}},
+	}))
+	r.Register(echo)
+	r.Register(failing{NewEcho()})
+
+	if b, _ := r.ForModel("any"); b != echo {
+		t.Errorf("ForModel(any) = %v, want the default", b)
+	}
+	if b, err := r.ForModel("fake"); err != nil || b.Name() != "failing" {
+		t.Errorf("ForModel(fake) = %v, %v", b, err)
+	}
+	if _, err := r.Get("missing"); err == nil {
+		t.Error("Get of a missing backend succeeded")
+	}
+}
+
+func TestCleanupAllWaitsForHooks(t *testing.T) {
+	log := &callLog{}
+	r := newTestRegistry(NewEcho())
+	r.Use(&recorder{name: "h", log: log})
+
+	b, _ := r.Default()
+	run, err := b.CreateSession(context.Background(), Request{Prompt: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The caller stops reading, as a handler does on an error
+	run.Cancel()
+	r.CleanupAll()
+	want := []string{"h.admit", "h.start", "h.finish"}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls after CleanupAll = %v, want %v", got, want)
+	}
+}
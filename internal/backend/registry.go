@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"claude-code-api/internal/config"
+)
+
+// Registry holds the available backends and selects one per model.
+type Registry struct {
+	cfg      *config.Reloader
+	mu       sync.RWMutex
+	backends map[string]Backend
+	hooks    []Hook
+
+	// runs counts hooked runs whose hooks have not finished
+	runs sync.WaitGroup
+}
+
+// NewRegistry creates an empty backend registry.
+func NewRegistry(cfg *config.Reloader) *Registry {
+	return &Registry{
+		cfg:      cfg,
+		backends: make(map[string]Backend),
+	}
+}
+
+// Register adds a backend under its name, replacing any previous one.
+func (r *Registry) Register(b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[b.Name()] = b
+}
+
+// Use adds a hook to every run started through the registry's backends.
+func (r *Registry) Use(h Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, h)
+}
+
+// Charge runs the hooks for a run answered without a backend, such as from
+// the response cache, so that it is limited and accounted like any other.
+// Guards may refuse it; otherwise every hook starts and finishes with res as
+// the run's result. Events returned by Finish hooks are dropped.
+func (r *Registry) Charge(req Request, res Result) error {
+	r.mu.RLock()
+	hooks := r.hooks
+	r.mu.RUnlock()
+
+	if _, err := admit(hooks, req); err != nil {
+		return err
+	}
+	for _, f := range start(hooks, req) {
+		f(res)
+	}
+	return nil
+}
+
+// Get returns the backend registered under name.
+func (r *Registry) Get(name string) (Backend, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	b, ok := r.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	if len(r.hooks) > 0 {
+		return hookedBackend{Backend: b, hooks: r.hooks, runs: &r.runs}, nil
+	}
+	return b, nil
+}
+
+// Default returns the backend configured as DEFAULT_BACKEND.
+func (r *Registry) Default() (Backend, error) {
+	return r.Get(r.cfg.Get().DefaultBackend)
+}
+
+// ForModel returns the backend configured for model, falling back to the
+// default backend for models without an explicit entry.
+func (r *Registry) ForModel(model string) (Backend, error) {
+	cfg := r.cfg.Get()
+	for _, m := range cfg.Models {
+		if m.ID == model && m.Backend != "" {
+			return r.Get(m.Backend)
+		}
+	}
+	return r.Get(cfg.DefaultBackend)
+}
+
+// All returns the registered backends ordered by name.
+func (r *Registry) All() []Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]Backend, 0, len(r.backends))
+	for _, b := range r.backends {
+		all = append(all, b)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
+
+// ActiveSessionCount sums active sessions across all backends.
+func (r *Registry) ActiveSessionCount() int {
+	total := 0
+	for _, b := range r.All() {
+		total += b.ActiveSessionCount()
+	}
+	return total
+}
+
+// CleanupAll stops all sessions on all backends and waits for the hooks of
+// the stopped runs to finish.
+func (r *Registry) CleanupAll() {
+	for _, b := range r.All() {
+		b.CleanupAll()
+	}
+	r.runs.Wait()
+}
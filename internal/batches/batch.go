@@ -0,0 +1,242 @@
+// Package batches runs OpenAI-style batches of independent requests read
+// from an uploaded JSONL file.
+package batches
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Status is the lifecycle state of a batch.
+type Status string
+
+// Batch states, as in the OpenAI Batch API.
+const (
+	StatusValidating Status = "validating"
+	StatusFailed     Status = "failed"
+	StatusInProgress Status = "in_progress"
+	StatusFinalizing Status = "finalizing"
+	StatusCompleted  Status = "completed"
+	StatusExpired    Status = "expired"
+	StatusCancelling Status = "cancelling"
+	StatusCancelled  Status = "cancelled"
+)
+
+// Terminal reports whether a batch in this state will not change again.
+func (s Status) Terminal() bool {
+	switch s {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// RequestCounts tracks the requests of a batch.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Error is a validation error of the input file.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Errors lists the validation errors of a failed batch.
+type Errors struct {
+	Object string  `json:"object"`
+	Data   []Error `json:"data"`
+}
+
+// Batch is the persisted state of a batch.
+type Batch struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *Errors           `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           Status            `json:"status"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     int64             `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     int64             `json:"finalizing_at,omitempty"`
+	CompletedAt      int64             `json:"completed_at,omitempty"`
+	FailedAt         int64             `json:"failed_at,omitempty"`
+	ExpiredAt        int64             `json:"expired_at,omitempty"`
+	CancellingAt     int64             `json:"cancelling_at,omitempty"`
+	CancelledAt      int64             `json:"cancelled_at,omitempty"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	// Namespace and Owner identify the caller that created the batch; its
+	// requests run on their behalf
+	Namespace string `json:"namespace,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+}
+
+// RequestLine is a line of the batch input file.
+type RequestLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// ResultResponse is the response recorded for a request.
+type ResultResponse struct {
+	StatusCode int         `json:"status_code"`
+	RequestID  string      `json:"request_id"`
+	Body       interface{} `json:"body"`
+}
+
+// ResultError describes a request that could not be run.
+type ResultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResultLine is a line of the output or error file.
+type ResultLine struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *ResultResponse `json:"response"`
+	Error    *ResultError    `json:"error"`
+}
+
+// Output and error files of a batch in progress, appended as requests finish.
+const (
+	outputFile = "output.jsonl"
+	errorFile  = "errors.jsonl"
+)
+
+func (m *Manager) batchDir(id string) string {
+	return filepath.Join(m.dir, id)
+}
+
+// save writes the batch state atomically.
+func (m *Manager) save(b Batch) error {
+	dir := m.batchDir(b.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create batch dir: %w", err)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "batch.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, "batch.json"))
+}
+
+func (m *Manager) load(id string) (Batch, error) {
+	var b Batch
+	data, err := os.ReadFile(filepath.Join(m.batchDir(id), "batch.json"))
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("corrupt batch %s: %w", id, err)
+	}
+	return b, nil
+}
+
+// parseInput reads and validates the input file. Validation errors are
+// returned as Errors; err is reserved for I/O failures.
+func parseInput(r io.Reader, endpoint string, maxLines int) ([]RequestLine, []Error, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []RequestLine
+	var errs []Error
+	seen := make(map[string]bool)
+	n := 0
+	for scanner.Scan() {
+		n++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var line RequestLine
+		switch err := json.Unmarshal(scanner.Bytes(), &line); {
+		case err != nil:
+			errs = append(errs, Error{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON.", Line: n})
+		case line.CustomID == "":
+			errs = append(errs, Error{Code: "missing_required_parameter", Message: "Missing required parameter: 'custom_id'.", Line: n})
+		case seen[line.CustomID]:
+			errs = append(errs, Error{Code: "duplicate_custom_id", Message: fmt.Sprintf("The custom_id '%s' is used more than once.", line.CustomID), Line: n})
+		case line.Method != "POST":
+			errs = append(errs, Error{Code: "invalid_method", Message: "The method must be 'POST'.", Line: n})
+		case line.URL != endpoint:
+			errs = append(errs, Error{Code: "mismatched_endpoint", Message: fmt.Sprintf("The url must match the batch endpoint '%s'.", endpoint), Line: n})
+		case len(line.Body) == 0:
+			errs = append(errs, Error{Code: "missing_required_parameter", Message: "Missing required parameter: 'body'.", Line: n})
+		default:
+			seen[line.CustomID] = true
+			lines = append(lines, line)
+		}
+
+		if len(errs) >= 100 {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(errs) == 0 && len(lines) == 0 {
+		errs = append(errs, Error{Code: "empty_file", Message: "The input file contains no requests."})
+	}
+	if len(lines) > maxLines {
+		errs = append(errs, Error{Code: "too_many_requests", Message: fmt.Sprintf("The input file contains more than %d requests.", maxLines)})
+	}
+	return lines, errs, nil
+}
+
+// recoverResults reads the results already written for a batch, dropping a
+// torn final line, and returns the custom IDs that are done.
+func (m *Manager) recoverResults(id, name string) (map[string]bool, error) {
+	path := filepath.Join(m.batchDir(id), name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool)
+	valid := 0
+	for valid < len(data) {
+		end := valid
+		for end < len(data) && data[end] != '\n' {
+			end++
+		}
+		if end == len(data) {
+			break // no newline: torn write
+		}
+		var line ResultLine
+		if err := json.Unmarshal(data[valid:end], &line); err != nil {
+			break
+		}
+		done[line.CustomID] = true
+		valid = end + 1
+	}
+
+	if valid < len(data) {
+		if err := os.Truncate(path, int64(valid)); err != nil {
+			return nil, err
+		}
+	}
+	return done, nil
+}
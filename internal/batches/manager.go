@@ -0,0 +1,597 @@
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/files"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Errors returned by Manager.
+var (
+	ErrNotFound = errors.New("batch not found")
+	ErrFinished = errors.New("batch already finished")
+)
+
+// InvalidRequestError reports a batch that cannot be created.
+type InvalidRequestError struct {
+	Param   string
+	Message string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return e.Message
+}
+
+// Endpoints that batches may target.
+var supportedEndpoints = []string{"/v1/chat/completions"}
+
+const (
+	// completionWindow is the only supported completion window.
+	completionWindow = "24h"
+
+	// maxRequests bounds the number of requests in a batch.
+	maxRequests = 50000
+
+	// busyRetryInterval is how often a request retries a backend at capacity.
+	busyRetryInterval = 500 * time.Millisecond
+)
+
+// Executor runs one request of batch b against its endpoint and returns the
+// status code and body the endpoint would have responded with. It returns
+// an error wrapping backend.ErrBusy when the request should be retried
+// later.
+type Executor func(ctx context.Context, b Batch, requestID string, body json.RawMessage) (int, interface{}, error)
+
+// entry is the in-memory state of a batch. All fields are guarded by
+// Manager.mu.
+type entry struct {
+	batch           Batch
+	cancel          context.CancelFunc
+	cancelRequested bool
+}
+
+// Manager runs batches in the background and keeps their state on disk.
+// Unfinished batches are resumed on restart, skipping requests whose results
+// were already written.
+type Manager struct {
+	cfg   *config.Reloader
+	files *files.Store
+	exec  Executor
+	dir   string
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	batches map[string]*entry
+}
+
+// NewManager creates a batch manager storing batches under BATCHES_DIR and
+// resumes the unfinished batches found there.
+func NewManager(cfg *config.Reloader, store *files.Store, exec Executor) (*Manager, error) {
+	dir := cfg.Get().BatchesDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create batches dir: %w", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		cfg:     cfg,
+		files:   store,
+		exec:    exec,
+		dir:     dir,
+		ctx:     ctx,
+		stop:    stop,
+		batches: make(map[string]*entry),
+	}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batches dir: %w", err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		b, err := m.load(d.Name())
+		if err != nil {
+			log.Warn().Err(err).Str("batch_id", d.Name()).Msg("Skipping unreadable batch")
+			continue
+		}
+		e := &entry{batch: b, cancelRequested: b.Status == StatusCancelling}
+		m.batches[b.ID] = e
+		if !b.Status.Terminal() {
+			log.Info().Str("batch_id", b.ID).Str("status", string(b.Status)).Msg("Resuming batch")
+			m.start(e)
+		}
+	}
+
+	return m, nil
+}
+
+// Create validates the request and starts a batch for owner in namespace
+// ns. The input file must belong to ns.
+func (m *Manager) Create(ns, owner, inputFileID, endpoint, window string, metadata map[string]string) (Batch, error) {
+	supported := false
+	for _, ep := range supportedEndpoints {
+		supported = supported || ep == endpoint
+	}
+	if !supported {
+		return Batch{}, &InvalidRequestError{Param: "endpoint", Message: fmt.Sprintf("Unsupported endpoint '%s'. Supported: %s.", endpoint, strings.Join(supportedEndpoints, ", "))}
+	}
+	if window != completionWindow {
+		return Batch{}, &InvalidRequestError{Param: "completion_window", Message: "completion_window must be '24h'."}
+	}
+	f, err := m.files.Get(inputFileID)
+	if err == nil && f.Namespace != ns {
+		err = files.ErrNotFound
+	}
+	if errors.Is(err, files.ErrNotFound) {
+		return Batch{}, &InvalidRequestError{Param: "input_file_id", Message: fmt.Sprintf("File '%s' not found.", inputFileID)}
+	}
+	if err != nil {
+		return Batch{}, err
+	}
+	if f.Purpose != files.PurposeBatch {
+		return Batch{}, &InvalidRequestError{Param: "input_file_id", Message: "The input file must be uploaded with purpose 'batch'."}
+	}
+
+	now := time.Now()
+	b := Batch{
+		ID:               "batch_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:           "batch",
+		Endpoint:         endpoint,
+		InputFileID:      inputFileID,
+		CompletionWindow: window,
+		Status:           StatusValidating,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+		Metadata:         metadata,
+		Namespace:        ns,
+		Owner:            owner,
+	}
+	if err := m.save(b); err != nil {
+		return Batch{}, err
+	}
+
+	e := &entry{batch: b}
+	m.mu.Lock()
+	m.batches[b.ID] = e
+	m.mu.Unlock()
+
+	log.Info().Str("batch_id", b.ID).Str("input_file_id", inputFileID).Msg("Batch created")
+	m.start(e)
+	return b, nil
+}
+
+// Get returns the current state of a batch of namespace ns.
+func (m *Manager) Get(ns, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.batches[id]
+	if !ok || e.batch.Namespace != ns {
+		return Batch{}, ErrNotFound
+	}
+	return e.batch, nil
+}
+
+// List returns up to limit batches of namespace ns, newest first, starting
+// after the batch with ID after. It reports whether more batches follow.
+func (m *Manager) List(ns, after string, limit int) ([]Batch, bool) {
+	m.mu.Lock()
+	list := make([]Batch, 0, len(m.batches))
+	for _, e := range m.batches {
+		if e.batch.Namespace == ns {
+			list = append(list, e.batch)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID > list[j].ID
+	})
+	if after != "" {
+		for i, b := range list {
+			if b.ID == after {
+				list = list[i+1:]
+				break
+			}
+		}
+	}
+	if len(list) > limit {
+		return list[:limit], true
+	}
+	return list, false
+}
+
+// Cancel stops a batch of namespace ns. Requests in flight are abandoned;
+// results written so far are kept in the output files.
+func (m *Manager) Cancel(ns, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.batches[id]
+	if !ok || e.batch.Namespace != ns {
+		return Batch{}, ErrNotFound
+	}
+	if e.batch.Status.Terminal() || e.batch.Status == StatusFinalizing {
+		return e.batch, ErrFinished
+	}
+	if e.batch.Status != StatusCancelling {
+		e.cancelRequested = true
+		e.batch.Status = StatusCancelling
+		e.batch.CancellingAt = time.Now().Unix()
+		m.saveLocked(e)
+		if e.cancel != nil {
+			e.cancel()
+		}
+	}
+	return e.batch, nil
+}
+
+// Shutdown stops all batches and waits for them to exit. Unfinished batches
+// resume on the next start.
+func (m *Manager) Shutdown() {
+	m.stop()
+	m.wg.Wait()
+}
+
+func (m *Manager) start(e *entry) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.mu.Lock()
+	e.cancel = cancel
+	if e.cancelRequested {
+		cancel()
+	}
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(ctx, e)
+}
+
+func (m *Manager) saveLocked(e *entry) {
+	if err := m.save(e.batch); err != nil {
+		log.Error().Err(err).Str("batch_id", e.batch.ID).Msg("Failed to save batch")
+	}
+}
+
+// update applies fn to the batch and persists it.
+func (m *Manager) update(e *entry, fn func(b *Batch)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&e.batch)
+	m.saveLocked(e)
+}
+
+func (m *Manager) snapshot(e *entry) Batch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return e.batch
+}
+
+func (m *Manager) run(ctx context.Context, e *entry) {
+	defer m.wg.Done()
+
+	b := m.snapshot(e)
+	lines, verrs, err := m.readInput(b)
+	if err != nil {
+		verrs = []Error{{Code: "input_file_unreadable", Message: err.Error()}}
+	}
+	if len(verrs) > 0 {
+		m.update(e, func(b *Batch) {
+			b.Status = StatusFailed
+			b.FailedAt = time.Now().Unix()
+			b.Errors = &Errors{Object: "list", Data: verrs}
+		})
+		log.Warn().Str("batch_id", b.ID).Int("errors", len(verrs)).Msg("Batch input invalid")
+		return
+	}
+
+	// Skip requests finished before a restart
+	done, err := m.recoverResults(b.ID, outputFile)
+	if err != nil {
+		m.fail(e, err)
+		return
+	}
+	failed, err := m.recoverResults(b.ID, errorFile)
+	if err != nil {
+		m.fail(e, err)
+		return
+	}
+
+	m.update(e, func(b *Batch) {
+		if b.Status == StatusValidating {
+			b.Status = StatusInProgress
+			b.InProgressAt = time.Now().Unix()
+		}
+		b.RequestCounts = RequestCounts{Total: len(lines), Completed: len(done), Failed: len(failed)}
+	})
+
+	out, err := newResultWriter(filepath.Join(m.batchDir(b.ID), outputFile), filepath.Join(m.batchDir(b.ID), errorFile))
+	if err != nil {
+		m.fail(e, err)
+		return
+	}
+	defer out.Close()
+
+	var pending []RequestLine
+	for _, line := range lines {
+		if !done[line.CustomID] && !failed[line.CustomID] {
+			pending = append(pending, line)
+		}
+	}
+
+	expired := m.process(ctx, e, b, pending, out)
+
+	if m.ctx.Err() != nil && !m.snapshot(e).Status.Terminal() {
+		// Shutting down; the batch resumes on the next start
+		return
+	}
+	m.finalize(e, out, expired)
+}
+
+func (m *Manager) readInput(b Batch) ([]RequestLine, []Error, error) {
+	_, r, err := m.files.Open(b.InputFileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	return parseInput(r, b.Endpoint, maxRequests)
+}
+
+func (m *Manager) fail(e *entry, err error) {
+	log.Error().Err(err).Str("batch_id", e.batch.ID).Msg("Batch failed")
+	m.update(e, func(b *Batch) {
+		b.Status = StatusFailed
+		b.FailedAt = time.Now().Unix()
+		b.Errors = &Errors{Object: "list", Data: []Error{{Code: "server_error", Message: err.Error()}}}
+	})
+}
+
+// process runs the pending requests on a bounded pool of workers. It reports
+// whether the batch expired before all requests were dispatched; the
+// undispatched requests are then recorded as expired.
+func (m *Manager) process(ctx context.Context, e *entry, b Batch, pending []RequestLine, out *resultWriter) bool {
+	workers := m.cfg.Get().BatchWorkers()
+	work := make(chan RequestLine)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range work {
+				result, ok := m.runRequest(ctx, b, line)
+				if !ok {
+					continue
+				}
+				m.record(e, out, result)
+			}
+		}()
+	}
+
+	expired := false
+	expiresAt := time.Unix(b.ExpiresAt, 0)
+dispatch:
+	for i, line := range pending {
+		if time.Now().After(expiresAt) {
+			expired = true
+			for _, rest := range pending[i:] {
+				m.record(e, out, ResultLine{
+					ID:       newRequestID(),
+					CustomID: rest.CustomID,
+					Error:    &ResultError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."},
+				})
+			}
+			break
+		}
+		select {
+		case work <- line:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+	return expired
+}
+
+func newRequestID() string {
+	return "batch_req_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// runRequest executes a request, retrying while the backend is busy. It
+// returns false if the batch was stopped before the request finished.
+func (m *Manager) runRequest(ctx context.Context, b Batch, line RequestLine) (ResultLine, bool) {
+	id := newRequestID()
+	for {
+		status, body, err := m.exec(ctx, b, id, line.Body)
+		if ctx.Err() != nil {
+			return ResultLine{}, false
+		}
+		if errors.Is(err, backend.ErrBusy) {
+			select {
+			case <-time.After(busyRetryInterval):
+				continue
+			case <-ctx.Done():
+				return ResultLine{}, false
+			}
+		}
+
+		result := ResultLine{ID: id, CustomID: line.CustomID}
+		if err != nil {
+			result.Error = &ResultError{Code: "server_error", Message: err.Error()}
+		} else {
+			result.Response = &ResultResponse{StatusCode: status, RequestID: id, Body: body}
+		}
+		return result, true
+	}
+}
+
+// record writes a result and updates the request counts.
+func (m *Manager) record(e *entry, out *resultWriter, result ResultLine) {
+	ok := result.Error == nil && result.Response.StatusCode < 400
+	if err := out.Write(result, ok); err != nil {
+		log.Error().Err(err).Str("batch_id", e.batch.ID).Msg("Failed to write batch result")
+		return
+	}
+	m.update(e, func(b *Batch) {
+		if ok {
+			b.RequestCounts.Completed++
+		} else {
+			b.RequestCounts.Failed++
+		}
+	})
+}
+
+// finalize publishes the output and error files and ends the batch.
+func (m *Manager) finalize(e *entry, out *resultWriter, expired bool) {
+	m.update(e, func(b *Batch) {
+		if b.Status != StatusCancelling {
+			b.Status = StatusFinalizing
+			b.FinalizingAt = time.Now().Unix()
+		}
+	})
+
+	b := m.snapshot(e)
+	outputID, err := m.publish(b, outputFile, out.outputLines)
+	if err != nil {
+		m.fail(e, err)
+		return
+	}
+	errorID, err := m.publish(b, errorFile, out.errorLines)
+	if err != nil {
+		m.fail(e, err)
+		return
+	}
+
+	m.update(e, func(b *Batch) {
+		b.OutputFileID = outputID
+		b.ErrorFileID = errorID
+		now := time.Now().Unix()
+		switch {
+		case e.cancelRequested:
+			b.Status = StatusCancelled
+			b.CancelledAt = now
+		case expired:
+			b.Status = StatusExpired
+			b.ExpiredAt = now
+		default:
+			b.Status = StatusCompleted
+			b.CompletedAt = now
+		}
+	})
+
+	b = m.snapshot(e)
+	log.Info().
+		Str("batch_id", b.ID).
+		Str("status", string(b.Status)).
+		Int("completed", b.RequestCounts.Completed).
+		Int("failed", b.RequestCounts.Failed).
+		Msg("Batch finished")
+}
+
+// publish stores a results file in the file store, in the batch's
+// namespace. Empty results produce no file.
+func (m *Manager) publish(b Batch, name string, lines int) (string, error) {
+	if lines == 0 {
+		return "", nil
+	}
+	r, err := os.Open(filepath.Join(m.batchDir(b.ID), name))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	filename := fmt.Sprintf("%s_%s", b.ID, name)
+	f, err := m.files.Create(b.Namespace, filename, files.PurposeBatchOutput, r, 1<<62)
+	if err != nil {
+		return "", err
+	}
+	return f.ID, nil
+}
+
+// resultWriter appends result lines to the output and error files.
+type resultWriter struct {
+	mu          sync.Mutex
+	output      *os.File
+	errors      *os.File
+	outputLines int
+	errorLines  int
+}
+
+func newResultWriter(outputPath, errorPath string) (*resultWriter, error) {
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	errs, err := os.OpenFile(errorPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		output.Close()
+		return nil, err
+	}
+
+	w := &resultWriter{output: output, errors: errs}
+	w.outputLines = countLines(outputPath)
+	w.errorLines = countLines(errorPath)
+	return w, nil
+}
+
+func countLines(path string) int {
+	data, _ := os.ReadFile(path)
+	n := 0
+	for _, c := range data {
+		if c == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+// Write appends a result to the output file if ok, else to the error file.
+func (w *resultWriter) Write(result ResultLine, ok bool) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ok {
+		w.outputLines++
+		_, err = w.output.Write(data)
+	} else {
+		w.errorLines++
+		_, err = w.errors.Write(data)
+	}
+	return err
+}
+
+// Close closes both files.
+func (w *resultWriter) Close() error {
+	err := w.output.Close()
+	if cerr := w.errors.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+// Package cache provides a bounded in-memory response cache.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+)
+
+// Entry is a cached completion.
+type Entry struct {
+	Content   string
+	Usage     backend.Usage
+	SessionID string
+	CreatedAt time.Time
+}
+
+func (e Entry) size() int {
+	return len(e.Content) + len(e.SessionID) + 128
+}
+
+// Cache is an LRU cache with a TTL and limits on entry count and total size.
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	items      map[string]*list.Element
+	order      *list.List
+}
+
+type item struct {
+	key   string
+	entry Entry
+}
+
+// New creates a cache. Zero limits disable the corresponding bound.
+func New(ttl time.Duration, maxEntries, maxBytes int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the entry for key if present and not expired.
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := el.Value.(*item)
+	if c.ttl > 0 && time.Since(it.entry.CreatedAt) > c.ttl {
+		c.remove(el)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(el)
+	return it.entry, true
+}
+
+// Put stores an entry, evicting the least recently used entries as needed.
+func (c *Cache) Put(key string, e Entry) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&item{key: key, entry: e})
+	c.bytes += e.size()
+
+	for c.order.Len() > 0 &&
+		((c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	it := el.Value.(*item)
+	c.order.Remove(el)
+	delete(c.items, it.key)
+	c.bytes -= it.entry.size()
+}
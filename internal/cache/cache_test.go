@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetAndPut(t *testing.T) {
+	c := New(time.Minute, 0, 0)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("hit in an empty cache")
+	}
+
+	c.Put("a", Entry{Content: "one", SessionID: "s1"})
+	c.Put("a", Entry{Content: "two", SessionID: "s2"})
+	e, ok := c.Get("a")
+	if !ok || e.Content != "two" || e.SessionID != "s2" || e.CreatedAt.IsZero() {
+		t.Errorf("Get = %+v, %v", e, ok)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(time.Minute, 0, 0)
+	c.Put("old", Entry{Content: "x", CreatedAt: time.Now().Add(-2 * time.Minute)})
+	c.Put("new", Entry{Content: "y"})
+
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("fresh entry missing")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want the expired entry removed", c.Len())
+	}
+
+	// Without a TTL entries never expire
+	c = New(0, 0, 0)
+	c.Put("old", Entry{CreatedAt: time.Now().Add(-24 * time.Hour)})
+	if _, ok := c.Get("old"); !ok {
+		t.Error("entry expired without a TTL")
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(time.Minute, 2, 0)
+	c.Put("a", Entry{})
+	c.Put("b", Entry{})
+	c.Get("a")
+	c.Put("c", Entry{})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry kept")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s evicted", key)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	size := Entry{Content: strings.Repeat("x", 100)}.size()
+	c := New(time.Minute, 0, 2*size)
+
+	c.Put("big", Entry{Content: strings.Repeat("x", 3*size)})
+	if c.Len() != 0 {
+		t.Error("entry larger than the cache stored")
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Put(key, Entry{Content: strings.Repeat("x", 100)})
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("oldest entry kept over the size limit")
+	}
+}
@@ -0,0 +1,130 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/models"
+)
+
+// toEvents converts a stream-json message from the CLI into backend events.
+func toEvents(msg models.ClaudeMessage) []backend.Event {
+	switch msg.Type {
+	case "system":
+		if msg.Subtype == "init" {
+			return []backend.Event{{Type: backend.EventInit, SessionID: msg.SessionID, Model: msg.Model}}
+		}
+	case "assistant":
+		if msg.Message == nil {
+			return nil
+		}
+		var events []backend.Event
+		for _, block := range contentBlocks(msg.Message.Content) {
+			switch block["type"] {
+			case "text":
+				if text, _ := block["text"].(string); text != "" {
+					events = append(events, backend.Event{Type: backend.EventText, SessionID: msg.SessionID, Text: text})
+				}
+			case "tool_use":
+				id, _ := block["id"].(string)
+				name, _ := block["name"].(string)
+				input, _ := json.Marshal(block["input"])
+				events = append(events, backend.Event{
+					Type:      backend.EventToolCall,
+					SessionID: msg.SessionID,
+					ToolCall:  &backend.ToolCall{ID: id, Name: name, Input: input},
+				})
+			}
+		}
+		return events
+	case "user":
+		if msg.Message == nil {
+			return nil
+		}
+		var events []backend.Event
+		for _, block := range contentBlocks(msg.Message.Content) {
+			if block["type"] != "tool_result" {
+				continue
+			}
+			id, _ := block["tool_use_id"].(string)
+			isErr, _ := block["is_error"].(bool)
+			events = append(events, backend.Event{
+				Type:       backend.EventToolResult,
+				SessionID:  msg.SessionID,
+				ToolResult: &backend.ToolResult{ToolUseID: id, Content: blockText(block["content"]), IsError: isErr},
+			})
+		}
+		return events
+	case "result":
+		usage := parseUsage(msg)
+		ev := backend.Event{Type: backend.EventResult, SessionID: msg.SessionID, Text: msg.Result, Usage: &usage}
+		if msg.IsError || strings.HasPrefix(msg.Subtype, "error") {
+			errMsg := msg.Error
+			if errMsg == "" {
+				errMsg = msg.Result
+			}
+			if errMsg == "" {
+				errMsg = msg.Subtype
+			}
+			return []backend.Event{ev, {Type: backend.EventError, SessionID: msg.SessionID, Error: errMsg}}
+		}
+		return []backend.Event{ev}
+	}
+	return nil
+}
+
+// contentBlocks normalizes message content into a list of content blocks.
+func contentBlocks(content any) []map[string]interface{} {
+	switch v := content.(type) {
+	case string:
+		return []map[string]interface{}{{"type": "text", "text": v}}
+	case []interface{}:
+		blocks := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				blocks = append(blocks, m)
+			}
+		}
+		return blocks
+	}
+	return nil
+}
+
+// blockText flattens tool result content, which is either a string or a list
+// of text blocks.
+func blockText(content any) string {
+	if s, ok := content.(string); ok {
+		return s
+	}
+	var parts []string
+	for _, block := range contentBlocks(content) {
+		if text, ok := block["text"].(string); ok {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// parseUsage extracts token usage and cost from a result message.
+func parseUsage(msg models.ClaudeMessage) backend.Usage {
+	usage := backend.Usage{
+		InputTokens:              intField(msg.Usage, "input_tokens"),
+		OutputTokens:             intField(msg.Usage, "output_tokens"),
+		CacheCreationInputTokens: intField(msg.Usage, "cache_creation_input_tokens"),
+		CacheReadInputTokens:     intField(msg.Usage, "cache_read_input_tokens"),
+		CostUSD:                  msg.TotalCostUSD,
+		DurationMs:               msg.DurationMs,
+	}
+	if usage.CostUSD == 0 {
+		usage.CostUSD = msg.CostUSD
+	}
+	return usage
+}
+
+func intField(m map[string]interface{}, key string) int {
+	if v, ok := m[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
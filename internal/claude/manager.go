@@ -5,39 +5,83 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 
+	"claude-code-api/internal/backend"
 	"claude-code-api/internal/config"
 	"claude-code-api/internal/models"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
+// BackendName is the registry name of the Claude CLI backend.
+const BackendName = "claude-cli"
+
+// readOnlyDisallowedTools are the built-in tools disabled for read-only runs.
+var readOnlyDisallowedTools = []string{
+	"Bash", "BashOutput", "KillShell", "Edit", "MultiEdit", "Write", "NotebookEdit",
+	"Read", "Glob", "Grep", "WebFetch", "WebSearch", "Task", "TodoWrite", "SlashCommand",
+}
+
 // Process represents a single Claude CLI process.
 type Process struct {
-	SessionID   string
 	ProjectPath string
 	cmd         *exec.Cmd
+	cancel      context.CancelFunc
 	IsRunning   bool
-	Output      chan models.ClaudeMessage
+	sessionID   string
+	usage       backend.Usage
+	events      chan backend.Event
+	onExit      func()
+	interrupted bool
 	mu          sync.Mutex
 }
 
 // Start executes the Claude CLI and captures output.
-func (p *Process) Start(ctx context.Context, cfg *config.Config, prompt, model, systemPrompt string) error {
+// In replay mode a recorded transcript is served instead when one exists.
+func (p *Process) Start(ctx context.Context, cfg *config.Config, req backend.Request) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	args := []string{"-p", prompt}
+	ctx, p.cancel = context.WithCancel(ctx)
 
-	if systemPrompt != "" {
-		args = append(args, "--system-prompt", systemPrompt)
+	if cfg.TranscriptMode == TranscriptReplay {
+		lines, err := openTranscript(cfg.TranscriptDir, req)
+		if err == nil {
+			log.Info().Str("hash", RequestHash(req)).Msg("Replaying Claude transcript")
+			pr, pw := io.Pipe()
+			go replay(ctx, pw, lines, cfg.TranscriptReplayTiming)
+			p.IsRunning = true
+			p.events = make(chan backend.Event, 100)
+			go p.readOutput(ctx, pr, nil, func() error { return nil })
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("Failed to load transcript")
+		}
+		log.Info().Str("hash", RequestHash(req)).Msg("No transcript recorded, running Claude")
+	}
+
+	args := []string{"-p", req.Prompt}
+
+	if req.SystemPrompt != "" {
+		args = append(args, "--system-prompt", req.SystemPrompt)
+	}
+	if req.Model != "" {
+		args = append(args, "--model", req.Model)
+	}
+	if req.ResumeSessionID != "" {
+		args = append(args, "--resume", req.ResumeSessionID)
 	}
-	if model != "" {
-		args = append(args, "--model", model)
+	if req.ReadOnly {
+		args = append(args, "--disallowedTools", strings.Join(readOnlyDisallowedTools, ","))
 	}
 
 	args = append(args,
@@ -51,65 +95,189 @@ func (p *Process) Start(ctx context.Context, cfg *config.Config, prompt, model,
 
 	stdout, err := p.cmd.StdoutPipe()
 	if err != nil {
+		p.cancel()
 		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
 	stderr, err := p.cmd.StderrPipe()
 	if err != nil {
+		p.cancel()
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
+	var rec *recorder
+	if cfg.TranscriptMode == TranscriptRecord {
+		if rec, err = newRecorder(cfg.TranscriptDir, req); err != nil {
+			log.Warn().Err(err).Msg("Transcript recording disabled for session")
+		}
+	}
+
 	if err := p.cmd.Start(); err != nil {
+		p.cancel()
+		if rec != nil {
+			rec.Close()
+		}
 		return fmt.Errorf("failed to start claude: %w", err)
 	}
 
 	p.IsRunning = true
-	p.Output = make(chan models.ClaudeMessage, 100)
+	p.events = make(chan backend.Event, 100)
 
-	// Read stdout JSONL
+	// Log stderr, keeping the last line for error reporting
+	var stderrWG sync.WaitGroup
+	var lastStderr string
+	stderrWG.Add(1)
 	go func() {
-		defer close(p.Output)
-		scanner := bufio.NewScanner(stdout)
+		defer stderrWG.Done()
+		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
+			lastStderr = scanner.Text()
+			log.Debug().Str("stderr", lastStderr).Msg("Claude stderr")
+		}
+	}()
 
-			var msg models.ClaudeMessage
-			if err := json.Unmarshal([]byte(line), &msg); err != nil {
-				log.Warn().Err(err).Str("line", line).Msg("Failed to parse JSONL")
-				continue
-			}
+	wait := func() error {
+		stderrWG.Wait()
+		err := p.cmd.Wait()
+		if err != nil && lastStderr != "" {
+			return fmt.Errorf("%w: %s", err, lastStderr)
+		}
+		return err
+	}
+	go p.readOutput(ctx, stdout, rec, wait)
 
-			// Extract session ID from first message
-			if p.SessionID == "" && msg.SessionID != "" {
-				p.SessionID = msg.SessionID
-			}
+	return nil
+}
+
+// readOutput parses stream-json lines from r into events, then calls wait
+// and reports an error event if the run ended without a result.
+func (p *Process) readOutput(ctx context.Context, r io.Reader, rec *recorder, wait func() error) {
+	defer close(p.events)
+	if p.onExit != nil {
+		defer p.onExit()
+	}
+	defer p.cancel()
+
+	sawResult := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if rec != nil {
+			rec.Record(line)
+		}
 
-			p.Output <- msg
+		var msg models.ClaudeMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			log.Warn().Err(err).Str("line", line).Msg("Failed to parse JSONL")
+			continue
 		}
+
 		p.mu.Lock()
-		p.IsRunning = false
+		// Extract session ID from first message
+		if p.sessionID == "" && msg.SessionID != "" {
+			p.sessionID = msg.SessionID
+		}
+		if msg.Type == "result" {
+			sawResult = true
+			p.usage = parseUsage(msg)
+		}
 		p.mu.Unlock()
-	}()
 
-	// Log stderr
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			log.Debug().Str("stderr", scanner.Text()).Msg("Claude stderr")
+		for _, ev := range toEvents(msg) {
+			p.emit(ctx, ev)
 		}
-	}()
+	}
 
-	// Wait for completion
-	go func() {
-		if err := p.cmd.Wait(); err != nil {
-			log.Error().Err(err).Msg("Claude process exited with error")
+	waitErr := wait()
+	if rec != nil {
+		if err := rec.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to save transcript")
 		}
-	}()
+	}
 
-	return nil
+	p.mu.Lock()
+	p.IsRunning = false
+	sessionID := p.sessionID
+	interrupted := p.interrupted
+	p.mu.Unlock()
+
+	if sawResult {
+		return
+	}
+
+	var errMsg string
+	switch {
+	case interrupted:
+		errMsg = "claude was interrupted"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		errMsg = "claude timed out"
+	case ctx.Err() != nil:
+		errMsg = "claude was cancelled"
+	case waitErr != nil:
+		errMsg = fmt.Sprintf("claude exited: %v", waitErr)
+	default:
+		errMsg = "claude exited without a result"
+	}
+	log.Error().Err(waitErr).Str("error", errMsg).Msg("Claude process exited with error")
+	p.emit(ctx, backend.Event{Type: backend.EventError, SessionID: sessionID, Error: errMsg})
+}
+
+// emit delivers an event unless the run's context is done, so an abandoned
+// run never blocks its reader goroutine.
+func (p *Process) emit(ctx context.Context, ev backend.Event) {
+	select {
+	case p.events <- ev:
+	case <-ctx.Done():
+		// Always try to deliver the final error without blocking
+		if ev.Type == backend.EventError {
+			select {
+			case p.events <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// SessionID returns the Claude session ID once the CLI has reported it.
+func (p *Process) SessionID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sessionID
+}
+
+// Events returns the channel of events produced by the process.
+func (p *Process) Events() <-chan backend.Event {
+	return p.events
+}
+
+// Usage returns the usage reported by the CLI result message.
+func (p *Process) Usage() backend.Usage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.usage
+}
+
+// Cancel terminates the Claude process.
+func (p *Process) Cancel() {
+	p.Stop()
+}
+
+// Interrupt sends the CLI an interrupt signal, ending the current turn the
+// way Ctrl-C does in a terminal. Replayed sessions are cancelled instead.
+func (p *Process) Interrupt() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.interrupted = true
+	if p.cmd == nil || p.cmd.Process == nil {
+		p.cancel()
+		return nil
+	}
+	return p.cmd.Process.Signal(os.Interrupt)
 }
 
 // Stop terminates the Claude process.
@@ -117,6 +285,9 @@ func (p *Process) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.cancel != nil {
+		p.cancel()
+	}
 	if p.cmd != nil && p.cmd.Process != nil {
 		_ = p.cmd.Process.Kill()
 	}
@@ -124,8 +295,9 @@ func (p *Process) Stop() {
 }
 
 // Manager manages multiple Claude processes.
+// It implements backend.Backend.
 type Manager struct {
-	cfg         *config.Config
+	cfg         *config.Reloader
 	processes   map[string]*Process
 	mu          sync.RWMutex
 	version     string
@@ -133,19 +305,24 @@ type Manager struct {
 }
 
 // NewManager creates a new Claude manager.
-func NewManager(cfg *config.Config) *Manager {
+func NewManager(cfg *config.Reloader) *Manager {
 	return &Manager{
 		cfg:       cfg,
 		processes: make(map[string]*Process),
 	}
 }
 
+// Name returns the backend name.
+func (m *Manager) Name() string {
+	return BackendName
+}
+
 // GetVersion returns the Claude CLI version.
 // Caches the result to avoid repeated subprocess calls.
 func (m *Manager) GetVersion() (string, error) {
 	var err error
 	m.versionOnce.Do(func() {
-		cmd := exec.Command(m.cfg.ClaudeBinaryPath, "--version")
+		cmd := exec.Command(m.cfg.Get().ClaudeBinaryPath, "--version")
 		var out []byte
 		out, err = cmd.Output()
 		if err == nil {
@@ -160,28 +337,38 @@ func (m *Manager) GetVersion() (string, error) {
 }
 
 // CreateSession creates and starts a new Claude session.
-func (m *Manager) CreateSession(ctx context.Context, projectPath, prompt, model, systemPrompt string) (*Process, error) {
+func (m *Manager) CreateSession(ctx context.Context, req backend.Request) (backend.Run, error) {
+	cfg := m.cfg.Get()
+
 	m.mu.Lock()
-	if len(m.processes) >= m.cfg.MaxConcurrentSessions {
+	if len(m.processes) >= cfg.MaxConcurrentSessions {
 		m.mu.Unlock()
-		return nil, fmt.Errorf("max concurrent sessions (%d) reached", m.cfg.MaxConcurrentSessions)
+		return nil, fmt.Errorf("%w: max concurrent sessions (%d) reached", backend.ErrBusy, cfg.MaxConcurrentSessions)
 	}
-	m.mu.Unlock()
-
+	key := uuid.New().String()
 	proc := &Process{
-		ProjectPath: projectPath,
+		ProjectPath: req.ProjectPath,
+		onExit:      func() { m.remove(key) },
 	}
+	m.processes[key] = proc
+	m.mu.Unlock()
 
-	if err := proc.Start(ctx, m.cfg, prompt, model, systemPrompt); err != nil {
+	if err := proc.Start(ctx, cfg, req); err != nil {
+		m.remove(key)
 		return nil, err
 	}
 
-	// Don't store - Claude CLI completes immediately
-	log.Info().Str("session_id", proc.SessionID).Msg("Claude session created")
+	log.Info().Str("request_id", req.RequestID).Str("model", req.Model).Msg("Claude session created")
 
 	return proc, nil
 }
 
+func (m *Manager) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.processes, key)
+}
+
 // ActiveSessionCount returns the number of active sessions.
 func (m *Manager) ActiveSessionCount() int {
 	m.mu.RLock()
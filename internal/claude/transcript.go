@@ -0,0 +1,179 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+)
+
+// Transcript modes.
+const (
+	TranscriptOff    = "off"
+	TranscriptRecord = "record"
+	TranscriptReplay = "replay"
+)
+
+// transcriptHeader is the first line of a transcript file.
+type transcriptHeader struct {
+	Hash       string            `json:"hash"`
+	Request    transcriptRequest `json:"request"`
+	RecordedAt time.Time         `json:"recorded_at"`
+}
+
+// transcriptRequest holds the request fields that identify a transcript.
+type transcriptRequest struct {
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	Prompt       string `json:"prompt"`
+	Resume       string `json:"resume,omitempty"`
+	ReadOnly     bool   `json:"read_only,omitempty"`
+}
+
+// transcriptLine is one raw stdout line with its offset from process start.
+type transcriptLine struct {
+	OffsetMs int64  `json:"offset_ms"`
+	Line     string `json:"line"`
+}
+
+func newTranscriptRequest(req backend.Request) transcriptRequest {
+	return transcriptRequest{
+		Model:        req.Model,
+		SystemPrompt: req.SystemPrompt,
+		Prompt:       req.Prompt,
+		Resume:       req.ResumeSessionID,
+		ReadOnly:     req.ReadOnly,
+	}
+}
+
+// RequestHash returns the key under which a request's transcript is stored.
+func RequestHash(req backend.Request) string {
+	data, _ := json.Marshal(newTranscriptRequest(req))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func transcriptPath(dir string, req backend.Request) string {
+	return filepath.Join(dir, RequestHash(req)+".jsonl")
+}
+
+// recorder writes the raw stdout of a session to a transcript file. The file
+// is written under a temporary name and renamed into place when closed, so a
+// replay never sees a partial recording.
+type recorder struct {
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	path  string
+	start time.Time
+}
+
+func newRecorder(dir string, req backend.Request) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create transcript dir: %w", err)
+	}
+
+	path := transcriptPath(dir, req)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript: %w", err)
+	}
+
+	r := &recorder{file: f, w: bufio.NewWriter(f), path: path, start: time.Now()}
+	header, _ := json.Marshal(transcriptHeader{
+		Hash:       RequestHash(req),
+		Request:    newTranscriptRequest(req),
+		RecordedAt: r.start.UTC(),
+	})
+	r.w.Write(append(header, '\n'))
+	return r, nil
+}
+
+// Record appends a raw stdout line.
+func (r *recorder) Record(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, _ := json.Marshal(transcriptLine{OffsetMs: time.Since(r.start).Milliseconds(), Line: line})
+	r.w.Write(append(data, '\n'))
+}
+
+// Close finishes the transcript and moves it into place.
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		return err
+	}
+	if err := r.file.Close(); err != nil {
+		os.Remove(r.file.Name())
+		return err
+	}
+	return os.Rename(r.file.Name(), r.path)
+}
+
+// openTranscript loads the transcript recorded for req.
+func openTranscript(dir string, req backend.Request) ([]transcriptLine, error) {
+	f, err := os.Open(transcriptPath(dir, req))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []transcriptLine
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		var tl transcriptLine
+		if err := json.Unmarshal(scanner.Bytes(), &tl); err != nil {
+			return nil, fmt.Errorf("corrupt transcript %s: %w", f.Name(), err)
+		}
+		lines = append(lines, tl)
+	}
+	return lines, scanner.Err()
+}
+
+// replay writes the recorded lines to w, optionally reproducing the original
+// timing, and closes w when done or when ctx is cancelled.
+func replay(ctx context.Context, w *io.PipeWriter, lines []transcriptLine, timing bool) {
+	start := time.Now()
+	for _, tl := range lines {
+		if timing {
+			wait := time.Duration(tl.OffsetMs)*time.Millisecond - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					w.CloseWithError(ctx.Err())
+					return
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			w.CloseWithError(ctx.Err())
+			return
+		}
+		if _, err := io.WriteString(w, tl.Line+"\n"); err != nil {
+			return
+		}
+	}
+	w.Close()
+}
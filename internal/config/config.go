@@ -2,19 +2,27 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"claude-code-api/internal/keys"
+
 	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds all application configuration.
+//
+// Fields tagged reload:"restart" are only read at startup; a reload that
+// changes them is reported but has no effect until the process restarts.
 type Config struct {
 	// Server settings
-	Host string `envconfig:"HOST" default:"0.0.0.0"`
-	Port int    `envconfig:"PORT" default:"8000"`
+	Host string `envconfig:"HOST" default:"0.0.0.0" reload:"restart"`
+	Port int    `envconfig:"PORT" default:"8000" reload:"restart"`
 
 	// Claude settings
 	ClaudeBinaryPath      string `envconfig:"CLAUDE_BINARY_PATH"`
@@ -23,13 +31,103 @@ type Config struct {
 	SessionTimeoutMinutes int    `envconfig:"SESSION_TIMEOUT_MINUTES" default:"30"`
 	StreamingTimeoutSecs  int    `envconfig:"STREAMING_TIMEOUT_SECONDS" default:"300"`
 
+	// Transcript recording/replay of raw CLI output: off, record or replay
+	TranscriptMode         string `envconfig:"TRANSCRIPT_MODE" default:"off"`
+	TranscriptDir          string `envconfig:"TRANSCRIPT_DIR" default:"/tmp/claude_transcripts"`
+	TranscriptReplayTiming bool   `envconfig:"TRANSCRIPT_REPLAY_TIMING" default:"false"`
+
+	// Response cache for read-only requests
+	ResponseCacheEnabled    bool `envconfig:"RESPONSE_CACHE_ENABLED" default:"false"`
+	ResponseCacheTTLSecs    int  `envconfig:"RESPONSE_CACHE_TTL_SECONDS" default:"3600" reload:"restart"`
+	ResponseCacheMaxEntries int  `envconfig:"RESPONSE_CACHE_MAX_ENTRIES" default:"1000" reload:"restart"`
+	ResponseCacheMaxBytes   int  `envconfig:"RESPONSE_CACHE_MAX_BYTES" default:"16777216" reload:"restart"`
+
+	// Asynchronous tasks
+	TasksDir           string `envconfig:"TASKS_DIR" default:"/tmp/claude_tasks" reload:"restart"`
+	TaskTimeoutMinutes int    `envconfig:"TASK_TIMEOUT_MINUTES" default:"120"`
+	// Finished tasks are dropped TaskRetentionHours after they end; 0 keeps
+	// them forever
+	TaskRetentionHours int `envconfig:"TASK_RETENTION_HOURS" default:"168"`
+
+	// Uploaded files
+	FilesDir     string `envconfig:"FILES_DIR" default:"/tmp/claude_files" reload:"restart"`
+	FileMaxBytes int64  `envconfig:"FILE_MAX_BYTES" default:"104857600"`
+
+	// Batches; BatchConcurrency is capped below MaxConcurrentSessions
+	BatchesDir       string `envconfig:"BATCHES_DIR" default:"/tmp/claude_batches" reload:"restart"`
+	BatchConcurrency int    `envconfig:"BATCH_CONCURRENCY" default:"4"`
+
+	// Usage records of every run, one file per day
+	UsageDir string `envconfig:"USAGE_DIR" default:"/tmp/claude_usage" reload:"restart"`
+
+	// Backend used for models without an explicit backend entry
+	DefaultBackend string `envconfig:"DEFAULT_BACKEND" default:"claude-cli"`
+
 	// Project settings
 	ProjectRoot string `envconfig:"PROJECT_ROOT" default:"/tmp/claude_projects"`
 
+	// Commit each project to git after every run
+	GitCheckpoints bool `envconfig:"GIT_CHECKPOINTS" default:"false"`
+
+	// Disk quotas in bytes, checked before sessions start; 0 is unlimited
+	ProjectQuotaBytes int64 `envconfig:"PROJECT_QUOTA_BYTES" default:"0"`
+	DiskQuotaBytes    int64 `envconfig:"DISK_QUOTA_BYTES" default:"0"`
+
+	// Projects unused for ProjectTTLHours are deleted, or archived to
+	// ProjectArchiveDir first; 0 keeps projects forever
+	ProjectTTLHours          int    `envconfig:"PROJECT_TTL_HOURS" default:"0"`
+	ProjectGCAction          string `envconfig:"PROJECT_GC_ACTION" default:"delete"`
+	ProjectArchiveDir        string `envconfig:"PROJECT_ARCHIVE_DIR" default:"/tmp/claude_project_archives"`
+	ProjectGCIntervalMinutes int    `envconfig:"PROJECT_GC_INTERVAL_MINUTES" default:"60"`
+
+	// Snapshot each project before every session, keeping the newest
+	// SnapshotRetention snapshots per project
+	ProjectSnapshots  bool `envconfig:"PROJECT_SNAPSHOTS" default:"false"`
+	SnapshotRetention int  `envconfig:"SNAPSHOT_RETENTION" default:"10"`
+
+	// Report the files each run changed, with a unified diff capped at
+	// WorkspaceDiffMaxBytes
+	WorkspaceChanges      bool  `envconfig:"WORKSPACE_CHANGES" default:"false"`
+	WorkspaceDiffMaxBytes int64 `envconfig:"WORKSPACE_DIFF_MAX_BYTES" default:"262144"`
+
+	// Template applied to projects created implicitly on first use
+	DefaultProjectTemplate string `envconfig:"DEFAULT_PROJECT_TEMPLATE"`
+
 	// Auth settings
-	APIKeys     []string `envconfig:"API_KEYS"`
+	APIKeys     []string `envconfig:"API_KEYS" reload:"secret"`
 	RequireAuth bool     `envconfig:"REQUIRE_AUTH" default:"false"`
 
+	// Managed API keys with scopes and expiry; empty disables the key store
+	KeysFile string `envconfig:"KEYS_FILE" reload:"restart"`
+
+	// Credentials accepted when auth is required: API keys, JWTs issued by
+	// an identity provider, or any of them
+	AuthMode string `envconfig:"AUTH_MODE" default:"keys" reload:"restart"`
+
+	// JWT verification: signing keys from a JWKS file or URL, cached for
+	// JWKSCacheSecs, and the issuer and audiences tokens must carry
+	JWKSFile      string   `envconfig:"JWT_JWKS_FILE" reload:"restart"`
+	JWKSURL       string   `envconfig:"JWT_JWKS_URL" reload:"restart"`
+	JWKSCacheSecs int      `envconfig:"JWT_JWKS_CACHE_SECONDS" default:"300" reload:"restart"`
+	JWTIssuer     string   `envconfig:"JWT_ISSUER" reload:"restart"`
+	JWTAudience   []string `envconfig:"JWT_AUDIENCE" reload:"restart"`
+	JWTLeewaySecs int      `envconfig:"JWT_LEEWAY_SECONDS" default:"60" reload:"restart"`
+
+	// Rate limits of each API key unless rate_limits in the config file
+	// sets others for it; 0 is unlimited
+	RateLimitRPM          int   `envconfig:"RATE_LIMIT_REQUESTS_PER_MINUTE" default:"0"`
+	RateLimitSessions     int   `envconfig:"RATE_LIMIT_CONCURRENT_SESSIONS" default:"0"`
+	RateLimitTokensPerDay int64 `envconfig:"RATE_LIMIT_TOKENS_PER_DAY" default:"0"`
+
+	// Claims, as dotted paths, naming the caller, its scopes and its
+	// project namespace. Tokens without the scopes claim get
+	// JWTDefaultScopes; without the namespace claim the principal is used.
+	JWTPrincipalClaim string   `envconfig:"JWT_PRINCIPAL_CLAIM" default:"sub"`
+	JWTScopesClaim    string   `envconfig:"JWT_SCOPES_CLAIM" default:"scope"`
+	JWTScopePrefix    string   `envconfig:"JWT_SCOPE_PREFIX"`
+	JWTDefaultScopes  []string `envconfig:"JWT_DEFAULT_SCOPES" default:"chat,models,projects"`
+	JWTNamespaceClaim string   `envconfig:"JWT_NAMESPACE_CLAIM"`
+
 	// CORS settings
 	AllowedOrigins []string `envconfig:"ALLOWED_ORIGINS" default:"*"`
 
@@ -37,10 +135,159 @@ type Config struct {
 	LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
 
 	// Config file path
-	ConfigFile string `envconfig:"CONFIG_FILE" default:"config.yaml"`
+	ConfigFile string `envconfig:"CONFIG_FILE" default:"config.yaml" reload:"restart"`
+
+	// How often the config file is checked for changes (0 disables watching)
+	ConfigWatchSecs int `envconfig:"CONFIG_WATCH_INTERVAL_SECONDS" default:"5" reload:"restart"`
 
 	// Models loaded from config file
 	Models []ModelConfig `ignored:"true"`
+
+	// Project templates by name and the git repositories projects may be
+	// cloned from, loaded from config file
+	ProjectTemplates map[string]string `ignored:"true"`
+	ProjectRepos     []string          `ignored:"true"`
+
+	// Namespaces shared by groups of API keys and the projects shared
+	// across namespaces, loaded from config file
+	KeyGroups      map[string][]string `ignored:"true" reload:"secret"`
+	SharedProjects []SharedProject     `ignored:"true"`
+
+	// Rate limits by principal or key group, loaded from config file
+	RateLimits map[string]RateLimit `ignored:"true"`
+}
+
+// RateLimit overrides the default rate limits for an API key or the keys
+// of a key group. Fields left out keep the default; 0 is unlimited.
+type RateLimit struct {
+	RequestsPerMinute  *int   `yaml:"requests_per_minute"`
+	ConcurrentSessions *int   `yaml:"concurrent_sessions"`
+	TokensPerDay       *int64 `yaml:"tokens_per_day"`
+}
+
+// Limits are the rate limits of one API key; 0 is unlimited.
+type Limits struct {
+	RequestsPerMinute  int
+	ConcurrentSessions int
+	TokensPerDay       int64
+}
+
+// LimitsFor returns the rate limits of the API key with principal p: those
+// set for p, else those of its key group, over the defaults. Each key of a
+// group is limited separately.
+func (c *Config) LimitsFor(p string) Limits {
+	l := Limits{
+		RequestsPerMinute:  c.RateLimitRPM,
+		ConcurrentSessions: c.RateLimitSessions,
+		TokensPerDay:       c.RateLimitTokensPerDay,
+	}
+	r, ok := c.RateLimits[p]
+	if !ok {
+		r, ok = c.RateLimits[c.keyGroup(p)]
+	}
+	if !ok {
+		return l
+	}
+	if r.RequestsPerMinute != nil {
+		l.RequestsPerMinute = *r.RequestsPerMinute
+	}
+	if r.ConcurrentSessions != nil {
+		l.ConcurrentSessions = *r.ConcurrentSessions
+	}
+	if r.TokensPerDay != nil {
+		l.TokensPerDay = *r.TokensPerDay
+	}
+	return l
+}
+
+// keyGroup returns the key group listing the key with principal p, by the
+// key or by its principal, or "".
+func (c *Config) keyGroup(p string) string {
+	for group, members := range c.KeyGroups {
+		for _, m := range members {
+			if m == p || keys.Principal(m) == p {
+				return group
+			}
+		}
+	}
+	return ""
+}
+
+// Authentication modes.
+const (
+	AuthModeKeys = "keys"
+	AuthModeJWT  = "jwt"
+	AuthModeAny  = "any"
+)
+
+// AcceptsKeys reports whether API keys are accepted.
+func (c *Config) AcceptsKeys() bool {
+	return c.AuthMode != AuthModeJWT
+}
+
+// AcceptsJWT reports whether JWTs are accepted.
+func (c *Config) AcceptsJWT() bool {
+	return c.AuthMode == AuthModeJWT || c.AuthMode == AuthModeAny
+}
+
+// Project grants.
+const (
+	GrantRead  = "read"
+	GrantWrite = "write"
+)
+
+// SharedProject grants other namespaces access to a project.
+type SharedProject struct {
+	Namespace string `yaml:"namespace"`
+	Project   string `yaml:"project"`
+	// Grants maps each namespace the project is shared with to read or
+	// write
+	Grants map[string]string `yaml:"grants"`
+}
+
+// namePattern is the grammar of namespace and project names, as enforced
+// by the projects package.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// JWTNamespacePrefix starts the namespaces of JWT callers, keeping them apart
+// from those of API keys and key groups.
+const JWTNamespacePrefix = "jwt_"
+
+// KeyNamespace returns the namespace of the projects of an API key: the
+// key group listing the key or its principal, else the principal itself.
+func (c *Config) KeyNamespace(key, principal string) string {
+	for group, members := range c.KeyGroups {
+		for _, m := range members {
+			if m == key || m == principal {
+				return group
+			}
+		}
+	}
+	return principal
+}
+
+// ProjectGrant returns the access namespace ns has been granted to project
+// id of namespace owner: GrantRead, GrantWrite or "" for none.
+func (c *Config) ProjectGrant(owner, id, ns string) string {
+	for _, s := range c.SharedProjects {
+		if s.Namespace == owner && s.Project == id {
+			return s.Grants[ns]
+		}
+	}
+	return ""
+}
+
+// BatchWorkers returns how many batch requests may run at once. One session
+// is always left free for interactive requests.
+func (c *Config) BatchWorkers() int {
+	n := c.BatchConcurrency
+	if n > c.MaxConcurrentSessions-1 {
+		n = c.MaxConcurrentSessions - 1
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
 }
 
 // ModelConfig represents a model entry in config file.
@@ -48,10 +295,24 @@ type ModelConfig struct {
 	ID          string `yaml:"id"`
 	Name        string `yaml:"name"`
 	Description string `yaml:"description"`
+	Backend     string `yaml:"backend,omitempty"`
 }
 
 // Load loads configuration from environment variables and config file.
 func Load() (*Config, error) {
+	cfg, err := read("")
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.prepare(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// read reads the environment and applies the config file on top of it. The
+// file is configFile when set, CONFIG_FILE otherwise.
+func read(configFile string) (*Config, error) {
 	cfg := &Config{}
 	if err := envconfig.Process("", cfg); err != nil {
 		return nil, err
@@ -62,72 +323,229 @@ func Load() (*Config, error) {
 		cfg.ClaudeBinaryPath = findClaudeBinary()
 	}
 
-	// Ensure project root exists
-	if err := os.MkdirAll(cfg.ProjectRoot, 0755); err != nil {
+	if configFile == "" {
+		configFile = cfg.ConfigFile
+	}
+	if err := cfg.applyFile(configFile); err != nil {
 		return nil, err
 	}
-
-	// Load models from config file
-	cfg.Models = loadModelsFromFile(cfg.ConfigFile)
-
 	return cfg, nil
 }
 
-// ConfigFile represents the structure of config.yaml
-type ConfigFile struct {
-	Models []ModelConfig `yaml:"models"`
+// prepare validates the configuration and creates the project root.
+func (c *Config) prepare() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return os.MkdirAll(c.ProjectRoot, 0755)
 }
 
-// loadModelsFromFile loads models from YAML config file
-func loadModelsFromFile(path string) []ModelConfig {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		// Return default models if file doesn't exist
-		return defaultModels()
+// Validate checks the configuration for values the gateway cannot run with.
+func (c *Config) Validate() error {
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	if c.MaxConcurrentSessions <= 0 {
+		return fmt.Errorf("max concurrent sessions must be positive, got %d", c.MaxConcurrentSessions)
+	}
+	if c.StreamingTimeoutSecs <= 0 {
+		return fmt.Errorf("streaming timeout must be positive, got %d", c.StreamingTimeoutSecs)
+	}
+	if c.TaskTimeoutMinutes <= 0 {
+		return fmt.Errorf("task timeout must be positive, got %d", c.TaskTimeoutMinutes)
+	}
+	if c.TaskRetentionHours < 0 {
+		return fmt.Errorf("task retention must not be negative, got %d", c.TaskRetentionHours)
+	}
+	if c.FileMaxBytes <= 0 {
+		return fmt.Errorf("file size limit must be positive, got %d", c.FileMaxBytes)
+	}
+	if c.ProjectQuotaBytes < 0 || c.DiskQuotaBytes < 0 {
+		return fmt.Errorf("disk quotas must not be negative")
+	}
+	if c.ProjectTTLHours < 0 {
+		return fmt.Errorf("project TTL must not be negative, got %d", c.ProjectTTLHours)
+	}
+	if c.ProjectGCAction != "delete" && c.ProjectGCAction != "archive" {
+		return fmt.Errorf("invalid project GC action %q (want delete or archive)", c.ProjectGCAction)
+	}
+	if c.ProjectGCIntervalMinutes <= 0 {
+		return fmt.Errorf("project GC interval must be positive, got %d", c.ProjectGCIntervalMinutes)
+	}
+	if c.SnapshotRetention <= 0 {
+		return fmt.Errorf("snapshot retention must be positive, got %d", c.SnapshotRetention)
+	}
+	if c.WorkspaceDiffMaxBytes <= 0 {
+		return fmt.Errorf("workspace diff size limit must be positive, got %d", c.WorkspaceDiffMaxBytes)
+	}
+	if c.BatchConcurrency <= 0 {
+		return fmt.Errorf("batch concurrency must be positive, got %d", c.BatchConcurrency)
+	}
+	switch c.TranscriptMode {
+	case "off", "record", "replay":
+	default:
+		return fmt.Errorf("invalid transcript mode %q (want off, record or replay)", c.TranscriptMode)
+	}
+	if c.DefaultBackend == "" {
+		return fmt.Errorf("default backend must not be empty")
+	}
+	if c.ProjectRoot == "" {
+		return fmt.Errorf("project root must not be empty")
+	}
+	for name, dir := range c.ProjectTemplates {
+		if name == "" || !filepath.IsAbs(dir) {
+			return fmt.Errorf("project template %q must be an absolute path, got %q", name, dir)
+		}
+	}
+	for _, repo := range c.ProjectRepos {
+		if !filepath.IsAbs(repo) {
+			return fmt.Errorf("project repository %q must be an absolute path", repo)
+		}
+	}
+	if c.DefaultProjectTemplate != "" {
+		if _, ok := c.ProjectTemplates[c.DefaultProjectTemplate]; !ok {
+			return fmt.Errorf("default project template %q is not configured", c.DefaultProjectTemplate)
+		}
+	}
+	switch c.AuthMode {
+	case AuthModeKeys, AuthModeJWT, AuthModeAny:
+	default:
+		return fmt.Errorf("invalid auth mode %q (want keys, jwt or any)", c.AuthMode)
+	}
+	if c.RequireAuth && c.AuthMode == AuthModeKeys && len(c.APIKeys) == 0 && c.KeysFile == "" {
+		return fmt.Errorf("authentication is required but neither API keys nor a key store are configured")
+	}
+	if c.AcceptsJWT() {
+		if (c.JWKSFile == "") == (c.JWKSURL == "") {
+			return fmt.Errorf("auth mode %s needs exactly one of JWT_JWKS_FILE and JWT_JWKS_URL", c.AuthMode)
+		}
+		if c.JWTIssuer == "" || len(c.JWTAudience) == 0 {
+			return fmt.Errorf("auth mode %s needs JWT_ISSUER and JWT_AUDIENCE", c.AuthMode)
+		}
+		if c.JWKSCacheSecs <= 0 {
+			return fmt.Errorf("JWKS cache time must be positive, got %d", c.JWKSCacheSecs)
+		}
+		if c.JWTLeewaySecs < 0 {
+			return fmt.Errorf("JWT leeway must not be negative, got %d", c.JWTLeewaySecs)
+		}
+		if c.JWTPrincipalClaim == "" {
+			return fmt.Errorf("JWT principal claim must not be empty")
+		}
+	}
+	grouped := make(map[string]string)
+	for group, members := range c.KeyGroups {
+		if !namePattern.MatchString(group) {
+			return fmt.Errorf("invalid key group name %q", group)
+		}
+		// A group named like a key's principal or a JWT namespace would
+		// share that caller's projects
+		if strings.HasPrefix(group, keys.PrincipalPrefix) || strings.HasPrefix(group, JWTNamespacePrefix) {
+			return fmt.Errorf("key group name %q must not start with %q or %q", group, keys.PrincipalPrefix, JWTNamespacePrefix)
+		}
+		for _, m := range members {
+			if other, ok := grouped[m]; ok && other != group {
+				return fmt.Errorf("key listed in key groups %q and %q", other, group)
+			}
+			grouped[m] = group
+		}
+	}
+	shared := make(map[string]bool)
+	for i, s := range c.SharedProjects {
+		if !namePattern.MatchString(s.Namespace) || !namePattern.MatchString(s.Project) {
+			return fmt.Errorf("shared project %d needs a valid namespace and project", i)
+		}
+		if shared[s.Namespace+":"+s.Project] {
+			return fmt.Errorf("project %s:%s is shared twice", s.Namespace, s.Project)
+		}
+		shared[s.Namespace+":"+s.Project] = true
+		for ns, grant := range s.Grants {
+			if grant != GrantRead && grant != GrantWrite {
+				return fmt.Errorf("invalid grant %q for namespace %q on project %s:%s (want read or write)", grant, ns, s.Namespace, s.Project)
+			}
+		}
 	}
 
-	var cf ConfigFile
-	if err := parseYAML(data, &cf); err != nil {
-		return defaultModels()
+	if c.RateLimitRPM < 0 || c.RateLimitSessions < 0 || c.RateLimitTokensPerDay < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	for name, r := range c.RateLimits {
+		if (r.RequestsPerMinute != nil && *r.RequestsPerMinute < 0) ||
+			(r.ConcurrentSessions != nil && *r.ConcurrentSessions < 0) ||
+			(r.TokensPerDay != nil && *r.TokensPerDay < 0) {
+			return fmt.Errorf("rate limits for %q must not be negative", name)
+		}
 	}
 
-	if len(cf.Models) == 0 {
-		return defaultModels()
+	seen := make(map[string]bool)
+	for i, m := range c.Models {
+		if m.ID == "" {
+			return fmt.Errorf("model %d has no id", i)
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("duplicate model id %q", m.ID)
+		}
+		seen[m.ID] = true
 	}
 
-	return cf.Models
+	return nil
+}
+
+// ConfigFile represents the structure of config.yaml
+//
+// Settings present in the file override their environment counterparts.
+type ConfigFile struct {
+	Models         []ModelConfig `yaml:"models"`
+	APIKeys        []string      `yaml:"api_keys"`
+	RequireAuth    *bool         `yaml:"require_auth"`
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+
+	ProjectTemplates       map[string]string `yaml:"project_templates"`
+	ProjectRepos           []string          `yaml:"project_repos"`
+	DefaultProjectTemplate *string           `yaml:"default_project_template"`
+
+	KeyGroups      map[string][]string  `yaml:"key_groups"`
+	SharedProjects []SharedProject      `yaml:"shared_projects"`
+	RateLimits     map[string]RateLimit `yaml:"rate_limits"`
 }
 
-// parseYAML is a simple YAML parser for our config format
-func parseYAML(data []byte, cf *ConfigFile) error {
-	lines := strings.Split(string(data), "\n")
-	var currentModel *ModelConfig
+// applyFile loads the config file at path and merges it into cfg.
+// A missing file is not an error; the default models are used instead.
+func (c *Config) applyFile(path string) error {
+	c.Models = defaultModels()
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
 		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
 
-		if strings.HasPrefix(line, "- id:") {
-			if currentModel != nil {
-				cf.Models = append(cf.Models, *currentModel)
-			}
-			currentModel = &ModelConfig{
-				ID: strings.TrimSpace(strings.TrimPrefix(line, "- id:")),
-			}
-		} else if currentModel != nil {
-			if strings.HasPrefix(line, "name:") {
-				currentModel.Name = strings.TrimSpace(strings.TrimPrefix(line, "name:"))
-			} else if strings.HasPrefix(line, "description:") {
-				currentModel.Description = strings.TrimSpace(strings.TrimPrefix(line, "description:"))
-			}
-		}
+	var cf ConfigFile
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 
-	if currentModel != nil {
-		cf.Models = append(cf.Models, *currentModel)
+	if len(cf.Models) > 0 {
+		c.Models = cf.Models
+	}
+	if len(cf.APIKeys) > 0 {
+		c.APIKeys = cf.APIKeys
+	}
+	if cf.RequireAuth != nil {
+		c.RequireAuth = *cf.RequireAuth
+	}
+	if len(cf.AllowedOrigins) > 0 {
+		c.AllowedOrigins = cf.AllowedOrigins
+	}
+	c.ProjectTemplates = cf.ProjectTemplates
+	c.ProjectRepos = cf.ProjectRepos
+	if cf.DefaultProjectTemplate != nil {
+		c.DefaultProjectTemplate = *cf.DefaultProjectTemplate
 	}
+	c.KeyGroups = cf.KeyGroups
+	c.SharedProjects = cf.SharedProjects
+	c.RateLimits = cf.RateLimits
 
 	return nil
 }
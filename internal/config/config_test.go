@@ -0,0 +1,20 @@
+package config
+
+import "testing"
+
+func TestValidateKeyGroups(t *testing.T) {
+	r, _ := newTestReloader(t)
+	for group, valid := range map[string]bool{
+		"team":      true,
+		"keystone":  true,
+		"key_team":  false,
+		"jwt_team":  false,
+		"../escape": false,
+	} {
+		cfg := *r.Get()
+		cfg.KeyGroups = map[string][]string{group: {"sk-one"}}
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("key group %q: Validate = %v", group, err)
+		}
+	}
+}
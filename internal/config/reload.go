@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Change describes a single setting that differs between two configurations.
+type Change struct {
+	Field           string
+	Old             string
+	New             string
+	RestartRequired bool
+}
+
+// Reloader holds the live configuration and swaps it atomically on reload.
+// Handlers and middlewares call Get on every request so they always see the
+// most recently validated configuration.
+type Reloader struct {
+	current atomic.Pointer[Config]
+	mu      sync.Mutex
+	modTime time.Time
+	loader  func(configFile string) (*Config, error)
+}
+
+// NewReloader creates a reloader serving cfg until the first reload.
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{loader: read}
+	r.current.Store(cfg)
+	r.modTime = fileModTime(cfg.ConfigFile)
+	return r
+}
+
+// Get returns the current configuration.
+func (r *Reloader) Get() *Config {
+	return r.current.Load()
+}
+
+// Reload re-reads the environment and config file, validates the result and
+// swaps it in. On error the previous configuration stays active. Changes to
+// fields tagged reload:"restart" are reported but the running values are
+// kept, and the config file read is always the one the process started with.
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.current.Load()
+	r.modTime = fileModTime(old.ConfigFile)
+
+	next, err := r.loader(old.ConfigFile)
+	var changes []Change
+	if err == nil {
+		changes = Diff(old, next)
+		keepRestartFields(old, next)
+		err = next.prepare()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping previous configuration")
+		return nil, err
+	}
+
+	r.current.Store(next)
+	SetLogLevel(next.LogLevel)
+
+	if len(changes) == 0 {
+		log.Info().Msg("Config reloaded, no changes")
+		return nil, nil
+	}
+	for _, ch := range changes {
+		event := log.Info()
+		if ch.RestartRequired {
+			event = log.Warn().Bool("restart_required", true)
+		}
+		event.Str("field", ch.Field).Str("old", ch.Old).Str("new", ch.New).Msg("Config changed")
+	}
+	return changes, nil
+}
+
+// Watch polls the config file and reloads it whenever its modification time
+// changes. It returns when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			mt := fileModTime(r.current.Load().ConfigFile)
+			changed := !mt.Equal(r.modTime)
+			r.mu.Unlock()
+
+			if changed {
+				log.Info().Str("file", r.current.Load().ConfigFile).Msg("Config file changed, reloading")
+				_, _ = r.Reload()
+			}
+		}
+	}
+}
+
+// Diff lists the settings that differ between old and next.
+func Diff(old, next *Config) []Change {
+	var changes []Change
+
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	t := ov.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		a, b := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+
+		ch := Change{
+			Field:           field.Name,
+			Old:             formatValue(a),
+			New:             formatValue(b),
+			RestartRequired: field.Tag.Get("reload") == "restart",
+		}
+		if field.Tag.Get("reload") == "secret" {
+			ch.Old = fmt.Sprintf("%d entries", ov.Field(i).Len())
+			ch.New = fmt.Sprintf("%d entries", nv.Field(i).Len())
+		}
+		changes = append(changes, ch)
+	}
+
+	return changes
+}
+
+// keepRestartFields copies the fields only read at startup from old into
+// next, so that next describes the settings actually in effect.
+func keepRestartFields(old, next *Config) {
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	t := ov.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("reload") == "restart" {
+			nv.Field(i).Set(ov.Field(i))
+		}
+	}
+}
+
+// SetLogLevel applies the configured log level.
+func SetLogLevel(level string) {
+	switch level {
+	case "debug":
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case "warn":
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case "error":
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	default:
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+}
+
+func formatValue(v interface{}) string {
+	if models, ok := v.([]ModelConfig); ok {
+		ids := make([]string, len(models))
+		for i, m := range models {
+			ids[i] = m.ID
+		}
+		return fmt.Sprintf("%v", ids)
+	}
+	if limits, ok := v.(map[string]RateLimit); ok {
+		return fmt.Sprintf("%d entries", len(limits))
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestReloader loads a configuration from a scratch environment whose
+// config file lists a single model.
+func newTestReloader(t *testing.T) (*Reloader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeConfigFile(t, file, "first-model")
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("PROJECT_ROOT", filepath.Join(dir, "projects"))
+	t.Setenv("CLAUDE_BINARY_PATH", "/bin/true")
+
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return NewReloader(cfg), file
+}
+
+func writeConfigFile(t *testing.T, path, model string) {
+	t.Helper()
+	data := "models:\n  - id: " + model + "\n    name: " + model + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findChange(changes []Change, field string) (Change, bool) {
+	for _, ch := range changes {
+		if ch.Field == field {
+			return ch, true
+		}
+	}
+	return Change{}, false
+}
+
+func TestReloadKeepsRestartFields(t *testing.T) {
+	r, file := newTestReloader(t)
+	before := r.Get()
+
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	writeConfigFile(t, other, "other-model")
+	t.Setenv("CONFIG_FILE", other)
+	t.Setenv("PORT", "9999")
+	t.Setenv("AUTH_MODE", "jwt")
+	t.Setenv("MAX_CONCURRENT_SESSIONS", "3")
+
+	changes, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	for _, field := range []string{"Port", "AuthMode", "ConfigFile"} {
+		ch, ok := findChange(changes, field)
+		if !ok || !ch.RestartRequired {
+			t.Errorf("change to %s = %+v, %v; want reported as requiring a restart", field, ch, ok)
+		}
+	}
+	if ch, ok := findChange(changes, "MaxConcurrentSessions"); !ok || ch.RestartRequired || ch.New != "3" {
+		t.Errorf("change to MaxConcurrentSessions = %+v, %v; want live change to 3", ch, ok)
+	}
+
+	cfg := r.Get()
+	if cfg.Port != before.Port || cfg.AuthMode != before.AuthMode || cfg.ConfigFile != file {
+		t.Errorf("restart fields after reload = %d %q %q, want %d %q %q",
+			cfg.Port, cfg.AuthMode, cfg.ConfigFile, before.Port, before.AuthMode, file)
+	}
+	if cfg.MaxConcurrentSessions != 3 {
+		t.Errorf("MaxConcurrentSessions = %d, want 3", cfg.MaxConcurrentSessions)
+	}
+	if len(cfg.Models) != 1 || cfg.Models[0].ID != "first-model" {
+		t.Errorf("models = %+v, want those of the original config file", cfg.Models)
+	}
+}
+
+func TestReloadReadsConfigFile(t *testing.T) {
+	r, file := newTestReloader(t)
+
+	writeConfigFile(t, file, "second-model")
+	changes, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if ch, ok := findChange(changes, "Models"); !ok || ch.New != "[second-model]" {
+		t.Errorf("change to Models = %+v, %v; want [second-model]", ch, ok)
+	}
+	if got := r.Get().Models[0].ID; got != "second-model" {
+		t.Errorf("model = %q, want second-model", got)
+	}
+
+	// A reload with nothing changed reports nothing
+	changes, err = r.Reload()
+	if err != nil || len(changes) != 0 {
+		t.Errorf("second reload = %+v, %v; want no changes", changes, err)
+	}
+}
+
+func TestReloadAppliesLogLevel(t *testing.T) {
+	r, _ := newTestReloader(t)
+
+	t.Setenv("LOG_LEVEL", "error")
+	if _, err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := zerolog.GlobalLevel(); got != zerolog.ErrorLevel {
+		t.Errorf("log level = %v, want error", got)
+	}
+}
+
+func TestReloadKeepsConfigOnError(t *testing.T) {
+	r, _ := newTestReloader(t)
+	before := r.Get()
+
+	t.Setenv("SNAPSHOT_RETENTION", "0")
+	if _, err := r.Reload(); err == nil {
+		t.Error("reload with an invalid setting succeeded")
+	}
+	t.Setenv("SNAPSHOT_RETENTION", "not-a-number")
+	if _, err := r.Reload(); err == nil {
+		t.Error("reload with an unparsable setting succeeded")
+	}
+	if r.Get() != before {
+		t.Error("configuration replaced by a failed reload")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Config{
+		Port:       8000,
+		APIKeys:    []string{"sk-one"},
+		KeyGroups:  map[string][]string{"team": {"sk-one"}},
+		RateLimits: map[string]RateLimit{},
+		Models:     []ModelConfig{{ID: "a"}},
+	}
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff of equal configs = %+v, want none", changes)
+	}
+
+	next := *old
+	next.Port = 8001
+	next.APIKeys = []string{"sk-one", "sk-two"}
+	next.KeyGroups = map[string][]string{"team": {"sk-one", "sk-two"}, "other": {}}
+	next.RateLimits = map[string]RateLimit{"key_1": {}}
+	next.Models = []ModelConfig{{ID: "a"}, {ID: "b"}}
+	changes := Diff(old, &next)
+
+	want := map[string]Change{
+		"Port":       {Field: "Port", Old: "8000", New: "8001", RestartRequired: true},
+		"APIKeys":    {Field: "APIKeys", Old: "1 entries", New: "2 entries"},
+		"KeyGroups":  {Field: "KeyGroups", Old: "1 entries", New: "2 entries"},
+		"RateLimits": {Field: "RateLimits", Old: "0 entries", New: "1 entries"},
+		"Models":     {Field: "Models", Old: "[a]", New: "[a b]"},
+	}
+	if len(changes) != len(want) {
+		t.Errorf("Diff = %+v, want %d changes", changes, len(want))
+	}
+	for _, ch := range changes {
+		if ch != want[ch.Field] {
+			t.Errorf("change = %+v, want %+v", ch, want[ch.Field])
+		}
+		if strings.Contains(ch.Old+ch.New, "sk-") {
+			t.Errorf("change to %s reveals a key: %+v", ch.Field, ch)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Package files stores uploaded files and files produced by the gateway,
+// such as batch input and output, and tracks files uploaded into project
+// directories.
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/projects"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by Store.
+var (
+	ErrNotFound = errors.New("file not found")
+	ErrTooLarge = errors.New("file exceeds size limit")
+	ErrBadPath  = errors.New("invalid project path")
+)
+
+// File purposes.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+	PurposeProject     = "project"
+)
+
+// File describes a stored file.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Namespace string `json:"namespace,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+// Store keeps files on disk, each as <id>.data with its metadata in
+// <id>.json. Project files keep only their metadata in the store; their
+// content lives at <project root>/<project_id>/<path> in the project root
+// of their namespace, where Claude works on it. Files belong to the
+// namespace they were created in.
+type Store struct {
+	dir         string
+	projectRoot func() string
+	mu          sync.Mutex
+}
+
+// NewStore creates a store under dir. projectRoot returns the current
+// project root, which may change on config reload.
+func NewStore(dir string, projectRoot func() string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create files dir: %w", err)
+	}
+	return &Store{dir: dir, projectRoot: projectRoot}, nil
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".data")
+}
+
+func (s *Store) metaPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// validID guards against IDs that would escape the store directory.
+func validID(id string) bool {
+	return strings.HasPrefix(id, "file-") && !strings.ContainsAny(id, `/\.`)
+}
+
+// Create stores the content of r in namespace ns, failing with ErrTooLarge
+// if it exceeds maxBytes.
+func (s *Store) Create(ns, filename, purpose string, r io.Reader, maxBytes int64) (File, error) {
+	f := File{
+		ID:        "file-" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:    "file",
+		CreatedAt: time.Now().Unix(),
+		Filename:  filepath.Base(filename),
+		Purpose:   purpose,
+		Namespace: ns,
+	}
+
+	tmp, err := os.CreateTemp(s.dir, f.ID+".*.tmp")
+	if err != nil {
+		return File{}, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Read one byte past the limit to detect oversized input
+	n, err := io.Copy(tmp, io.LimitReader(r, maxBytes+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if n > maxBytes {
+		return File{}, ErrTooLarge
+	}
+	f.Bytes = n
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeMeta(f); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp.Name(), s.dataPath(f.ID)); err != nil {
+		os.Remove(s.metaPath(f.ID))
+		return File{}, fmt.Errorf("failed to store file: %w", err)
+	}
+	return f, nil
+}
+
+// CreateInProject writes the content of r to path inside the directory of
+// project projectID of namespace ns, replacing any file already there, and
+// failing with ErrTooLarge if it exceeds maxBytes or ErrBadPath if path
+// escapes the project.
+func (s *Store) CreateInProject(ns, projectID, path string, r io.Reader, maxBytes int64) (File, error) {
+	rel, err := cleanPath(path)
+	if err != nil {
+		return File{}, err
+	}
+	projectDir, err := s.projectDir(ns, projectID)
+	if err != nil {
+		return File{}, err
+	}
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return File{}, fmt.Errorf("failed to create project directory: %w", err)
+	}
+	target := filepath.Join(projectDir, rel)
+	// Intermediate directories may be symlinks created by Claude, so check
+	// the part of the path that exists before creating the rest
+	if !contained(projectDir, existingAncestor(filepath.Dir(target))) {
+		return File{}, ErrBadPath
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return File{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if !contained(projectDir, filepath.Dir(target)) {
+		return File{}, ErrBadPath
+	}
+	if info, err := os.Lstat(target); err == nil && info.IsDir() {
+		return File{}, ErrBadPath
+	}
+
+	f := File{
+		ID:        "file-" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:    "file",
+		CreatedAt: time.Now().Unix(),
+		Filename:  filepath.Base(rel),
+		Purpose:   PurposeProject,
+		Namespace: ns,
+		ProjectID: projectID,
+		Path:      filepath.ToSlash(rel),
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*.tmp")
+	if err != nil {
+		return File{}, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(r, maxBytes+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if n > maxBytes {
+		return File{}, ErrTooLarge
+	}
+	f.Bytes = n
+	os.Chmod(tmp.Name(), 0644)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// An upload to the same path replaces the earlier one
+	existing, err := s.listLocked()
+	if err != nil {
+		return File{}, err
+	}
+	for _, old := range existing {
+		if old.Namespace == f.Namespace && old.ProjectID == f.ProjectID && old.Path == f.Path {
+			os.Remove(s.metaPath(old.ID))
+		}
+	}
+	if err := s.writeMeta(f); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(s.metaPath(f.ID))
+		return File{}, fmt.Errorf("failed to store file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *Store) writeMeta(f File) error {
+	meta, _ := json.Marshal(f)
+	tmp := s.metaPath(f.ID) + ".tmp"
+	if err := os.WriteFile(tmp, meta, 0644); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+	return os.Rename(tmp, s.metaPath(f.ID))
+}
+
+// cleanPath validates a path relative to a project directory.
+func cleanPath(path string) (string, error) {
+	if path == "" || strings.ContainsRune(path, 0) || filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return "", ErrBadPath
+	}
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrBadPath
+	}
+	return rel, nil
+}
+
+// projectDir returns the directory of a project of namespace ns.
+func (s *Store) projectDir(ns, projectID string) (string, error) {
+	dir, err := projects.Resolve(projects.NamespaceRoot(s.projectRoot(), ns), projectID)
+	if err != nil {
+		return "", ErrBadPath
+	}
+	return dir, nil
+}
+
+// contained reports whether path, with symlinks resolved, is inside dir.
+func contained(dir, path string) bool {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(realDir, realPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// existingAncestor returns the deepest of path and its parents that exists.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// projectFilePath returns where the content of a project file lives, or
+// ErrNotFound if it is gone or no longer inside its project.
+func (s *Store) projectFilePath(f File) (string, error) {
+	projectDir, err := s.projectDir(f.Namespace, f.ProjectID)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	rel, err := cleanPath(f.Path)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	target := filepath.Join(projectDir, rel)
+	if !contained(projectDir, target) {
+		return "", ErrNotFound
+	}
+	return target, nil
+}
+
+// Get returns the metadata of a file.
+func (s *Store) Get(id string) (File, error) {
+	if !validID(id) {
+		return File{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.metaPath(id))
+	if os.IsNotExist(err) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("corrupt file metadata %s: %w", id, err)
+	}
+	if f.Purpose == PurposeProject {
+		// Claude may have changed the file since it was uploaded
+		if path, err := s.projectFilePath(f); err == nil {
+			if info, err := os.Stat(path); err == nil {
+				f.Bytes = info.Size()
+			}
+		}
+	}
+	return f, nil
+}
+
+// List returns the files of namespace ns with the given purpose and
+// project, newest first. Empty purpose and project filters match every
+// file.
+func (s *Store) List(ns, purpose, projectID string) ([]File, error) {
+	s.mu.Lock()
+	all, err := s.listLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []File{}
+	for _, f := range all {
+		if f.Namespace == ns && (purpose == "" || f.Purpose == purpose) && (projectID == "" || f.ProjectID == projectID) {
+			if f, err := s.Get(f.ID); err == nil {
+				list = append(list, f)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *Store) listLocked() ([]File, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files dir: %w", err)
+	}
+	var list []File
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var f File
+		if json.Unmarshal(data, &f) == nil {
+			list = append(list, f)
+		}
+	}
+	return list, nil
+}
+
+// Delete removes a file. Deleting a project file also removes it from the
+// project directory.
+func (s *Store) Delete(id string) error {
+	f, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.Purpose == PurposeProject {
+		if path, err := s.projectFilePath(f); err == nil {
+			if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+				if err := os.Remove(path); err != nil {
+					return fmt.Errorf("failed to delete file: %w", err)
+				}
+			}
+		}
+	} else if err := os.Remove(s.dataPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := os.Remove(s.metaPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+	return nil
+}
+
+// Open returns the metadata and content of a file. The caller closes the
+// returned reader.
+func (s *Store) Open(id string) (File, *os.File, error) {
+	f, err := s.Get(id)
+	if err != nil {
+		return File{}, nil, err
+	}
+	path := s.dataPath(id)
+	if f.Purpose == PurposeProject {
+		if path, err = s.projectFilePath(f); err != nil {
+			return File{}, nil, err
+		}
+	}
+	r, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return File{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return File{}, nil, err
+	}
+	info, err := r.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		r.Close()
+		return File{}, nil, ErrNotFound
+	}
+	f.Bytes = info.Size()
+	return f, r, nil
+}
+
+// RenameProject moves the records of the files of a project of namespace ns
+// to the renamed project.
+func (s *Store) RenameProject(ns, projectID, newID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.listLocked()
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if f.Purpose == PurposeProject && f.Namespace == ns && f.ProjectID == projectID {
+			f.ProjectID = newID
+			if err := s.writeMeta(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForgetProject drops the records of the files of a deleted project of
+// namespace ns.
+func (s *Store) ForgetProject(ns, projectID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.listLocked()
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if f.Purpose == PurposeProject && f.Namespace == ns && f.ProjectID == projectID {
+			if err := os.Remove(s.metaPath(f.ID)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
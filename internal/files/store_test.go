@@ -0,0 +1,152 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	root := t.TempDir()
+	s, err := NewStore(t.TempDir(), func() string { return root })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+func readAll(t *testing.T, s *Store, id string) string {
+	t.Helper()
+	_, r, err := s.Open(id)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", id, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestCreate(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	f, err := s.Create("team", "dir/input.jsonl", PurposeBatch, strings.NewReader("hello"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Filename != "input.jsonl" || f.Bytes != 5 || f.Namespace != "team" || !validID(f.ID) {
+		t.Errorf("file = %+v", f)
+	}
+	if got := readAll(t, s, f.ID); got != "hello" {
+		t.Errorf("content = %q", got)
+	}
+
+	if _, err := s.Create("team", "big", PurposeBatch, strings.NewReader("hello world"), 10); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized Create = %v", err)
+	}
+
+	// Files are listed in their namespace only
+	list, err := s.List("team", PurposeBatch, "")
+	if err != nil || len(list) != 1 || list[0].ID != f.ID {
+		t.Errorf("List(team) = %+v, %v", list, err)
+	}
+	if list, _ := s.List("other", "", ""); len(list) != 0 {
+		t.Errorf("List(other) = %+v", list)
+	}
+
+	if err := s.Delete(f.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(f.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v", err)
+	}
+	for _, id := range []string{"file-../../etc/passwd", "other", ""} {
+		if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v", id, err)
+		}
+	}
+}
+
+func TestCreateInProject(t *testing.T) {
+	s, root := newTestStore(t)
+
+	f, err := s.CreateInProject("", "p", "src/main.go", strings.NewReader("package main"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Purpose != PurposeProject || f.ProjectID != "p" || f.Path != "src/main.go" {
+		t.Errorf("file = %+v", f)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "p", "src", "main.go"))
+	if err != nil || string(data) != "package main" {
+		t.Errorf("project file = %q, %v", data, err)
+	}
+
+	// Claude edits show in the size; an upload to the same path replaces
+	// the record
+	os.WriteFile(filepath.Join(root, "p", "src", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	if got, _ := s.Get(f.ID); got.Bytes != 29 {
+		t.Errorf("size after edit = %d", got.Bytes)
+	}
+	again, err := s.CreateInProject("", "p", "src/main.go", strings.NewReader("v2"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(f.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("replaced record = %v", err)
+	}
+
+	// Deleting the file removes it from the project
+	if err := s.Delete(again.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "p", "src", "main.go")); !os.IsNotExist(err) {
+		t.Errorf("project file after Delete: %v", err)
+	}
+}
+
+func TestCreateInProjectRejectsBadPaths(t *testing.T) {
+	s, root := newTestStore(t)
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "p", "dir"), 0755)
+	os.Symlink(outside, filepath.Join(root, "p", "link"))
+
+	for _, path := range []string{"", "/etc/passwd", "..", "../q/x", "a/../../x", "dir", "link/x", "link/sub/x", "nul\x00"} {
+		if _, err := s.CreateInProject("", "p", path, strings.NewReader("x"), 10); !errors.Is(err, ErrBadPath) {
+			t.Errorf("CreateInProject(%q) = %v, want ErrBadPath", path, err)
+		}
+	}
+	if _, err := s.CreateInProject("", "../p", "x", strings.NewReader("x"), 10); !errors.Is(err, ErrBadPath) {
+		t.Errorf("bad project ID = %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside the project: %v", entries)
+	}
+}
+
+func TestProjectRenameAndForget(t *testing.T) {
+	s, root := newTestStore(t)
+	f, _ := s.CreateInProject("team", "old", "a.txt", strings.NewReader("a"), 10)
+	other, _ := s.CreateInProject("other", "old", "a.txt", strings.NewReader("b"), 10)
+
+	os.Rename(filepath.Join(root, ".tenants", "team", "old"), filepath.Join(root, ".tenants", "team", "new"))
+	if err := s.RenameProject("team", "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List("team", "", "new"); len(list) != 1 || list[0].ID != f.ID {
+		t.Errorf("files of the renamed project = %+v", list)
+	}
+	if got, err := s.Get(other.ID); err != nil || got.ProjectID != "old" {
+		t.Errorf("other namespace's file = %+v, %v", got, err)
+	}
+
+	if err := s.ForgetProject("team", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(f.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("forgotten file = %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+// Package jwtauth verifies JWTs issued by an identity provider against the
+// signing keys it publishes as a JWKS.
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidToken is returned for tokens that fail verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+// methods are the signing algorithms accepted.
+var methods = []string{"RS256", "ES256"}
+
+// minRefresh limits how often an unknown key ID makes the JWKS be fetched
+// again, so forged tokens cannot hammer the identity provider.
+const minRefresh = 10 * time.Second
+
+// Options configure a Verifier.
+type Options struct {
+	// JWKSFile or JWKSURL holds the signing keys
+	JWKSFile string
+	JWKSURL  string
+	// CacheTTL is how long keys fetched from JWKSURL are used before they
+	// are fetched again
+	CacheTTL time.Duration
+	Issuer   string
+	// Audience lists the audiences accepted; tokens need one of them
+	Audience []string
+	// Leeway is the clock skew allowed when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// Verifier checks token signatures, issuer, audience and lifetime.
+type Verifier struct {
+	opts   Options
+	client *http.Client
+	parser *jwt.Parser
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	loaded  time.Time
+	modTime time.Time
+}
+
+// New creates a verifier and loads the signing keys.
+func New(opts Options) (*Verifier, error) {
+	v := &Verifier{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(methods),
+			jwt.WithIssuer(opts.Issuer),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(opts.Leeway),
+		),
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.loadLocked(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks token and returns its claims.
+func (v *Verifier) Verify(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	aud, _ := claims.GetAudience()
+	for _, want := range v.opts.Audience {
+		for _, a := range aud {
+			if a == want {
+				return claims, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: token has invalid audience", ErrInvalidToken)
+}
+
+// key finds the key that signed t.
+func (v *Verifier) key(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.staleLocked() {
+		if err := v.loadLocked(); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh JWKS, using cached keys")
+		}
+	}
+	k, ok := v.lookupLocked(kid, t.Method)
+	if !ok && v.opts.JWKSURL != "" && time.Since(v.loaded) >= minRefresh {
+		// The identity provider may have rotated its keys
+		if err := v.loadLocked(); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh JWKS")
+		}
+		k, ok = v.lookupLocked(kid, t.Method)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no signing key %q", kid)
+	}
+	return k, nil
+}
+
+// lookupLocked returns the key with ID kid usable with method. Tokens
+// without a key ID match the only key of the right type.
+func (v *Verifier) lookupLocked(kid string, method jwt.SigningMethod) (crypto.PublicKey, bool) {
+	usable := func(k crypto.PublicKey) bool {
+		switch k.(type) {
+		case *rsa.PublicKey:
+			return method.Alg() == "RS256"
+		case *ecdsa.PublicKey:
+			return method.Alg() == "ES256"
+		}
+		return false
+	}
+	if kid != "" {
+		k, ok := v.keys[kid]
+		return k, ok && usable(k)
+	}
+	var found crypto.PublicKey
+	for _, k := range v.keys {
+		if usable(k) {
+			if found != nil {
+				return nil, false
+			}
+			found = k
+		}
+	}
+	return found, found != nil
+}
+
+// staleLocked reports whether the keys should be loaded again: the file
+// changed, or the keys fetched from the URL are older than the cache TTL.
+func (v *Verifier) staleLocked() bool {
+	if v.opts.JWKSFile != "" {
+		info, err := os.Stat(v.opts.JWKSFile)
+		return err == nil && !info.ModTime().Equal(v.modTime)
+	}
+	return time.Since(v.loaded) >= v.opts.CacheTTL
+}
+
+// loadLocked reads the JWKS. On failure the keys loaded before stay in use.
+func (v *Verifier) loadLocked() error {
+	var data []byte
+	var modTime time.Time
+	if v.opts.JWKSFile != "" {
+		info, err := os.Stat(v.opts.JWKSFile)
+		if err != nil {
+			return fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		if data, err = os.ReadFile(v.opts.JWKSFile); err != nil {
+			return fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		modTime = info.ModTime()
+	} else {
+		var err error
+		if data, err = v.fetch(); err != nil {
+			v.loaded = time.Now()
+			return err
+		}
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	v.loaded = time.Now()
+	v.modTime = modTime
+	return nil
+}
+
+func (v *Verifier) fetch() ([]byte, error) {
+	resp, err := v.client.Get(v.opts.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	return data, nil
+}
+
+// jwk is a JSON Web Key as published in a JWKS.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and P-256 signing keys of a JWKS by key ID.
+// Keys of other types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch {
+		case k.Kty == "RSA":
+			key, err = rsaKey(k)
+		case k.Kty == "EC" && k.Crv == "P-256":
+			key, err = ecKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no RSA or P-256 signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("bad modulus or exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func ecKey(k jwk) (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.X, "="))
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.Y, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("bad coordinates")
+	}
+	if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, errors.New("point is not on P-256")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
@@ -0,0 +1,238 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "gateway"
+)
+
+func jwks(t *testing.T, keys map[string]crypto.Signer) []byte {
+	t.Helper()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	var set []map[string]string
+	for kid, k := range keys {
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": set})
+	return data
+}
+
+func sign(t *testing.T, kid string, key crypto.Signer, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": "alice",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+		} else {
+			base[k] = v
+		}
+	}
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	tok := jwt.NewWithClaims(method, base)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func rsaSigner(t *testing.T) crypto.Signer {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func ecSigner(t *testing.T) crypto.Signer {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, ecKey, other := rsaSigner(t), ecSigner(t), rsaSigner(t)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(file, jwks(t, map[string]crypto.Signer{"r": rsaKey, "e": ecKey}), 0o644)
+
+	v, err := New(Options{JWKSFile: file, Issuer: testIssuer, Audience: []string{"other", testAudience}, Leeway: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tok := range map[string]string{
+		"rsa":           sign(t, "r", rsaKey, nil),
+		"ec":            sign(t, "e", ecKey, nil),
+		"no kid":        sign(t, "", ecKey, nil),
+		"audience list": sign(t, "r", rsaKey, jwt.MapClaims{"aud": []string{"x", testAudience}}),
+		"within leeway": sign(t, "r", rsaKey, jwt.MapClaims{"exp": time.Now().Add(-30 * time.Second).Unix()}),
+	} {
+		claims, err := v.Verify(tok)
+		if err != nil || claims["sub"] != "alice" {
+			t.Errorf("%s: Verify = %v, %v", name, claims, err)
+		}
+	}
+
+	for name, tok := range map[string]string{
+		"unknown key":    sign(t, "r", other, nil),
+		"unknown kid":    sign(t, "x", rsaKey, nil),
+		"expired":        sign(t, "r", rsaKey, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":      sign(t, "r", rsaKey, jwt.MapClaims{"exp": nil}),
+		"wrong issuer":   sign(t, "r", rsaKey, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"wrong audience": sign(t, "r", rsaKey, jwt.MapClaims{"aud": "someone-else"}),
+		"no audience":    sign(t, "r", rsaKey, jwt.MapClaims{"aud": nil}),
+		"not a token":    "abc.def.ghi",
+	} {
+		if _, err := v.Verify(tok); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	// HS256 signed with the public key must not be accepted
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": testIssuer, "aud": testAudience, "exp": time.Now().Add(time.Hour).Unix()})
+	hs.Header["kid"] = "r"
+	tok, _ := hs.SignedString(jwks(t, map[string]crypto.Signer{"r": rsaKey}))
+	if _, err := v.Verify(tok); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("HS256 token: Verify = %v", err)
+	}
+}
+
+func TestKeyFileReloaded(t *testing.T) {
+	old, rotated := rsaSigner(t), ecSigner(t)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(file, jwks(t, map[string]crypto.Signer{"old": old}), 0o644)
+
+	v, err := New(Options{JWKSFile: file, Issuer: testIssuer, Audience: []string{testAudience}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(file, jwks(t, map[string]crypto.Signer{"new": rotated}), 0o644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(file, future, future)
+	if _, err := v.Verify(sign(t, "new", rotated, nil)); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if _, err := v.Verify(sign(t, "old", old, nil)); err == nil {
+		t.Error("removed key still accepted")
+	}
+
+	// A broken file keeps the keys loaded before
+	os.WriteFile(file, []byte("{"), 0o644)
+	os.Chtimes(file, future.Add(time.Minute), future.Add(time.Minute))
+	if _, err := v.Verify(sign(t, "new", rotated, nil)); err != nil {
+		t.Errorf("after a broken JWKS: %v", err)
+	}
+}
+
+func TestKeyURL(t *testing.T) {
+	first, second := rsaSigner(t), rsaSigner(t)
+	var served atomic.Value
+	served.Store(jwks(t, map[string]crypto.Signer{"1": first}))
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(served.Load().([]byte))
+	}))
+	defer srv.Close()
+
+	v, err := New(Options{JWKSURL: srv.URL, CacheTTL: time.Hour, Issuer: testIssuer, Audience: []string{testAudience}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(sign(t, "1", first, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want the keys cached", n)
+	}
+
+	// An unknown key ID refetches, but no more often than minRefresh
+	served.Store(jwks(t, map[string]crypto.Signer{"1": first, "2": second}))
+	if _, err := v.Verify(sign(t, "2", second, nil)); err == nil {
+		t.Error("unknown key accepted before the refresh interval")
+	}
+	v.mu.Lock()
+	v.loaded = time.Now().Add(-minRefresh)
+	v.mu.Unlock()
+	if _, err := v.Verify(sign(t, "2", second, nil)); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":     "{",
+		"no keys":      `{"keys":[]}`,
+		"only oct":     `{"keys":[{"kty":"oct","kid":"h","k":"c2VjcmV0"}]}`,
+		"bad exponent": `{"keys":[{"kty":"RSA","kid":"r","n":"AQAB","e":"AQ"}]}`,
+		"off curve":    `{"keys":[{"kty":"EC","kid":"e","crv":"P-256","x":"` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `","y":"` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `"}]}`,
+	} {
+		if _, err := parseJWKS([]byte(data)); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+
+	// Encryption keys are skipped
+	data := jwks(t, map[string]crypto.Signer{"s": rsaSigner(t)})
+	var set map[string][]map[string]string
+	json.Unmarshal(data, &set)
+	set["keys"] = append(set["keys"], map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"})
+	data, _ = json.Marshal(set)
+	keys, err := parseJWKS(data)
+	if err != nil || len(keys) != 1 || keys["s"] == nil {
+		t.Errorf("parseJWKS = %v, %v", keys, err)
+	}
+}
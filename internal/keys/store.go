@@ -0,0 +1,371 @@
+// Package keys manages API keys: who they belong to, what they may do and
+// until when. Only hashes of the keys are stored.
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Store.
+var (
+	ErrNotFound = errors.New("key not found")
+	ErrInvalid  = errors.New("invalid API key")
+	ErrExpired  = errors.New("API key expired")
+	ErrDisabled = errors.New("API key disabled")
+	// ErrInvalidOptions wraps the reasons a key cannot be created
+	ErrInvalidOptions = errors.New("invalid key options")
+)
+
+// Scopes a key may be granted.
+const (
+	// ScopeChat allows running Claude: chat, messages, responses,
+	// completions, WebSocket sessions, tasks, batches and files
+	ScopeChat = "chat"
+	// ScopeModels allows listing and inspecting models
+	ScopeModels = "models"
+	// ScopeProjects allows managing projects, their files and snapshots
+	ScopeProjects = "projects"
+	// ScopeAdmin allows managing keys and storage
+	ScopeAdmin = "admin"
+)
+
+// AllScopes lists every scope.
+var AllScopes = []string{ScopeChat, ScopeModels, ScopeProjects, ScopeAdmin}
+
+// DefaultScopes are granted to keys created without explicit scopes.
+var DefaultScopes = []string{ScopeChat, ScopeModels, ScopeProjects}
+
+// secretPrefix starts every generated key.
+const secretPrefix = "sk-cc-"
+
+// Key describes an API key. The key itself is only known when it is
+// created.
+type Key struct {
+	ID     string `json:"id"`
+	Object string `json:"object"`
+	Name   string `json:"name"`
+	Owner  string `json:"owner,omitempty"`
+	// Prefix is the start of the key, to help recognise it
+	Prefix    string   `json:"prefix"`
+	Scopes    []string `json:"scopes"`
+	Models    []string `json:"models,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	Disabled  bool     `json:"disabled"`
+	RevokedAt int64    `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether k was granted scope.
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel reports whether k may use model. Keys without a model list
+// may use any model.
+func (k Key) AllowsModel(model string) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	for _, m := range k.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether k has expired at now.
+func (k Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != 0 && now.Unix() >= k.ExpiresAt
+}
+
+// record is what is persisted about a key.
+type record struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+// Options describe a key to create.
+type Options struct {
+	Name      string
+	Owner     string
+	Scopes    []string
+	Models    []string
+	ExpiresAt int64
+}
+
+// PrincipalPrefix starts the principal of every API key.
+const PrincipalPrefix = "key_"
+
+// Principal derives the stable identity of an API key without exposing
+// it. It is also the ID of keys in the store.
+func Principal(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return PrincipalPrefix + hex.EncodeToString(sum[:6])
+}
+
+// Equal compares two keys in constant time.
+func Equal(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store keeps keys in a JSON file. Changes made to the file by another
+// process, such as the keys CLI, are picked up on next use.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	keys    map[string]*record
+	modTime time.Time
+}
+
+// Open opens the key store in path, which is created on first write.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, keys: make(map[string]*record)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// refreshLocked reloads the file if it changed since it was last read.
+func (s *Store) refreshLocked() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.keys = make(map[string]*record)
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read key store: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key store: %w", err)
+	}
+	var file struct {
+		Keys []*record `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("corrupt key store %s: %w", s.path, err)
+	}
+	keys := make(map[string]*record, len(file.Keys))
+	for _, r := range file.Keys {
+		keys[r.ID] = r
+	}
+	s.keys = keys
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// saveLocked writes all keys to the file.
+func (s *Store) saveLocked() error {
+	list := make([]*record, 0, len(s.keys))
+	for _, r := range s.keys {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	data, _ := json.MarshalIndent(map[string]interface{}{"keys": list}, "", "  ")
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create key store dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Create generates a key and returns it with its description. The key is
+// not stored and cannot be recovered later.
+func (s *Store) Create(opts Options) (Key, string, error) {
+	if strings.TrimSpace(opts.Name) == "" {
+		return Key{}, "", fmt.Errorf("%w: name is required", ErrInvalidOptions)
+	}
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return Key{}, "", fmt.Errorf("%w: unknown scope %q (want %s)", ErrInvalidOptions, scope, strings.Join(AllScopes, ", "))
+		}
+	}
+	if opts.ExpiresAt != 0 && opts.ExpiresAt <= time.Now().Unix() {
+		return Key{}, "", fmt.Errorf("%w: expiry must be in the future", ErrInvalidOptions)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	r := &record{
+		Key: Key{
+			ID:        Principal(secret),
+			Object:    "api_key",
+			Name:      opts.Name,
+			Owner:     opts.Owner,
+			Prefix:    secret[:len(secretPrefix)+4],
+			Scopes:    append([]string(nil), scopes...),
+			Models:    opts.Models,
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: opts.ExpiresAt,
+		},
+		Hash: hash(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return Key{}, "", err
+	}
+	if _, ok := s.keys[r.ID]; ok {
+		return Key{}, "", errors.New("key ID collision; try again")
+	}
+	s.keys[r.ID] = r
+	if err := s.saveLocked(); err != nil {
+		delete(s.keys, r.ID)
+		return Key{}, "", err
+	}
+	return r.Key, secret, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns all keys, newest first.
+func (s *Store) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return nil, err
+	}
+	list := make([]Key, 0, len(s.keys))
+	for _, r := range s.keys {
+		list = append(list, r.Key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// Get returns the key with ID id.
+func (s *Store) Get(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return Key{}, err
+	}
+	r, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	return r.Key, nil
+}
+
+// Revoke disables a key for good. The record is kept so its ID stays
+// attributable.
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return Key{}, err
+	}
+	r, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	if !r.Disabled {
+		r.Disabled = true
+		r.RevokedAt = time.Now().Unix()
+		if err := s.saveLocked(); err != nil {
+			return Key{}, err
+		}
+	}
+	return r.Key, nil
+}
+
+// Delete removes a key.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.keys[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.keys, id)
+	return s.saveLocked()
+}
+
+// Verify returns the key matching secret. It fails with ErrInvalid for an
+// unknown key, and with ErrDisabled or ErrExpired for keys that may no
+// longer be used. Hashes are compared in constant time.
+func (s *Store) Verify(secret string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return Key{}, err
+	}
+	r, ok := s.keys[Principal(secret)]
+	if !ok || subtle.ConstantTimeCompare([]byte(r.Hash), []byte(hash(secret))) != 1 {
+		return Key{}, ErrInvalid
+	}
+	if r.Disabled {
+		return Key{}, ErrDisabled
+	}
+	if r.Expired(time.Now()) {
+		return Key{}, ErrExpired
+	}
+	return r.Key, nil
+}
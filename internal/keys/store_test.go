@@ -0,0 +1,138 @@
+package keys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	s, path := openTestStore(t)
+
+	key, secret, err := s.Create(Options{Name: "ci", Owner: "alice", Models: []string{"m1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || !strings.HasPrefix(secret, key.Prefix) || key.ID != Principal(secret) {
+		t.Errorf("key = %+v, secret = %q", key, secret)
+	}
+	if len(key.Scopes) != len(DefaultScopes) || key.HasScope(ScopeAdmin) || !key.HasScope(ScopeChat) {
+		t.Errorf("scopes = %v, want the defaults", key.Scopes)
+	}
+	if !key.AllowsModel("m1") || key.AllowsModel("m2") {
+		t.Errorf("models = %v", key.Models)
+	}
+
+	got, err := s.Verify(secret)
+	if err != nil || got.ID != key.ID {
+		t.Errorf("Verify = %+v, %v", got, err)
+	}
+	if _, err := s.Verify(secret + "x"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify of a wrong key = %v", err)
+	}
+
+	// Only the hash is stored
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("key stored in the clear")
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	s, _ := openTestStore(t)
+	for _, opts := range []Options{
+		{Name: " "},
+		{Name: "x", Scopes: []string{"everything"}},
+		{Name: "x", ExpiresAt: time.Now().Add(-time.Hour).Unix()},
+	} {
+		if _, _, err := s.Create(opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Create(%+v) = %v, want ErrInvalidOptions", opts, err)
+		}
+	}
+}
+
+func TestRevokeAndDelete(t *testing.T) {
+	s, _ := openTestStore(t)
+	key, secret, _ := s.Create(Options{Name: "a"})
+
+	revoked, err := s.Revoke(key.ID)
+	if err != nil || !revoked.Disabled || revoked.RevokedAt == 0 {
+		t.Fatalf("Revoke = %+v, %v", revoked, err)
+	}
+	if _, err := s.Verify(secret); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Verify of a revoked key = %v", err)
+	}
+
+	if err := s.Delete(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v", err)
+	}
+	if err := s.Delete(key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete = %v", err)
+	}
+	if _, err := s.Revoke(key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke after Delete = %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	k := Key{ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	if k.Expired(time.Now()) || !k.Expired(time.Now().Add(2*time.Hour)) {
+		t.Errorf("Expired of %+v is wrong", k)
+	}
+	if (Key{}).Expired(time.Now().Add(100 * 365 * 24 * time.Hour)) {
+		t.Error("key without an expiry expired")
+	}
+}
+
+func TestChangesFromOtherProcesses(t *testing.T) {
+	s, path := openTestStore(t)
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, secret, _ := other.Create(Options{Name: "cli"})
+	if _, err := s.Verify(secret); err != nil {
+		t.Errorf("key created elsewhere not picked up: %v", err)
+	}
+	list, err := s.List()
+	if err != nil || len(list) != 1 || list[0].ID != key.ID {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+
+	// Move the file's mtime on, as filesystems may have coarse timestamps
+	other.Revoke(key.ID)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if _, err := s.Verify(secret); !errors.Is(err, ErrDisabled) {
+		t.Errorf("revocation made elsewhere not picked up: %v", err)
+	}
+}
+
+func TestPrincipalAndEqual(t *testing.T) {
+	if Principal("a") != Principal("a") || Principal("a") == Principal("b") || !strings.HasPrefix(Principal("a"), "key_") {
+		t.Error("Principal is not a stable key_ hash")
+	}
+	if !Equal("secret", "secret") || Equal("secret", "Secret") {
+		t.Error("Equal is wrong")
+	}
+}
@@ -0,0 +1,150 @@
+// Package models defines Anthropic Messages API types.
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"claude-code-api/internal/backend"
+)
+
+// AnthropicContentBlock is a content block in a Messages API message.
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image and document
+	Source *AnthropicSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   any    `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	// thinking
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// AnthropicSource is the source of an image or document block.
+type AnthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicContent is message content: a plain string or a list of blocks.
+type AnthropicContent []AnthropicContentBlock
+
+// UnmarshalJSON accepts both the string and the block list form.
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: s}}
+		return nil
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// Text joins the text of all text and tool_result blocks.
+func (c AnthropicContent) Text() string {
+	var parts []string
+	for _, b := range c {
+		switch b.Type {
+		case "text":
+			if b.Text != "" {
+				parts = append(parts, b.Text)
+			}
+		case "tool_result":
+			switch v := b.Content.(type) {
+			case string:
+				parts = append(parts, v)
+			case []interface{}:
+				for _, item := range v {
+					if m, ok := item.(map[string]interface{}); ok {
+						if text, ok := m["text"].(string); ok {
+							parts = append(parts, text)
+						}
+					}
+				}
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// AnthropicMessage is a message in a Messages API request.
+type AnthropicMessage struct {
+	Role    string           `json:"role" binding:"required,oneof=user assistant"`
+	Content AnthropicContent `json:"content" binding:"required"`
+}
+
+// AnthropicMessagesRequest is the request body for POST /v1/messages.
+type AnthropicMessagesRequest struct {
+	Model         string             `json:"model" binding:"required"`
+	MaxTokens     int                `json:"max_tokens"`
+	Messages      []AnthropicMessage `json:"messages" binding:"required,min=1"`
+	System        *AnthropicContent  `json:"system,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Metadata      map[string]any     `json:"metadata,omitempty"`
+	Tools         []json.RawMessage  `json:"tools,omitempty"`
+	ToolChoice    json.RawMessage    `json:"tool_choice,omitempty"`
+	Thinking      json.RawMessage    `json:"thinking,omitempty"`
+
+	// Extension fields for Claude Code
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// AnthropicUsage contains token usage information.
+type AnthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// AnthropicMessageResponse is the response for POST /v1/messages and the
+// message embedded in the message_start stream event.
+type AnthropicMessageResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+
+	// Extension fields for Claude Code
+	SessionID        string                    `json:"session_id,omitempty"`
+	ProjectID        string                    `json:"project_id,omitempty"`
+	WorkspaceChanges *backend.WorkspaceChanges `json:"workspace_changes,omitempty"`
+}
+
+// AnthropicErrorDetail contains error information.
+type AnthropicErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// AnthropicErrorResponse is the Messages API error body.
+type AnthropicErrorResponse struct {
+	Type  string               `json:"type"`
+	Error AnthropicErrorDetail `json:"error"`
+}
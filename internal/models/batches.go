@@ -0,0 +1,10 @@
+// Package models defines OpenAI Batch API types.
+package models
+
+// CreateBatchRequest is the request body for POST /v1/batches.
+type CreateBatchRequest struct {
+	InputFileID      string            `json:"input_file_id" binding:"required"`
+	Endpoint         string            `json:"endpoint" binding:"required"`
+	CompletionWindow string            `json:"completion_window" binding:"required"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
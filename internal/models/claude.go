@@ -15,6 +15,11 @@ type ClaudeMessage struct {
 	Usage      map[string]interface{} `json:"usage,omitempty"`
 	CostUSD    float64                `json:"cost_usd,omitempty"`
 	DurationMs int                    `json:"duration_ms,omitempty"`
+
+	// Fields reported on the final "result" message
+	IsError      bool    `json:"is_error,omitempty"`
+	NumTurns     int     `json:"num_turns,omitempty"`
+	TotalCostUSD float64 `json:"total_cost_usd,omitempty"`
 }
 
 // ClaudeMessageContent represents the content of a Claude message.
@@ -0,0 +1,62 @@
+// Package models defines OpenAI legacy completions API types.
+package models
+
+import "encoding/json"
+
+// StringList is a field that accepts either a single string or a list of
+// strings.
+type StringList []string
+
+// UnmarshalJSON accepts both the string and the list form.
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// CompletionRequest is the request body for POST /v1/completions.
+type CompletionRequest struct {
+	Model            string     `json:"model"`
+	Prompt           StringList `json:"prompt" binding:"required"`
+	Suffix           string     `json:"suffix,omitempty"`
+	MaxTokens        *int       `json:"max_tokens,omitempty"`
+	Temperature      *float64   `json:"temperature,omitempty"`
+	TopP             *float64   `json:"top_p,omitempty"`
+	Stream           bool       `json:"stream,omitempty"`
+	Echo             bool       `json:"echo,omitempty"`
+	Stop             StringList `json:"stop,omitempty"`
+	FrequencyPenalty *float64   `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64   `json:"presence_penalty,omitempty"`
+	User             string     `json:"user,omitempty"`
+
+	// Extension fields for Claude Code
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// CompletionChoice is a single text completion.
+type CompletionChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// CompletionResponse is the text_completion object, used both for the full
+// response and for streaming chunks.
+type CompletionResponse struct {
+	ID        string               `json:"id"`
+	Object    string               `json:"object"`
+	Created   int64                `json:"created"`
+	Model     string               `json:"model"`
+	Choices   []CompletionChoice   `json:"choices"`
+	Usage     *ChatCompletionUsage `json:"usage,omitempty"`
+	ProjectID string               `json:"project_id,omitempty"`
+}
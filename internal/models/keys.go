@@ -0,0 +1,21 @@
+// Package models defines API key management types.
+package models
+
+import "claude-code-api/internal/keys"
+
+// CreateKeyRequest is the request body for POST /v1/admin/keys.
+type CreateKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Owner  string   `json:"owner,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	Models []string `json:"models,omitempty"`
+	// ExpiresAt is a Unix time; 0 never expires
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// CreateKeyResponse describes a new key together with the key itself,
+// which is shown only once.
+type CreateKeyResponse struct {
+	keys.Key
+	Secret string `json:"key"`
+}
@@ -0,0 +1,119 @@
+// Package models defines Ollama API types.
+package models
+
+// OllamaMessage is a chat message.
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// OllamaChatRequest is the request body for POST /api/chat.
+type OllamaChatRequest struct {
+	Model     string                 `json:"model" binding:"required"`
+	Messages  []OllamaMessage        `json:"messages" binding:"required,min=1"`
+	Stream    *bool                  `json:"stream,omitempty"`
+	Format    any                    `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive any                    `json:"keep_alive,omitempty"`
+
+	// Extension fields for Claude Code
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// OllamaGenerateRequest is the request body for POST /api/generate.
+type OllamaGenerateRequest struct {
+	Model     string                 `json:"model" binding:"required"`
+	Prompt    string                 `json:"prompt"`
+	Suffix    string                 `json:"suffix,omitempty"`
+	System    string                 `json:"system,omitempty"`
+	Stream    *bool                  `json:"stream,omitempty"`
+	Raw       bool                   `json:"raw,omitempty"`
+	Format    any                    `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Context   []int                  `json:"context,omitempty"`
+	KeepAlive any                    `json:"keep_alive,omitempty"`
+
+	// Extension fields for Claude Code
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// OllamaMetrics holds the timing and token counts of a finished request.
+// Durations are in nanoseconds.
+type OllamaMetrics struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// OllamaChatResponse is a /api/chat response or streaming chunk.
+type OllamaChatResponse struct {
+	Model      string         `json:"model"`
+	CreatedAt  string         `json:"created_at"`
+	Message    *OllamaMessage `json:"message,omitempty"`
+	Done       bool           `json:"done"`
+	DoneReason string         `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+// OllamaGenerateResponse is a /api/generate response or streaming chunk.
+type OllamaGenerateResponse struct {
+	Model      string `json:"model"`
+	CreatedAt  string `json:"created_at"`
+	Response   string `json:"response"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+// OllamaModelDetails describes a model's format and family.
+type OllamaModelDetails struct {
+	ParentModel       string   `json:"parent_model"`
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// OllamaModel is an entry of the /api/tags model list.
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt string             `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaTagsResponse is the response for GET /api/tags.
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaShowRequest is the request body for POST /api/show. Older clients
+// send the model as name.
+type OllamaShowRequest struct {
+	Model   string `json:"model"`
+	Name    string `json:"name"`
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+// OllamaShowResponse is the response for POST /api/show.
+type OllamaShowResponse struct {
+	Modelfile    string                 `json:"modelfile"`
+	Parameters   string                 `json:"parameters"`
+	Template     string                 `json:"template"`
+	Details      OllamaModelDetails     `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+	ModifiedAt   string                 `json:"modified_at"`
+}
+
+// OllamaErrorResponse is the Ollama error body.
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -1,7 +1,11 @@
 // Package models defines OpenAI-compatible API types.
 package models
 
-import "strings"
+import (
+	"strings"
+
+	"claude-code-api/internal/backend"
+)
 
 // ChatMessage represents a message in a chat conversation.
 type ChatMessage struct {
@@ -53,6 +57,10 @@ type ChatCompletionRequest struct {
 	ProjectID    string `json:"project_id,omitempty"`
 	SessionID    string `json:"session_id,omitempty"`
 	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// ReadOnly declares the request needs no tool use; the run has tools
+	// disabled and its response may be served from the response cache.
+	ReadOnly bool `json:"read_only,omitempty"`
 }
 
 // ChatCompletionChoice represents a single completion choice.
@@ -79,6 +87,9 @@ type ChatCompletionResponse struct {
 	Usage     ChatCompletionUsage    `json:"usage"`
 	SessionID string                 `json:"session_id,omitempty"`
 	ProjectID string                 `json:"project_id,omitempty"`
+
+	// WorkspaceChanges lists what the run changed in the project
+	WorkspaceChanges *backend.WorkspaceChanges `json:"workspace_changes,omitempty"`
 }
 
 // ChatCompletionChunkDelta represents delta content in streaming.
@@ -0,0 +1,19 @@
+// Package models defines project management types.
+package models
+
+// CreateProjectRequest is the request body for POST /v1/projects. A project
+// ID is generated when ID is empty. The project starts empty unless it names
+// a configured template or an allowlisted repository to clone.
+type CreateProjectRequest struct {
+	ID         string            `json:"id,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Template   string            `json:"template,omitempty"`
+	Repository string            `json:"repository,omitempty"`
+	Ref        string            `json:"ref,omitempty"`
+}
+
+// RenameProjectRequest is the request body for POST
+// /v1/projects/:project_id/rename.
+type RenameProjectRequest struct {
+	ID string `json:"id" binding:"required"`
+}
@@ -0,0 +1,131 @@
+// Package models defines OpenAI Responses API types.
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ResponseInputItem is an item of the input list.
+type ResponseInputItem struct {
+	Type    string `json:"type,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Content any    `json:"content,omitempty"`
+
+	// function_call_output
+	CallID string `json:"call_id,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// Text returns the text of the item.
+func (i ResponseInputItem) Text() string {
+	if i.Type == "function_call_output" {
+		return i.Output
+	}
+	switch v := i.Content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// ResponseInput is the input of a request: a string or a list of items.
+type ResponseInput []ResponseInputItem
+
+// UnmarshalJSON accepts both the string and the item list form.
+func (in *ResponseInput) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*in = ResponseInput{{Type: "message", Role: "user", Content: s}}
+		return nil
+	}
+	var items []ResponseInputItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*in = items
+	return nil
+}
+
+// CreateResponseRequest is the request body for POST /v1/responses.
+type CreateResponseRequest struct {
+	Model              string            `json:"model"`
+	Input              ResponseInput     `json:"input" binding:"required"`
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Tools              []json.RawMessage `json:"tools,omitempty"`
+	User               string            `json:"user,omitempty"`
+
+	// Extension fields for Claude Code
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// ResponseOutputContent is a content part of an output message.
+type ResponseOutputContent struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// ResponseOutputItem is an item of the response output.
+type ResponseOutputItem struct {
+	Type    string                  `json:"type"`
+	ID      string                  `json:"id"`
+	Status  string                  `json:"status"`
+	Role    string                  `json:"role"`
+	Content []ResponseOutputContent `json:"content"`
+}
+
+// ResponseUsage contains token usage information.
+type ResponseUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// ResponseError describes why a response failed.
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Response is the Responses API response object.
+type Response struct {
+	ID                 string               `json:"id"`
+	Object             string               `json:"object"`
+	CreatedAt          int64                `json:"created_at"`
+	Status             string               `json:"status"`
+	Model              string               `json:"model"`
+	Instructions       *string              `json:"instructions"`
+	Output             []ResponseOutputItem `json:"output"`
+	PreviousResponseID *string              `json:"previous_response_id"`
+	Usage              *ResponseUsage       `json:"usage"`
+	Error              *ResponseError       `json:"error"`
+	Metadata           map[string]string    `json:"metadata"`
+
+	// Extension fields for Claude Code
+	SessionID string `json:"session_id,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+}
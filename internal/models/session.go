@@ -0,0 +1,54 @@
+// Package models defines WebSocket session message types.
+package models
+
+import "encoding/json"
+
+// SessionClientMessage is a message sent by the client over a session
+// WebSocket. Type is one of user_message, interrupt or cancel.
+type SessionClientMessage struct {
+	Type         string `json:"type"`
+	Content      string `json:"content,omitempty"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+}
+
+// SessionToolCall is a tool invocation reported to the client.
+type SessionToolCall struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// SessionToolResult is a tool result reported to the client.
+type SessionToolResult struct {
+	ToolUseID string `json:"tool_use_id"`
+	Content   string `json:"content"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// SessionUsage reports the usage of a single turn.
+type SessionUsage struct {
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	DurationMs               int     `json:"duration_ms"`
+}
+
+// SessionEvent is an event sent to the client over a session WebSocket.
+// Type is one of session.created, status, message.queued, text_delta,
+// tool_call, tool_result, usage or error.
+type SessionEvent struct {
+	Type       string             `json:"type"`
+	Status     string             `json:"status,omitempty"`
+	SessionID  string             `json:"session_id,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	ProjectID  string             `json:"project_id,omitempty"`
+	Text       string             `json:"text,omitempty"`
+	ToolCall   *SessionToolCall   `json:"tool_call,omitempty"`
+	ToolResult *SessionToolResult `json:"tool_result,omitempty"`
+	Usage      *SessionUsage      `json:"usage,omitempty"`
+	Queued     int                `json:"queued,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Code       string             `json:"code,omitempty"`
+}
@@ -0,0 +1,12 @@
+// Package models defines asynchronous task API types.
+package models
+
+// CreateTaskRequest is the request body for POST /v1/tasks.
+type CreateTaskRequest struct {
+	Model        string            `json:"model"`
+	Prompt       string            `json:"prompt" binding:"required"`
+	SystemPrompt string            `json:"system_prompt,omitempty"`
+	ProjectID    string            `json:"project_id,omitempty"`
+	SessionID    string            `json:"session_id,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
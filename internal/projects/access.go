@@ -0,0 +1,69 @@
+package projects
+
+import (
+	"strings"
+
+	"claude-code-api/internal/config"
+)
+
+// Access is what a caller may do with a project.
+type Access int
+
+// Access levels, each including the ones before it.
+const (
+	AccessNone Access = iota
+	// AccessRead allows read-only runs and reading the project
+	AccessRead
+	// AccessWrite allows runs, uploads and snapshot management
+	AccessWrite
+	// AccessOwner additionally allows renaming and deleting the project
+	AccessOwner
+)
+
+// Ref names a project in a namespace.
+type Ref struct {
+	Namespace string
+	ID        string
+}
+
+// String returns the reference as a client writes it.
+func (r Ref) String() string {
+	if r.Namespace == "" {
+		return r.ID
+	}
+	return r.Namespace + ":" + r.ID
+}
+
+// ParseRef parses a project reference made by a caller in namespace ns:
+// either a project ID in ns, or namespace:project_id for a project in
+// another namespace. Both parts must match the project ID grammar.
+func ParseRef(ns, ref string) (Ref, error) {
+	r := Ref{Namespace: ns, ID: ref}
+	if owner, id, ok := strings.Cut(ref, ":"); ok {
+		if !ValidID(owner) {
+			return Ref{}, ErrInvalidID
+		}
+		r = Ref{Namespace: owner, ID: id}
+	}
+	if !ValidID(r.ID) {
+		return Ref{}, ErrInvalidID
+	}
+	return r, nil
+}
+
+// AccessTo returns the access a caller in namespace ns has to project r. A
+// namespace owns its projects and reaches other namespaces' projects only
+// through the grants of shared_projects. The empty namespace, used when
+// authentication is off, owns everything.
+func AccessTo(cfg *config.Config, ns string, r Ref) Access {
+	if ns == "" || r.Namespace == ns {
+		return AccessOwner
+	}
+	switch cfg.ProjectGrant(r.Namespace, r.ID, ns) {
+	case config.GrantWrite:
+		return AccessWrite
+	case config.GrantRead:
+		return AccessRead
+	}
+	return AccessNone
+}
@@ -0,0 +1,240 @@
+package projects
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+)
+
+// Limits on tracking workspace changes.
+const (
+	// maxSnapshotBytes bounds the file content a snapshot holds in memory;
+	// beyond it files are compared by size and modification time only
+	maxSnapshotBytes = 64 << 20
+	// maxChangedFiles bounds the files listed in WorkspaceChanges
+	maxChangedFiles = 1000
+	// binarySniffLen is how much of a file is checked for NUL bytes, as git
+	// does
+	binarySniffLen = 8000
+)
+
+// snapFile is the state of a file in a snapshot.
+type snapFile struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+	content []byte // nil when not captured
+	binary  bool
+}
+
+// Snapshot is the state of a project directory at one point in time. The
+// .git directory is left out.
+type Snapshot struct {
+	files map[string]snapFile
+}
+
+// TakeSnapshot records the files under dir, keeping the content of files up
+// to maxFileBytes so their changes can be diffed.
+func TakeSnapshot(dir string, maxFileBytes int64) (*Snapshot, error) {
+	s := &Snapshot{files: make(map[string]snapFile)}
+	var captured int64
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+
+		f := snapFile{size: info.Size(), modTime: info.ModTime(), mode: info.Mode().Type()}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			f.content = []byte(target)
+		case info.Mode().IsRegular():
+			if f.size <= maxFileBytes && captured+f.size <= maxSnapshotBytes {
+				if data, err := os.ReadFile(p); err == nil {
+					f.content = data
+					f.binary = isBinary(data)
+					captured += f.size
+				}
+			}
+		default:
+			return nil
+		}
+		s.files[filepath.ToSlash(rel)] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func isBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+		// Drop a multibyte rune cut off at the end of the sniffed prefix
+		for i := 1; i < utf8.UTFMax && i <= len(sniff); i++ {
+			if utf8.RuneStart(sniff[len(sniff)-i]) {
+				if !utf8.FullRune(sniff[len(sniff)-i:]) {
+					sniff = sniff[:len(sniff)-i]
+				}
+				break
+			}
+		}
+	}
+	return bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(sniff)
+}
+
+// changed reports whether a file differs between two snapshots.
+func (a snapFile) changed(b snapFile) bool {
+	if a.mode != b.mode {
+		return true
+	}
+	if a.content != nil && b.content != nil {
+		return !bytes.Equal(a.content, b.content)
+	}
+	return a.size != b.size || !a.modTime.Equal(b.modTime)
+}
+
+// Changes compares the snapshot with the current state of dir. The diff
+// stops growing once it reaches maxDiffBytes.
+func (s *Snapshot) Changes(dir string, maxDiffBytes int64) (*backend.WorkspaceChanges, error) {
+	after, err := TakeSnapshot(dir, maxDiffBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for p := range s.files {
+		paths[p] = true
+	}
+	for p := range after.files {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	changes := &backend.WorkspaceChanges{Files: []backend.FileChange{}}
+	var diff strings.Builder
+	for _, p := range sorted {
+		old, inOld := s.files[p]
+		cur, inCur := after.files[p]
+
+		fc := backend.FileChange{Path: p}
+		switch {
+		case !inOld:
+			fc.Status = "added"
+		case !inCur:
+			fc.Status = "deleted"
+		case old.changed(cur):
+			fc.Status = "modified"
+		default:
+			continue
+		}
+		if len(changes.Files) == maxChangedFiles {
+			changes.Truncated = true
+			break
+		}
+
+		fromName, toName := "a/"+p, "b/"+p
+		if !inOld {
+			old, fromName = snapFile{content: []byte{}}, "/dev/null"
+		}
+		if !inCur {
+			cur, toName = snapFile{content: []byte{}}, "/dev/null"
+		}
+
+		var fileDiff string
+		switch {
+		case old.binary || cur.binary:
+			fc.Binary = true
+			fileDiff = "Binary files " + fromName + " and " + toName + " differ\n"
+		case old.content == nil || cur.content == nil:
+			changes.Truncated = true
+			fc.DiffOmitted = true
+		default:
+			fileDiff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(old.content)),
+				B:        difflib.SplitLines(string(cur.content)),
+				FromFile: fromName,
+				ToFile:   toName,
+				Context:  3,
+			})
+		}
+		if fileDiff != "" {
+			if int64(diff.Len()+len(fileDiff)) > maxDiffBytes {
+				changes.Truncated = true
+				fc.DiffOmitted = true
+			} else {
+				diff.WriteString(fileDiff)
+			}
+		}
+		changes.Files = append(changes.Files, fc)
+	}
+	changes.Diff = diff.String()
+	return changes, nil
+}
+
+// ChangeTracker is a backend.Hook that snapshots the project before each
+// run and reports what the run changed as an EventWorkspaceChanges event
+// when WORKSPACE_CHANGES is on.
+type ChangeTracker struct {
+	cfg *config.Reloader
+}
+
+// NewChangeTracker creates a change tracker.
+func NewChangeTracker(cfg *config.Reloader) *ChangeTracker {
+	return &ChangeTracker{cfg: cfg}
+}
+
+// Start implements backend.Hook.
+func (t *ChangeTracker) Start(req backend.Request) backend.Finish {
+	cfg := t.cfg.Get()
+	if !cfg.WorkspaceChanges || req.ReadOnly || req.ProjectPath == "" {
+		return nil
+	}
+	maxDiff := cfg.WorkspaceDiffMaxBytes
+
+	before, err := TakeSnapshot(req.ProjectPath, maxDiff)
+	if err != nil {
+		log.Error().Err(err).Str("project_id", req.ProjectID).Msg("Failed to snapshot project")
+		return nil
+	}
+	return func(backend.Result) []backend.Event {
+		changes, err := before.Changes(req.ProjectPath, maxDiff)
+		if err != nil {
+			log.Error().Err(err).Str("project_id", req.ProjectID).Msg("Failed to compute workspace changes")
+			return nil
+		}
+		return []backend.Event{{Type: backend.EventWorkspaceChanges, Changes: changes}}
+	}
+}
@@ -0,0 +1,137 @@
+package projects
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+)
+
+func TestChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "keep.txt"), "same\n")
+	writeFile(t, filepath.Join(dir, "edit.txt"), "one\ntwo\n")
+	writeFile(t, filepath.Join(dir, "gone.txt"), "bye\n")
+	writeFile(t, filepath.Join(dir, "image.bin"), "\x89PNG\x00\x01")
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref: refs/heads/main\n")
+
+	before, err := TakeSnapshot(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "edit.txt"), "one\n2\n")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+	writeFile(t, filepath.Join(dir, "new.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, "image.bin"), "\x89PNG\x00\x02")
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref: refs/heads/other\n")
+
+	changes, err := before.Changes(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []backend.FileChange{
+		{Path: "edit.txt", Status: "modified"},
+		{Path: "gone.txt", Status: "deleted"},
+		{Path: "image.bin", Status: "modified", Binary: true},
+		{Path: "new.txt", Status: "added"},
+	}
+	if len(changes.Files) != len(want) {
+		t.Fatalf("files = %+v", changes.Files)
+	}
+	for i, fc := range changes.Files {
+		if fc != want[i] {
+			t.Errorf("file %d = %+v, want %+v", i, fc, want[i])
+		}
+	}
+	for _, line := range []string{"--- a/edit.txt", "-two", "+2", "--- /dev/null", "+++ b/new.txt", "+hello", "+++ /dev/null", "Binary files a/image.bin and b/image.bin differ"} {
+		if !strings.Contains(changes.Diff, line+"\n") {
+			t.Errorf("diff has no %q line:\n%s", line, changes.Diff)
+		}
+	}
+	if changes.Truncated {
+		t.Error("small diff truncated")
+	}
+
+	// Diffs beyond the cap are left out
+	changes, _ = before.Changes(dir, 10)
+	if !changes.Truncated || len(changes.Files) != 4 {
+		t.Errorf("capped changes = %+v", changes)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data string
+		want bool
+	}{
+		"text":  {"hello\n", false},
+		"utf-8": {"héllo wörld\n", false},
+		"nul":   {"a\x00b", true},
+		"latin": {"caf\xe9", true},
+		// "é" is two bytes; the sniffed prefix ends between them
+		"rune across the sniff length": {strings.Repeat("a", binarySniffLen-1) + "é", false},
+		"invalid before the cut":       {strings.Repeat("a", binarySniffLen-2) + "\xff" + "é", true},
+	} {
+		if got := isBinary([]byte(tc.data)); got != tc.want {
+			t.Errorf("%s: isBinary = %v", name, got)
+		}
+	}
+}
+
+func TestSessionGuard(t *testing.T) {
+	g := NewSessionGuard()
+	req := backend.Request{Namespace: "team", ProjectID: "p"}
+	g.Start(req)(backend.Result{SessionID: "s1"})
+
+	resume := req
+	resume.ResumeSessionID = "s1"
+	if err := g.Admit(resume); err != nil {
+		t.Errorf("resume in the same project = %v", err)
+	}
+	for _, other := range []backend.Request{
+		{Namespace: "team", ProjectID: "q", ResumeSessionID: "s1"},
+		{Namespace: "other", ProjectID: "p", ResumeSessionID: "s1"},
+	} {
+		if err := g.Admit(other); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("resume in %s:%s = %v", other.Namespace, other.ProjectID, err)
+		}
+	}
+	// Sessions it has not seen are left to the backend
+	if err := g.Admit(backend.Request{ProjectID: "q", ResumeSessionID: "s2"}); err != nil {
+		t.Errorf("unknown session = %v", err)
+	}
+}
+
+func TestQuotaGuard(t *testing.T) {
+	m := newTestManager(t, config.Config{ProjectQuotaBytes: 10, DiskQuotaBytes: 25})
+	g := NewQuotaGuard(m)
+	small, _ := m.Dir("small")
+	big, _ := m.Dir("big")
+	writeFile(t, filepath.Join(small, "a.txt"), "12345")
+	writeFile(t, filepath.Join(big, "a.txt"), "1234567890")
+
+	if err := g.Admit(backend.Request{ProjectID: "small", ProjectPath: small}); err != nil {
+		t.Errorf("project under quota = %v", err)
+	}
+	req := backend.Request{ProjectID: "big", ProjectPath: big}
+	if err := g.Admit(req); !errors.Is(err, ErrProjectQuota) {
+		t.Errorf("project over quota = %v", err)
+	}
+	req.ReadOnly = true
+	if err := g.Admit(req); err != nil {
+		t.Errorf("read-only run = %v", err)
+	}
+
+	// The project root's usage is cached, so a new manager sees the
+	// lower quota
+	cfg := *m.cfg.Get()
+	cfg.DiskQuotaBytes = 15
+	g = NewQuotaGuard(NewManager(config.NewReloader(&cfg)))
+	if err := g.Admit(backend.Request{ProjectID: "small", ProjectPath: small}); !errors.Is(err, ErrDiskQuota) {
+		t.Errorf("root over quota = %v", err)
+	}
+}
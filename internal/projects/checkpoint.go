@@ -0,0 +1,246 @@
+package projects
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointTimeout bounds committing a project after a run.
+const checkpointTimeout = time.Minute
+
+// Identity checkpoint commits are made with.
+const (
+	checkpointAuthor = "Claude Code API"
+	checkpointEmail  = "claude-code-api@localhost"
+)
+
+// Trailers recorded in checkpoint commit messages.
+const (
+	trailerRequestID = "Request-ID"
+	trailerSessionID = "Session-ID"
+	trailerModel     = "Model"
+)
+
+// CommitInfo describes the run a checkpoint records.
+type CommitInfo struct {
+	RequestID string
+	SessionID string
+	Model     string
+	Prompt    string
+}
+
+// ChangedFile is a file changed by a commit.
+type ChangedFile struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"` // added, modified, deleted, renamed or changed
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// Commit is an entry of a project's history.
+type Commit struct {
+	SHA       string        `json:"sha"`
+	Object    string        `json:"object"`
+	Message   string        `json:"message"`
+	RequestID string        `json:"request_id,omitempty"`
+	SessionID string        `json:"session_id,omitempty"`
+	Model     string        `json:"model,omitempty"`
+	CreatedAt int64         `json:"created_at"`
+	Files     []ChangedFile `json:"files"`
+}
+
+// Checkpoint commits everything in dir, initialising a git repository
+// first if needed. It returns the new commit's SHA, or "" if nothing
+// changed.
+func Checkpoint(ctx context.Context, dir string, info CommitInfo) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if _, err := git(ctx, dir, "init", "--quiet"); err != nil {
+			return "", fmt.Errorf("failed to initialize repository: %w", err)
+		}
+	}
+	if _, err := git(ctx, dir, "add", "--all"); err != nil {
+		return "", fmt.Errorf("failed to stage changes: %w", err)
+	}
+	status, err := git(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		return "", fmt.Errorf("failed to read status: %w", err)
+	}
+	if strings.TrimSpace(status) == "" {
+		return "", nil
+	}
+
+	if _, err := git(ctx, dir,
+		"-c", "user.name="+checkpointAuthor, "-c", "user.email="+checkpointEmail, "-c", "commit.gpgsign=false",
+		"commit", "--quiet", "--no-verify", "-m", commitMessage(info)); err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	sha, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// commitMessage summarises the prompt in the subject and records the run in
+// trailers.
+func commitMessage(info CommitInfo) string {
+	subject := ""
+	for _, line := range strings.Split(info.Prompt, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subject = line
+			break
+		}
+	}
+	if utf8.RuneCountInString(subject) > 72 {
+		subject = string([]rune(subject)[:69]) + "..."
+	}
+	if subject == "" {
+		subject = "Checkpoint"
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n\n")
+	fmt.Fprintf(&b, "%s: %s\n", trailerRequestID, info.RequestID)
+	if info.SessionID != "" {
+		fmt.Fprintf(&b, "%s: %s\n", trailerSessionID, info.SessionID)
+	}
+	fmt.Fprintf(&b, "%s: %s\n", trailerModel, info.Model)
+	return b.String()
+}
+
+// History returns up to limit commits of the repository in dir, newest
+// first, optionally only those made for requestID. A project without
+// commits has an empty history.
+func History(ctx context.Context, dir string, limit int, requestID string) ([]Commit, error) {
+	commits := []Commit{}
+	if _, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return commits, nil
+	}
+
+	args := []string{"-c", "core.quotepath=false", "log", "-n", strconv.Itoa(limit),
+		"--format=%x1e%H%x1f%ct%x1f%B%x1f", "--name-status"}
+	if requestID != "" {
+		args = append(args, "--fixed-strings", "--grep", trailerRequestID+": "+requestID)
+	}
+	out, err := git(ctx, dir, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	for _, rec := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(rec, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		c := Commit{SHA: fields[0], Object: "commit", Files: []ChangedFile{}}
+		c.CreatedAt, _ = strconv.ParseInt(fields[1], 10, 64)
+
+		body := strings.TrimSpace(fields[2])
+		c.Message, _, _ = strings.Cut(body, "\n")
+		for _, line := range strings.Split(body, "\n") {
+			key, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case trailerRequestID:
+				c.RequestID = value
+			case trailerSessionID:
+				c.SessionID = value
+			case trailerModel:
+				c.Model = value
+			}
+		}
+		if requestID != "" && c.RequestID != requestID {
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(fields[3]), "\n") {
+			parts := strings.Split(line, "\t")
+			if len(parts) < 2 || parts[0] == "" {
+				continue
+			}
+			f := ChangedFile{Path: parts[len(parts)-1], Status: "changed"}
+			switch parts[0][0] {
+			case 'A':
+				f.Status = "added"
+			case 'M':
+				f.Status = "modified"
+			case 'D':
+				f.Status = "deleted"
+			case 'R':
+				f.Status = "renamed"
+				f.OldPath = parts[1]
+			}
+			c.Files = append(c.Files, f)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// Checkpointer is a backend.Hook that commits a project after every run
+// when GIT_CHECKPOINTS is on.
+type Checkpointer struct {
+	cfg *config.Reloader
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCheckpointer creates a checkpointer.
+func NewCheckpointer(cfg *config.Reloader) *Checkpointer {
+	return &Checkpointer{cfg: cfg, locks: make(map[string]*sync.Mutex)}
+}
+
+// lock serialises commits to the same project.
+func (c *Checkpointer) lock(dir string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[dir] = l
+	}
+	return l
+}
+
+// Start implements backend.Hook.
+func (c *Checkpointer) Start(req backend.Request) backend.Finish {
+	if !c.cfg.Get().GitCheckpoints || req.ReadOnly || req.ProjectPath == "" {
+		return nil
+	}
+	return func(res backend.Result) []backend.Event {
+		l := c.lock(req.ProjectPath)
+		l.Lock()
+		defer l.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+		defer cancel()
+		sha, err := Checkpoint(ctx, req.ProjectPath, CommitInfo{
+			RequestID: req.RequestID,
+			SessionID: res.SessionID,
+			Model:     req.Model,
+			Prompt:    req.Prompt,
+		})
+		if err != nil {
+			log.Error().Err(err).Str("project_id", req.ProjectID).Str("request_id", req.RequestID).Msg("Failed to checkpoint project")
+			return nil
+		}
+		if sha != "" {
+			log.Info().Str("project_id", req.ProjectID).Str("request_id", req.RequestID).Str("commit", sha).Msg("Project checkpointed")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package projects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Garbage collection actions.
+const (
+	GCDelete  = "delete"
+	GCArchive = "archive"
+)
+
+// Collected describes a project removed by the garbage collector.
+type Collected struct {
+	ProjectID  string `json:"project_id"`
+	Namespace  string `json:"namespace,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Action     string `json:"action"`
+	SizeBytes  int64  `json:"size_bytes"`
+	LastUsedAt int64  `json:"last_used_at"`
+	// Archive is the tar.gz the project was saved to before deletion
+	Archive string `json:"archive,omitempty"`
+}
+
+// Collector removes projects unused for longer than PROJECT_TTL_HOURS,
+// archiving them first when PROJECT_GC_ACTION is archive. It runs every
+// PROJECT_GC_INTERVAL_MINUTES until Shutdown.
+type Collector struct {
+	projects *Manager
+	// forget is called with the namespace and ID of each removed project
+	forget func(ns, id string) error
+
+	mu   sync.Mutex // serialises collection runs
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewCollector creates a collector and starts its background loop. forget
+// may be nil.
+func NewCollector(m *Manager, forget func(ns, id string) error) *Collector {
+	ctx, stop := context.WithCancel(context.Background())
+	c := &Collector{projects: m, forget: forget, stop: stop, done: make(chan struct{})}
+	go c.loop(ctx)
+	return c
+}
+
+func (c *Collector) loop(ctx context.Context) {
+	defer close(c.done)
+	for {
+		interval := time.Duration(c.projects.cfg.Get().ProjectGCIntervalMinutes) * time.Minute
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if _, err := c.Collect(); err != nil {
+			log.Error().Err(err).Msg("Project garbage collection failed")
+		}
+	}
+}
+
+// Shutdown stops the background loop.
+func (c *Collector) Shutdown() {
+	c.stop()
+	<-c.done
+}
+
+// Collect removes the projects of any namespace that have expired now. It
+// does nothing when PROJECT_TTL_HOURS is 0.
+func (c *Collector) Collect() ([]Collected, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg := c.projects.cfg.Get()
+	collected := []Collected{}
+	if cfg.ProjectTTLHours <= 0 {
+		return collected, nil
+	}
+	list, err := c.projects.all()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-time.Duration(cfg.ProjectTTLHours) * time.Hour).Unix()
+	for _, p := range list {
+		if p.LastUsedAt >= cutoff {
+			continue
+		}
+		m := c.projects.Namespace(p.Namespace)
+		// A session started before the cutoff, such as a long task, may
+		// still be running
+		if m.sessions(p.ID) > 0 {
+			continue
+		}
+		item := Collected{
+			ProjectID:  p.ID,
+			Namespace:  p.Namespace,
+			Owner:      p.Owner,
+			Action:     cfg.ProjectGCAction,
+			SizeBytes:  p.SizeBytes,
+			LastUsedAt: p.LastUsedAt,
+		}
+		if cfg.ProjectGCAction == GCArchive {
+			path, err := archive(m, cfg.ProjectArchiveDir, p)
+			if err != nil {
+				// Keep the project rather than lose it
+				log.Error().Err(err).Str("namespace", p.Namespace).Str("project_id", p.ID).Msg("Failed to archive expired project")
+				continue
+			}
+			item.Archive = path
+		}
+		err := m.Delete(p.ID)
+		if errors.Is(err, ErrProjectInUse) {
+			// A session started meanwhile; keep the project for now
+			if item.Archive != "" {
+				os.Remove(item.Archive)
+			}
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Str("namespace", p.Namespace).Str("project_id", p.ID).Msg("Failed to delete expired project")
+			continue
+		}
+		if c.forget != nil {
+			if err := c.forget(p.Namespace, p.ID); err != nil {
+				log.Error().Err(err).Str("namespace", p.Namespace).Str("project_id", p.ID).Msg("Failed to drop project file records")
+			}
+		}
+		log.Info().Str("namespace", p.Namespace).Str("project_id", p.ID).Str("action", item.Action).Int64("size_bytes", p.SizeBytes).Msg("Expired project collected")
+		collected = append(collected, item)
+	}
+	return collected, nil
+}
+
+// archive writes project p of m to a timestamped tar.gz in dir. Archives of
+// namespaced projects are prefixed with the namespace.
+func archive(m *Manager, dir string, p Project) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive dir: %w", err)
+	}
+	name := p.ID
+	if p.Namespace != "" {
+		name = p.Namespace + "_" + p.ID
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.tar.gz", name, time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	if err := m.WriteArchive(p.ID, FormatTarGz, f); err != nil {
+		f.Close()
+		os.Remove(path + ".tmp")
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path + ".tmp")
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	return path, os.Rename(path+".tmp", path)
+}
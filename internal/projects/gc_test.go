@@ -0,0 +1,51 @@
+package projects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/config"
+)
+
+func TestCollect(t *testing.T) {
+	m := newTestManager(t, config.Config{ProjectTTLHours: 1, ProjectGCAction: GCDelete, ProjectGCIntervalMinutes: 60})
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{"fresh", "stale", "busy"} {
+		dir, _ := m.Dir(id)
+		writeFile(t, filepath.Join(dir, "a.txt"), "x")
+		if id != "fresh" {
+			os.Chtimes(dir, old, old)
+		}
+	}
+	// A long task may run past the TTL
+	m.use("busy", 1)
+
+	var forgotten []string
+	c := NewCollector(m, func(ns, id string) error {
+		forgotten = append(forgotten, id)
+		return nil
+	})
+	defer c.Shutdown()
+
+	collected, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collected) != 1 || collected[0].ProjectID != "stale" || len(forgotten) != 1 || forgotten[0] != "stale" {
+		t.Errorf("collected = %+v, forgotten = %v", collected, forgotten)
+	}
+	for id, want := range map[string]bool{"fresh": true, "stale": false, "busy": true} {
+		dir, _ := m.Dir(id)
+		if _, err := os.Stat(dir); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", id, err == nil, want)
+		}
+	}
+
+	// Once the session has ended it is collected
+	m.use("busy", -1)
+	if collected, _ := c.Collect(); len(collected) != 1 || collected[0].ProjectID != "busy" {
+		t.Errorf("second collection = %+v", collected)
+	}
+}
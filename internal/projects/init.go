@@ -0,0 +1,224 @@
+package projects
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/config"
+)
+
+// Errors returned when initialising a project.
+var (
+	ErrUnknownTemplate = errors.New("unknown project template")
+	ErrRepoNotAllowed  = errors.New("repository is not in the allowlist")
+	ErrInvalidRef      = errors.New("invalid git ref")
+	ErrInvalidSource   = errors.New("specify either a template or a repository")
+)
+
+// cloneTimeout bounds cloning a repository into a new project.
+const cloneTimeout = 5 * time.Minute
+
+// Source says what a new project is initialised from: a template directory
+// configured in project_templates, or a local git repository listed in
+// project_repos, optionally checked out at Ref.
+type Source struct {
+	Template   string `json:"template,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+}
+
+func (s Source) empty() bool {
+	return s == Source{}
+}
+
+// Ensure returns the directory of a project of namespace ns, creating it on
+// first use with owner recorded. New projects are initialised from
+// DEFAULT_PROJECT_TEMPLATE when one is set.
+func Ensure(cfg *config.Config, ns, id, owner string) (string, error) {
+	root := NamespaceRoot(cfg.ProjectRoot, ns)
+	dir, err := Resolve(root, id)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		return dir, nil
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return dir, fmt.Errorf("failed to create project root: %w", err)
+	}
+
+	md := meta{Owner: owner, CreatedAt: time.Now().Unix()}
+	err = create(context.Background(), cfg, root, id, Source{Template: cfg.DefaultProjectTemplate}, md)
+	if errors.Is(err, ErrExists) {
+		// Another request created it first
+		err = nil
+	}
+	return dir, err
+}
+
+// create creates project id under root from src and records md. Populated
+// projects are staged next to the metadata and moved into place once
+// complete, so a failed initialisation leaves nothing behind.
+func create(ctx context.Context, cfg *config.Config, root, id string, src Source, md meta) error {
+	dir := filepath.Join(root, id)
+
+	if src.empty() {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			if os.IsExist(err) {
+				return ErrExists
+			}
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Join(root, metaDir), 0755); err != nil {
+			return fmt.Errorf("failed to create project metadata dir: %w", err)
+		}
+		staging, err := os.MkdirTemp(filepath.Join(root, metaDir), "init-*")
+		if err != nil {
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+		defer os.RemoveAll(staging)
+
+		if err := populate(ctx, cfg, staging, src); err != nil {
+			return err
+		}
+		os.Chmod(staging, 0755)
+		if err := os.Rename(staging, dir); err != nil {
+			if _, serr := os.Lstat(dir); serr == nil {
+				return ErrExists
+			}
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+		md.Source = &src
+	}
+
+	if err := saveMeta(root, id, md); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// populate fills dir from src.
+func populate(ctx context.Context, cfg *config.Config, dir string, src Source) error {
+	switch {
+	case src.Template != "" && src.Repository == "" && src.Ref == "":
+		tmpl, ok := cfg.ProjectTemplates[src.Template]
+		if !ok {
+			return ErrUnknownTemplate
+		}
+		if err := copyTree(tmpl, dir); err != nil {
+			return fmt.Errorf("failed to copy template %s: %w", src.Template, err)
+		}
+		return nil
+
+	case src.Repository != "" && src.Template == "":
+		if !repoAllowed(cfg, src.Repository) {
+			return ErrRepoNotAllowed
+		}
+		// A ref starting with a dash would be read as an option
+		if strings.HasPrefix(src.Ref, "-") || strings.ContainsAny(src.Ref, " \t\n\x00") {
+			return ErrInvalidRef
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, cloneTimeout)
+		defer cancel()
+		if _, err := git(ctx, "", "clone", "--quiet", "--no-hardlinks", "--", filepath.Clean(src.Repository), dir); err != nil {
+			return fmt.Errorf("failed to clone %s: %w", src.Repository, err)
+		}
+		if src.Ref != "" {
+			if _, err := git(ctx, dir, "checkout", "--quiet", src.Ref, "--"); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidRef, err)
+			}
+		}
+		return nil
+	}
+	return ErrInvalidSource
+}
+
+// repoAllowed reports whether repo is listed in project_repos.
+func repoAllowed(cfg *config.Config, repo string) bool {
+	if !filepath.IsAbs(repo) {
+		return false
+	}
+	repo = filepath.Clean(repo)
+	for _, allowed := range cfg.ProjectRepos {
+		if filepath.Clean(allowed) == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// git runs a git command and returns its output, or its stderr as the
+// error.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// copyTree copies the files, directories and symlinks under src into dst.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyRegular(p, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyRegular(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,382 @@
+// Package projects manages the project directories Claude works in.
+package projects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/config"
+)
+
+// Errors returned by Manager.
+var (
+	ErrNotFound  = errors.New("project not found")
+	ErrExists    = errors.New("project already exists")
+	ErrInvalidID = errors.New("invalid project id")
+	ErrBadPath   = errors.New("invalid path")
+	// ErrOutsideRoot is returned for project directories that resolve
+	// outside the project root through a symlink
+	ErrOutsideRoot = errors.New("project directory is outside the project root")
+)
+
+// metaDir holds project metadata inside the project root. Project IDs may
+// not start with a dot, so it cannot clash with a project.
+const metaDir = ".projects"
+
+// tenantsDir holds the project roots of namespaces inside the project root.
+const tenantsDir = ".tenants"
+
+// maxIDLength bounds project IDs.
+const maxIDLength = 128
+
+// idPattern is the project ID grammar: letters, digits, '.', '_' and '-',
+// starting with a letter or digit. IDs are used as directory names, so
+// anything that could name another path is excluded.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Project describes a project directory.
+type Project struct {
+	ID         string            `json:"id"`
+	Object     string            `json:"object"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	CreatedAt  int64             `json:"created_at"`
+	LastUsedAt int64             `json:"last_used_at"`
+	SizeBytes  int64             `json:"size_bytes"`
+	FileCount  int               `json:"file_count"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Source     *Source           `json:"source,omitempty"`
+	// Access is the grant through which a project of another namespace
+	// is shared with the caller
+	Access string `json:"access,omitempty"`
+}
+
+// meta is what is persisted about a project besides its directory.
+type meta struct {
+	Owner     string            `json:"owner,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Source    *Source           `json:"source,omitempty"`
+}
+
+// Manager manages the projects of one namespace. Directories created
+// implicitly by chat requests are projects too; they have no owner.
+type Manager struct {
+	cfg *config.Reloader
+	// ns is the namespace whose projects the manager sees
+	ns string
+	*state
+}
+
+// state is shared by the managers of all namespaces.
+type state struct {
+	mu sync.Mutex
+
+	// snapLocks serialise snapshot operations per project
+	snapLocks map[string]*sync.Mutex
+	// inUse counts the sessions running in each project
+	inUse map[string]int
+
+	// usage caches the last disk usage measurement
+	usageMu sync.Mutex
+	usage   *DiskUsage
+}
+
+// NewManager creates a manager for the projects directly under the project
+// root, which belong to the empty namespace used when authentication is off.
+func NewManager(cfg *config.Reloader) *Manager {
+	return &Manager{cfg: cfg, state: &state{snapLocks: make(map[string]*sync.Mutex), inUse: make(map[string]int)}}
+}
+
+// Namespace returns a manager for the projects of namespace ns.
+func (m *Manager) Namespace(ns string) *Manager {
+	return &Manager{cfg: m.cfg, ns: ns, state: m.state}
+}
+
+// NamespaceRoot returns the directory holding the projects of namespace ns:
+// <root>/.tenants/<ns>, or root itself for the empty namespace.
+func NamespaceRoot(root, ns string) string {
+	if ns == "" {
+		return root
+	}
+	return filepath.Join(root, tenantsDir, ns)
+}
+
+// Namespaces lists the namespaces that have a project root, starting with
+// the empty namespace.
+func (m *Manager) Namespaces() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.cfg.Get().ProjectRoot, tenantsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read namespaces: %w", err)
+	}
+	list := []string{""}
+	for _, e := range entries {
+		if e.IsDir() && ValidID(e.Name()) {
+			list = append(list, e.Name())
+		}
+	}
+	return list, nil
+}
+
+func (m *Manager) root() string {
+	return NamespaceRoot(m.cfg.Get().ProjectRoot, m.ns)
+}
+
+// ValidID reports whether id matches the project ID grammar.
+func ValidID(id string) bool {
+	return len(id) <= maxIDLength && idPattern.MatchString(id)
+}
+
+// Resolve returns the directory of project id under root. IDs outside the
+// grammar fail with ErrInvalidID, and an existing directory that resolves
+// outside root, such as a symlink to elsewhere, with ErrOutsideRoot.
+func Resolve(root, id string) (string, error) {
+	if !ValidID(id) {
+		return "", ErrInvalidID
+	}
+	dir := filepath.Join(root, id)
+
+	real, err := filepath.EvalSymlinks(dir)
+	if os.IsNotExist(err) {
+		if _, lerr := os.Lstat(dir); lerr == nil {
+			// A dangling symlink; creating the project would follow it
+			return "", ErrOutsideRoot
+		}
+		return dir, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve project directory: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve project root: %w", err)
+	}
+	rel, err := filepath.Rel(realRoot, real)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideRoot
+	}
+	return dir, nil
+}
+
+// Dir returns the directory of a project.
+func (m *Manager) Dir(id string) (string, error) {
+	return Resolve(m.root(), id)
+}
+
+// Workspace returns the directory of an existing project.
+func (m *Manager) Workspace(id string) (string, error) {
+	return m.resolve(id, "")
+}
+
+func (m *Manager) metaPath(id string) string {
+	return metaPath(m.root(), id)
+}
+
+func metaPath(root, id string) string {
+	return filepath.Join(root, metaDir, id+".json")
+}
+
+func (m *Manager) loadMeta(id string) meta {
+	var md meta
+	if data, err := os.ReadFile(m.metaPath(id)); err == nil {
+		json.Unmarshal(data, &md)
+	}
+	return md
+}
+
+func saveMeta(root, id string, md meta) error {
+	if err := os.MkdirAll(filepath.Join(root, metaDir), 0755); err != nil {
+		return fmt.Errorf("failed to create project metadata dir: %w", err)
+	}
+	data, _ := json.MarshalIndent(md, "", "  ")
+	tmp := metaPath(root, id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write project metadata: %w", err)
+	}
+	return os.Rename(tmp, metaPath(root, id))
+}
+
+// Create creates a project, populated from src if it names a template or
+// repository.
+func (m *Manager) Create(ctx context.Context, id, owner string, metadata map[string]string, src Source) (Project, error) {
+	dir, err := m.Dir(id)
+	if err != nil {
+		return Project{}, err
+	}
+	cfg := m.cfg.Get()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := os.Lstat(dir); err == nil {
+		return Project{}, ErrExists
+	}
+	if err := os.MkdirAll(m.root(), 0755); err != nil {
+		return Project{}, fmt.Errorf("failed to create project root: %w", err)
+	}
+	md := meta{Owner: owner, CreatedAt: time.Now().Unix(), Metadata: metadata}
+	if err := create(ctx, cfg, m.root(), id, src, md); err != nil {
+		return Project{}, err
+	}
+	return m.describe(id, dir)
+}
+
+// Get returns a project with its current size.
+func (m *Manager) Get(id string) (Project, error) {
+	dir, err := m.Dir(id)
+	if err != nil {
+		return Project{}, err
+	}
+	return m.describe(id, dir)
+}
+
+// List returns all projects, most recently used first.
+func (m *Manager) List() ([]Project, error) {
+	entries, err := os.ReadDir(m.root())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Project{}, nil
+		}
+		return nil, fmt.Errorf("failed to read project root: %w", err)
+	}
+
+	list := []Project{}
+	for _, e := range entries {
+		if !e.IsDir() || !ValidID(e.Name()) {
+			continue
+		}
+		if p, err := m.describe(e.Name(), filepath.Join(m.root(), e.Name())); err == nil {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].LastUsedAt != list[j].LastUsedAt {
+			return list[i].LastUsedAt > list[j].LastUsedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// describe builds the Project for a directory. Sessions touch the project
+// directory when they start, so its mtime is when it was last used.
+func (m *Manager) describe(id, dir string) (Project, error) {
+	info, err := os.Lstat(dir)
+	if err != nil || !info.IsDir() {
+		return Project{}, ErrNotFound
+	}
+
+	md := m.loadMeta(id)
+	p := Project{
+		ID:         id,
+		Object:     "project",
+		Namespace:  m.ns,
+		Owner:      md.Owner,
+		CreatedAt:  md.CreatedAt,
+		LastUsedAt: info.ModTime().Unix(),
+		Metadata:   md.Metadata,
+		Source:     md.Source,
+	}
+	if p.CreatedAt == 0 {
+		p.CreatedAt = p.LastUsedAt
+	}
+	p.SizeBytes, p.FileCount = dirSize(dir)
+	return p, nil
+}
+
+// dirSize adds up the regular files under dir.
+func dirSize(dir string) (size int64, files int) {
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				size += fi.Size()
+				files++
+			}
+		}
+		return nil
+	})
+	return size, files
+}
+
+// Rename moves a project to a new ID. Projects a session is running in are
+// refused with ErrProjectInUse.
+func (m *Manager) Rename(id, newID string) (Project, error) {
+	dir, err := m.Dir(id)
+	if err != nil {
+		return Project{}, err
+	}
+	newDir, err := m.Dir(newID)
+	if err != nil {
+		return Project{}, err
+	}
+
+	l := m.snapshotLock(id)
+	l.Lock()
+	defer l.Unlock()
+	if m.sessions(id) > 0 {
+		return Project{}, ErrProjectInUse
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return Project{}, ErrNotFound
+	}
+	if _, err := os.Lstat(newDir); err == nil {
+		return Project{}, ErrExists
+	}
+	if err := os.Rename(dir, newDir); err != nil {
+		return Project{}, fmt.Errorf("failed to rename project: %w", err)
+	}
+	if err := os.Rename(m.metaPath(id), m.metaPath(newID)); err != nil && !os.IsNotExist(err) {
+		return Project{}, fmt.Errorf("failed to rename project metadata: %w", err)
+	}
+	// Drop snapshots left behind by a project removed outside the API
+	os.RemoveAll(m.snapshotRoot(newID))
+	if err := os.Rename(m.snapshotRoot(id), m.snapshotRoot(newID)); err != nil && !os.IsNotExist(err) {
+		return Project{}, fmt.Errorf("failed to rename project snapshots: %w", err)
+	}
+	return m.describe(newID, newDir)
+}
+
+// Delete removes a project, everything in it and its snapshots. Projects a
+// session is running in are refused with ErrProjectInUse.
+func (m *Manager) Delete(id string) error {
+	dir, err := m.Dir(id)
+	if err != nil {
+		return err
+	}
+
+	l := m.snapshotLock(id)
+	l.Lock()
+	defer l.Unlock()
+	if m.sessions(id) > 0 {
+		return ErrProjectInUse
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+	if err := os.Remove(m.metaPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete project metadata: %w", err)
+	}
+	if err := os.RemoveAll(m.snapshotRoot(id)); err != nil {
+		return fmt.Errorf("failed to delete project snapshots: %w", err)
+	}
+	return nil
+}
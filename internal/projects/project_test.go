@@ -0,0 +1,178 @@
+package projects
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-code-api/internal/config"
+)
+
+// newTestManager creates a manager over a scratch project root.
+func newTestManager(t *testing.T, cfg config.Config) *Manager {
+	t.Helper()
+	cfg.ProjectRoot = t.TempDir()
+	if cfg.SnapshotRetention == 0 {
+		cfg.SnapshotRetention = 10
+	}
+	return NewManager(config.NewReloader(&cfg))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidIDAndResolve(t *testing.T) {
+	for id, want := range map[string]bool{
+		"default": true, "my-project_1.2": true,
+		"": false, ".hidden": false, "a/b": false, "..": false, "a b": false,
+	} {
+		if ValidID(id) != want {
+			t.Errorf("ValidID(%q) = %v", id, !want)
+		}
+	}
+
+	root := t.TempDir()
+	if dir, err := Resolve(root, "p"); err != nil || dir != filepath.Join(root, "p") {
+		t.Errorf("Resolve = %q, %v", dir, err)
+	}
+	if _, err := Resolve(root, "../p"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Resolve of a bad ID = %v", err)
+	}
+	os.Symlink(t.TempDir(), filepath.Join(root, "escape"))
+	os.Symlink(filepath.Join(t.TempDir(), "missing"), filepath.Join(root, "dangling"))
+	for _, id := range []string{"escape", "dangling"} {
+		if _, err := Resolve(root, id); !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("Resolve(%s) = %v, want ErrOutsideRoot", id, err)
+		}
+	}
+}
+
+func TestParseRefAndAccess(t *testing.T) {
+	for ref, want := range map[string]Ref{
+		"p":      {Namespace: "team", ID: "p"},
+		"shop:p": {Namespace: "shop", ID: "p"},
+	} {
+		if got, err := ParseRef("team", ref); err != nil || got != want {
+			t.Errorf("ParseRef(%q) = %+v, %v", ref, got, err)
+		}
+	}
+	for _, ref := range []string{"", ":p", "shop:", "a:b:c", "../x:p"} {
+		if _, err := ParseRef("team", ref); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("ParseRef(%q) = %v, want ErrInvalidID", ref, err)
+		}
+	}
+	if s := (Ref{Namespace: "shop", ID: "p"}).String(); s != "shop:p" {
+		t.Errorf("String = %q", s)
+	}
+	if s := (Ref{ID: "p"}).String(); s != "p" {
+		t.Errorf("String = %q", s)
+	}
+
+	cfg := &config.Config{SharedProjects: []config.SharedProject{
+		{Namespace: "shop", Project: "docs", Grants: map[string]string{"team": "read", "ops": "write"}},
+	}}
+	for _, tc := range []struct {
+		ns   string
+		ref  Ref
+		want Access
+	}{
+		{"team", Ref{"team", "p"}, AccessOwner},
+		{"", Ref{"shop", "p"}, AccessOwner},
+		{"team", Ref{"shop", "docs"}, AccessRead},
+		{"ops", Ref{"shop", "docs"}, AccessWrite},
+		{"other", Ref{"shop", "docs"}, AccessNone},
+		{"team", Ref{"shop", "private"}, AccessNone},
+	} {
+		if got := AccessTo(cfg, tc.ns, tc.ref); got != tc.want {
+			t.Errorf("AccessTo(%q, %v) = %v, want %v", tc.ns, tc.ref, got, tc.want)
+		}
+	}
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	m := newTestManager(t, config.Config{})
+	team := m.Namespace("team")
+
+	p, err := team.Create(context.Background(), "p", "key_a", map[string]string{"env": "dev"}, Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Namespace != "team" || p.Owner != "key_a" || p.Metadata["env"] != "dev" || p.CreatedAt == 0 {
+		t.Errorf("project = %+v", p)
+	}
+	if _, err := team.Create(context.Background(), "p", "", nil, Source{}); !errors.Is(err, ErrExists) {
+		t.Errorf("second Create = %v", err)
+	}
+
+	dir, _ := team.Workspace("p")
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello")
+	if got, _ := team.Get("p"); got.SizeBytes != 5 || got.FileCount != 1 {
+		t.Errorf("size = %d bytes in %d files", got.SizeBytes, got.FileCount)
+	}
+
+	// Namespaces do not see each other's projects
+	if list, _ := m.List(); len(list) != 0 {
+		t.Errorf("empty namespace lists %+v", list)
+	}
+	if _, err := m.Namespace("other").Get("p"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other namespace Get = %v", err)
+	}
+	if ns, _ := m.Namespaces(); len(ns) != 2 || ns[0] != "" || ns[1] != "team" {
+		t.Errorf("Namespaces = %q", ns)
+	}
+
+	// Projects a session is running in stay put
+	team.use("p", 1)
+	if _, err := team.Rename("p", "q"); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("Rename in use = %v", err)
+	}
+	if err := team.Delete("p"); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("Delete in use = %v", err)
+	}
+	team.use("p", -1)
+
+	renamed, err := team.Rename("p", "q")
+	if err != nil || renamed.ID != "q" || renamed.Owner != "key_a" {
+		t.Fatalf("Rename = %+v, %v", renamed, err)
+	}
+	if _, err := team.Get("p"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of the old ID = %v", err)
+	}
+
+	if err := team.Delete("q"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := team.List(); len(list) != 0 {
+		t.Errorf("List after Delete = %+v", list)
+	}
+	if err := team.Delete("q"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete = %v", err)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	cfg := &config.Config{ProjectRoot: t.TempDir()}
+	dir, err := Ensure(cfg, "team", "p", "key_a")
+	if err != nil || dir != filepath.Join(cfg.ProjectRoot, tenantsDir, "team", "p") {
+		t.Fatalf("Ensure = %q, %v", dir, err)
+	}
+	writeFile(t, filepath.Join(dir, "keep.txt"), "x")
+	if again, err := Ensure(cfg, "team", "p", "key_b"); err != nil || again != dir {
+		t.Errorf("second Ensure = %q, %v", again, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "keep.txt")); err != nil {
+		t.Errorf("existing project touched: %v", err)
+	}
+	if p, _ := NewManager(config.NewReloader(cfg)).Namespace("team").Get("p"); p.Owner != "key_a" {
+		t.Errorf("owner = %q, want the first user", p.Owner)
+	}
+}
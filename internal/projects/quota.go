@@ -0,0 +1,186 @@
+package projects
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"claude-code-api/internal/backend"
+)
+
+// Errors returned when a run would exceed a disk quota.
+var (
+	ErrProjectQuota = errors.New("project disk quota exceeded")
+	ErrDiskQuota    = errors.New("disk quota exceeded")
+)
+
+// usageMaxAge bounds how stale the usage checked against DISK_QUOTA_BYTES
+// may be. Measuring walks every project, so it is not redone per session.
+const usageMaxAge = time.Minute
+
+// ProjectUsage is the disk usage of one project.
+type ProjectUsage struct {
+	ProjectID  string `json:"project_id"`
+	Namespace  string `json:"namespace,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	SizeBytes  int64  `json:"size_bytes"`
+	FileCount  int    `json:"file_count"`
+	LastUsedAt int64  `json:"last_used_at"`
+}
+
+// OwnerUsage is the disk usage of the projects owned by one API key.
+type OwnerUsage struct {
+	Owner        string `json:"owner"`
+	SizeBytes    int64  `json:"size_bytes"`
+	ProjectCount int    `json:"project_count"`
+}
+
+// DiskUsage reports the disk usage of the project root. Snapshots are not
+// included; SNAPSHOT_RETENTION bounds them.
+type DiskUsage struct {
+	Object            string         `json:"object"`
+	TotalBytes        int64          `json:"total_bytes"`
+	QuotaBytes        int64          `json:"quota_bytes"`
+	ProjectQuotaBytes int64          `json:"project_quota_bytes"`
+	Projects          []ProjectUsage `json:"projects"`
+	Owners            []OwnerUsage   `json:"owners"`
+	MeasuredAt        int64          `json:"measured_at"`
+}
+
+// DiskUsage measures the disk usage of the projects of all namespaces,
+// largest first.
+func (m *Manager) DiskUsage() (DiskUsage, error) {
+	list, err := m.all()
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	cfg := m.cfg.Get()
+
+	u := DiskUsage{
+		Object:            "disk_usage",
+		QuotaBytes:        cfg.DiskQuotaBytes,
+		ProjectQuotaBytes: cfg.ProjectQuotaBytes,
+		Projects:          make([]ProjectUsage, 0, len(list)),
+		Owners:            []OwnerUsage{},
+		MeasuredAt:        time.Now().Unix(),
+	}
+	owners := make(map[string]*OwnerUsage)
+	for _, p := range list {
+		u.TotalBytes += p.SizeBytes
+		u.Projects = append(u.Projects, ProjectUsage{
+			ProjectID:  p.ID,
+			Namespace:  p.Namespace,
+			Owner:      p.Owner,
+			SizeBytes:  p.SizeBytes,
+			FileCount:  p.FileCount,
+			LastUsedAt: p.LastUsedAt,
+		})
+		if p.Owner == "" {
+			continue
+		}
+		o, ok := owners[p.Owner]
+		if !ok {
+			o = &OwnerUsage{Owner: p.Owner}
+			owners[p.Owner] = o
+		}
+		o.SizeBytes += p.SizeBytes
+		o.ProjectCount++
+	}
+	for _, o := range owners {
+		u.Owners = append(u.Owners, *o)
+	}
+	sort.Slice(u.Projects, func(i, j int) bool {
+		if u.Projects[i].SizeBytes != u.Projects[j].SizeBytes {
+			return u.Projects[i].SizeBytes > u.Projects[j].SizeBytes
+		}
+		if u.Projects[i].Namespace != u.Projects[j].Namespace {
+			return u.Projects[i].Namespace < u.Projects[j].Namespace
+		}
+		return u.Projects[i].ProjectID < u.Projects[j].ProjectID
+	})
+	sort.Slice(u.Owners, func(i, j int) bool {
+		if u.Owners[i].SizeBytes != u.Owners[j].SizeBytes {
+			return u.Owners[i].SizeBytes > u.Owners[j].SizeBytes
+		}
+		return u.Owners[i].Owner < u.Owners[j].Owner
+	})
+
+	m.usageMu.Lock()
+	m.usage = &u
+	m.usageMu.Unlock()
+	return u, nil
+}
+
+// all lists the projects of every namespace.
+func (m *Manager) all() ([]Project, error) {
+	namespaces, err := m.Namespaces()
+	if err != nil {
+		return nil, err
+	}
+	var list []Project
+	for _, ns := range namespaces {
+		projects, err := m.Namespace(ns).List()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, projects...)
+	}
+	return list, nil
+}
+
+// totalBytes returns the disk used by all projects, measured at most
+// usageMaxAge ago.
+func (m *Manager) totalBytes() (int64, error) {
+	m.usageMu.Lock()
+	u := m.usage
+	m.usageMu.Unlock()
+	if u != nil && time.Since(time.Unix(u.MeasuredAt, 0)) < usageMaxAge {
+		return u.TotalBytes, nil
+	}
+	fresh, err := m.DiskUsage()
+	if err != nil {
+		return 0, err
+	}
+	return fresh.TotalBytes, nil
+}
+
+// QuotaGuard is a backend.Hook that refuses runs in projects over
+// PROJECT_QUOTA_BYTES, or any run once the project root exceeds
+// DISK_QUOTA_BYTES. Read-only runs are always admitted.
+type QuotaGuard struct {
+	projects *Manager
+}
+
+// NewQuotaGuard creates a quota guard for the projects of m.
+func NewQuotaGuard(m *Manager) *QuotaGuard {
+	return &QuotaGuard{projects: m}
+}
+
+// Start implements backend.Hook.
+func (g *QuotaGuard) Start(backend.Request) backend.Finish {
+	return nil
+}
+
+// Admit implements backend.Guard.
+func (g *QuotaGuard) Admit(req backend.Request) error {
+	cfg := g.projects.cfg.Get()
+	if req.ReadOnly {
+		return nil
+	}
+	if cfg.ProjectQuotaBytes > 0 && req.ProjectPath != "" {
+		if size, _ := dirSize(req.ProjectPath); size >= cfg.ProjectQuotaBytes {
+			return fmt.Errorf("%w: project '%s' uses %d of %d bytes", ErrProjectQuota, req.ProjectID, size, cfg.ProjectQuotaBytes)
+		}
+	}
+	if cfg.DiskQuotaBytes > 0 {
+		total, err := g.projects.totalBytes()
+		if err != nil {
+			return err
+		}
+		if total >= cfg.DiskQuotaBytes {
+			return fmt.Errorf("%w: projects use %d of %d bytes", ErrDiskQuota, total, cfg.DiskQuotaBytes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package projects
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"claude-code-api/internal/backend"
+)
+
+// ErrSessionNotFound is returned for resuming a session in a project other
+// than the one it ran in.
+var ErrSessionNotFound = errors.New("session not found")
+
+// maxTrackedSessions bounds the sessions a SessionGuard remembers.
+const maxTrackedSessions = 100000
+
+// SessionGuard is a backend.Guard that ties each session to the project it
+// ran in, so a session can only be resumed by callers with access to that
+// project, in that project. Sessions it has not seen, such as those from
+// before a restart, are left to the backend, which keeps sessions per
+// project directory.
+type SessionGuard struct {
+	mu       sync.Mutex
+	projects map[string]Ref
+	order    []string
+}
+
+// NewSessionGuard creates a session guard.
+func NewSessionGuard() *SessionGuard {
+	return &SessionGuard{projects: make(map[string]Ref)}
+}
+
+// Admit implements backend.Guard.
+func (g *SessionGuard) Admit(req backend.Request) error {
+	if req.ResumeSessionID == "" {
+		return nil
+	}
+	g.mu.Lock()
+	ref, ok := g.projects[req.ResumeSessionID]
+	g.mu.Unlock()
+	if ok && ref != (Ref{Namespace: req.Namespace, ID: req.ProjectID}) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, req.ResumeSessionID)
+	}
+	return nil
+}
+
+// Start implements backend.Hook. The session is recorded once the run has
+// reported its ID.
+func (g *SessionGuard) Start(req backend.Request) backend.Finish {
+	return func(res backend.Result) []backend.Event {
+		if res.SessionID == "" {
+			return nil
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if _, ok := g.projects[res.SessionID]; !ok {
+			g.order = append(g.order, res.SessionID)
+		}
+		g.projects[res.SessionID] = Ref{Namespace: req.Namespace, ID: req.ProjectID}
+		for len(g.order) > maxTrackedSessions {
+			delete(g.projects, g.order[0])
+			g.order = g.order[1:]
+		}
+		return nil
+	}
+}
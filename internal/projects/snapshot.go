@@ -0,0 +1,434 @@
+package projects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Errors returned by snapshot operations.
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	// ErrProjectInUse is returned when restoring, renaming or deleting a
+	// project a session is running in
+	ErrProjectInUse = errors.New("project is in use")
+)
+
+// snapshotsDir holds project snapshots inside the project root, one
+// directory per project. Like metaDir it cannot clash with a project.
+const snapshotsDir = ".snapshots"
+
+// Snapshot reasons.
+const (
+	ReasonSession = "session"
+	ReasonManual  = "manual"
+	ReasonRestore = "restore"
+)
+
+// snapshotPrefix starts every snapshot ID.
+const snapshotPrefix = "snap_"
+
+// ProjectSnapshot describes a saved copy of a project directory.
+type ProjectSnapshot struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	ProjectID string `json:"project_id"`
+	CreatedAt int64  `json:"created_at"`
+	// Reason is session, manual or restore (taken just before a restore)
+	Reason    string `json:"reason"`
+	RequestID string `json:"request_id,omitempty"`
+	SizeBytes int64  `json:"size_bytes"`
+	FileCount int    `json:"file_count"`
+
+	// entries counts files, directories and symlinks, to spot deletions
+	// when deciding whether anything changed since the previous snapshot
+	entries int
+}
+
+// snapshotMeta is what is persisted in snapshot.json.
+type snapshotMeta struct {
+	ProjectSnapshot
+	Entries int `json:"entries"`
+}
+
+// snapshotState tracks what copying a project into a snapshot did.
+type snapshotState struct {
+	entries int
+	size    int64
+	files   int
+	// changed counts entries that are new or differ from the previous
+	// snapshot
+	changed int
+}
+
+func (m *Manager) snapshotRoot(id string) string {
+	return filepath.Join(m.root(), snapshotsDir, id)
+}
+
+// snapshotLock serialises snapshot operations on one project.
+func (m *Manager) snapshotLock(id string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.ns + "/" + id
+	l, ok := m.snapLocks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		m.snapLocks[key] = l
+	}
+	return l
+}
+
+func validSnapshotID(id string) bool {
+	return strings.HasPrefix(id, snapshotPrefix) && ValidID(id)
+}
+
+// Snapshots lists the snapshots of a project, newest first.
+func (m *Manager) Snapshots(id string) ([]ProjectSnapshot, error) {
+	if _, err := m.Workspace(id); err != nil {
+		return nil, err
+	}
+	return m.listSnapshots(id)
+}
+
+func (m *Manager) listSnapshots(id string) ([]ProjectSnapshot, error) {
+	entries, err := os.ReadDir(m.snapshotRoot(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ProjectSnapshot{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	list := []ProjectSnapshot{}
+	for _, e := range entries {
+		if !e.IsDir() || !validSnapshotID(e.Name()) {
+			continue
+		}
+		if s, err := m.loadSnapshot(id, e.Name()); err == nil {
+			list = append(list, s)
+		}
+	}
+	// IDs grow with creation time
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
+
+func (m *Manager) loadSnapshot(id, snapID string) (ProjectSnapshot, error) {
+	var md snapshotMeta
+	data, err := os.ReadFile(filepath.Join(m.snapshotRoot(id), snapID, "snapshot.json"))
+	if err != nil {
+		return ProjectSnapshot{}, ErrSnapshotNotFound
+	}
+	if err := json.Unmarshal(data, &md); err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to parse snapshot %s: %w", snapID, err)
+	}
+	s := md.ProjectSnapshot
+	s.ProjectID = id
+	s.entries = md.Entries
+	return s, nil
+}
+
+// Snapshot saves a copy of a project. Files unchanged since the previous
+// snapshot are hard-linked to it rather than copied, and when nothing at
+// all changed the previous snapshot is returned instead of a new one.
+// Older snapshots beyond SNAPSHOT_RETENTION are removed.
+func (m *Manager) Snapshot(id, reason, requestID string) (ProjectSnapshot, error) {
+	dir, err := m.Workspace(id)
+	if err != nil {
+		return ProjectSnapshot{}, err
+	}
+	l := m.snapshotLock(id)
+	l.Lock()
+	defer l.Unlock()
+	return m.snapshot(id, dir, reason, requestID)
+}
+
+func (m *Manager) snapshot(id, dir, reason, requestID string) (ProjectSnapshot, error) {
+	root := m.snapshotRoot(id)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+	existing, err := m.listSnapshots(id)
+	if err != nil {
+		return ProjectSnapshot{}, err
+	}
+
+	now := time.Now()
+	snapID := fmt.Sprintf("%s%016x", snapshotPrefix, now.UnixNano())
+	var prevTree string
+	if len(existing) > 0 {
+		prev := existing[0]
+		prevTree = filepath.Join(root, prev.ID, "tree")
+		if snapID <= prev.ID {
+			snapID = nextSnapshotID(prev.ID)
+		}
+	}
+
+	staging, err := os.MkdirTemp(root, ".tmp-")
+	if err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	st, err := copySnapshotTree(dir, filepath.Join(staging, "tree"), prevTree)
+	if err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to copy project: %w", err)
+	}
+	if len(existing) > 0 && st.changed == 0 && st.entries == existing[0].entries {
+		return existing[0], nil
+	}
+
+	s := ProjectSnapshot{
+		ID:        snapID,
+		Object:    "project.snapshot",
+		ProjectID: id,
+		CreatedAt: now.Unix(),
+		Reason:    reason,
+		RequestID: requestID,
+		SizeBytes: st.size,
+		FileCount: st.files,
+		entries:   st.entries,
+	}
+	data, _ := json.MarshalIndent(snapshotMeta{ProjectSnapshot: s, Entries: s.entries}, "", "  ")
+	if err := os.WriteFile(filepath.Join(staging, "snapshot.json"), data, 0644); err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+	if err := os.Rename(staging, filepath.Join(root, snapID)); err != nil {
+		return ProjectSnapshot{}, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	all := append([]ProjectSnapshot{s}, existing...)
+	for _, old := range all[min(m.cfg.Get().SnapshotRetention, len(all)):] {
+		if err := os.RemoveAll(filepath.Join(root, old.ID)); err != nil {
+			log.Error().Err(err).Str("project_id", id).Str("snapshot_id", old.ID).Msg("Failed to prune snapshot")
+		}
+	}
+	return s, nil
+}
+
+// nextSnapshotID returns the ID right after id, for snapshots taken within
+// one clock tick.
+func nextSnapshotID(id string) string {
+	var n uint64
+	fmt.Sscanf(strings.TrimPrefix(id, snapshotPrefix), "%x", &n)
+	return fmt.Sprintf("%s%016x", snapshotPrefix, n+1)
+}
+
+// copySnapshotTree copies src to dst, hard-linking regular files that are
+// unchanged in prev (matching size, mode and modification time). Snapshot
+// files are never written after creation, so sharing them is safe.
+// Modification times are kept so the next snapshot can compare against
+// this one.
+func copySnapshotTree(src, dst, prev string) (snapshotState, error) {
+	var st snapshotState
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			st.entries++
+		}
+
+		switch {
+		case d.IsDir():
+			if prev != "" && rel != "." {
+				if old, err := os.Lstat(filepath.Join(prev, rel)); err != nil || !old.IsDir() {
+					st.changed++
+				}
+			}
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if prev != "" {
+				if old, err := os.Readlink(filepath.Join(prev, rel)); err != nil || old != link {
+					st.changed++
+				}
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			st.files++
+			st.size += info.Size()
+			if prev != "" {
+				old, err := os.Lstat(filepath.Join(prev, rel))
+				if err == nil && old.Mode() == info.Mode() && old.Size() == info.Size() && old.ModTime().Equal(info.ModTime()) {
+					if os.Link(filepath.Join(prev, rel), target) == nil {
+						return nil
+					}
+				}
+			}
+			st.changed++
+			if err := copyRegular(p, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+		return nil
+	})
+	return st, err
+}
+
+// RestoreSnapshot replaces the contents of a project with a snapshot. The
+// current state is snapshotted first, so a restore can itself be undone.
+// Projects a session is running in are not restored; sessions starting
+// meanwhile wait for the restore to finish.
+func (m *Manager) RestoreSnapshot(id, snapID string) (Project, ProjectSnapshot, error) {
+	dir, err := m.Workspace(id)
+	if err != nil {
+		return Project{}, ProjectSnapshot{}, err
+	}
+	if !validSnapshotID(snapID) {
+		return Project{}, ProjectSnapshot{}, ErrSnapshotNotFound
+	}
+	l := m.snapshotLock(id)
+	l.Lock()
+	defer l.Unlock()
+
+	s, err := m.loadSnapshot(id, snapID)
+	if err != nil {
+		return Project{}, ProjectSnapshot{}, err
+	}
+	if m.sessions(id) > 0 {
+		return Project{}, ProjectSnapshot{}, ErrProjectInUse
+	}
+
+	// Copy the snapshot before the pre-restore snapshot, which may prune it
+	root := m.snapshotRoot(id)
+	staging, err := os.MkdirTemp(root, ".restore-")
+	if err != nil {
+		return Project{}, ProjectSnapshot{}, fmt.Errorf("failed to stage restore: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	restored := filepath.Join(staging, "tree")
+	if err := copyTree(filepath.Join(root, snapID, "tree"), restored); err != nil {
+		return Project{}, ProjectSnapshot{}, fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+	if _, err := m.snapshot(id, dir, ReasonRestore, ""); err != nil {
+		return Project{}, ProjectSnapshot{}, err
+	}
+
+	// Swap directories so the project is never half restored
+	old := filepath.Join(staging, "old")
+	if err := os.Rename(dir, old); err != nil {
+		return Project{}, ProjectSnapshot{}, fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	if err := os.Rename(restored, dir); err != nil {
+		os.Rename(old, dir)
+		return Project{}, ProjectSnapshot{}, fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	p, err := m.describe(id, dir)
+	return p, s, err
+}
+
+// DeleteSnapshot removes one snapshot of a project.
+func (m *Manager) DeleteSnapshot(id, snapID string) error {
+	if _, err := m.Workspace(id); err != nil {
+		return err
+	}
+	if !validSnapshotID(snapID) {
+		return ErrSnapshotNotFound
+	}
+	l := m.snapshotLock(id)
+	l.Lock()
+	defer l.Unlock()
+
+	if _, err := m.loadSnapshot(id, snapID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(m.snapshotRoot(id), snapID)); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// sessions returns the number of sessions running in project id.
+func (m *Manager) sessions(id string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inUse[m.ns+"/"+id]
+}
+
+// use adds n to the number of sessions running in project id.
+func (m *Manager) use(id string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.ns + "/" + id
+	if m.inUse[key] += n; m.inUse[key] <= 0 {
+		delete(m.inUse, key)
+	}
+}
+
+// Snapshotter is a backend.Hook that snapshots the project before each
+// session when PROJECT_SNAPSHOTS is on. It also tracks the sessions running
+// in each project, which may not be restored meanwhile.
+type Snapshotter struct {
+	projects *Manager
+}
+
+// NewSnapshotter creates a snapshotter for the projects of m.
+func NewSnapshotter(m *Manager) *Snapshotter {
+	return &Snapshotter{projects: m}
+}
+
+// Admit implements backend.Guard. It marks the project in use, waiting for
+// a restore in progress to finish first.
+func (s *Snapshotter) Admit(req backend.Request) error {
+	if req.ProjectPath == "" {
+		return nil
+	}
+	m := s.projects.Namespace(req.Namespace)
+	l := m.snapshotLock(req.ProjectID)
+	l.Lock()
+	defer l.Unlock()
+	m.use(req.ProjectID, 1)
+	return nil
+}
+
+// Release implements backend.Releaser.
+func (s *Snapshotter) Release(req backend.Request) {
+	if req.ProjectPath != "" {
+		s.projects.Namespace(req.Namespace).use(req.ProjectID, -1)
+	}
+}
+
+// Start implements backend.Hook.
+func (s *Snapshotter) Start(req backend.Request) backend.Finish {
+	if req.ProjectPath == "" {
+		return nil
+	}
+	m := s.projects.Namespace(req.Namespace)
+	done := func(backend.Result) []backend.Event {
+		m.use(req.ProjectID, -1)
+		return nil
+	}
+	if !s.projects.cfg.Get().ProjectSnapshots || req.ReadOnly {
+		return done
+	}
+	snap, err := m.Snapshot(req.ProjectID, ReasonSession, req.RequestID)
+	if err != nil {
+		log.Error().Err(err).Str("project_id", req.ProjectID).Str("request_id", req.RequestID).Msg("Failed to snapshot project")
+		return done
+	}
+	log.Debug().Str("project_id", req.ProjectID).Str("snapshot_id", snap.ID).Msg("Project snapshotted")
+	return done
+}
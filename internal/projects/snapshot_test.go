@@ -0,0 +1,143 @@
+package projects
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	m := newTestManager(t, config.Config{SnapshotRetention: 3})
+	dir, _ := m.Dir("p")
+	writeFile(t, filepath.Join(dir, "a.txt"), "first\n")
+
+	first, err := m.Snapshot("p", ReasonManual, "req_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.FileCount != 1 || first.SizeBytes != 6 || first.Reason != ReasonManual || first.RequestID != "req_1" {
+		t.Errorf("snapshot = %+v", first)
+	}
+	// Nothing changed, so no new snapshot
+	if again, _ := m.Snapshot("p", ReasonManual, ""); again.ID != first.ID {
+		t.Errorf("unchanged project snapshotted again as %s", again.ID)
+	}
+
+	writeFile(t, filepath.Join(dir, "a.txt"), "second\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "new\n")
+	second, err := m.Snapshot("p", ReasonManual, "")
+	if err != nil || second.ID <= first.ID || second.FileCount != 2 {
+		t.Fatalf("second snapshot = %+v, %v", second, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "c.txt"), "unsaved\n")
+	p, restored, err := m.RestoreSnapshot("p", first.ID)
+	if err != nil || restored.ID != first.ID || p.FileCount != 1 {
+		t.Fatalf("RestoreSnapshot = %+v, %+v, %v", p, restored, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "first\n" {
+		t.Errorf("restored content = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("file added after the snapshot survived the restore: %v", err)
+	}
+
+	// The state before the restore was snapshotted, so it can be undone
+	list, _ := m.Snapshots("p")
+	if len(list) != 3 || list[0].Reason != ReasonRestore || list[0].FileCount != 3 {
+		t.Fatalf("snapshots = %+v", list)
+	}
+
+	if _, _, err := m.RestoreSnapshot("p", "snap_missing"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("restore of a missing snapshot = %v", err)
+	}
+	if err := m.DeleteSnapshot("p", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteSnapshot("p", first.ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("second DeleteSnapshot = %v", err)
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	m := newTestManager(t, config.Config{SnapshotRetention: 2})
+	dir, _ := m.Dir("p")
+
+	var ids []string
+	for _, content := range []string{"1", "2", "3"} {
+		writeFile(t, filepath.Join(dir, "a.txt"), content)
+		s, err := m.Snapshot("p", ReasonManual, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.ID)
+	}
+	list, _ := m.Snapshots("p")
+	if len(list) != 2 || list[0].ID != ids[2] || list[1].ID != ids[1] {
+		t.Errorf("snapshots = %+v, want the newest two of %v", list, ids)
+	}
+
+	// Restoring the oldest kept snapshot survives the pre-restore
+	// snapshot pruning it
+	writeFile(t, filepath.Join(dir, "a.txt"), "4")
+	if _, _, err := m.RestoreSnapshot("p", ids[1]); err != nil {
+		t.Fatalf("restore of the oldest snapshot: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "2" {
+		t.Errorf("restored content = %q", data)
+	}
+	if list, _ := m.Snapshots("p"); len(list) != 2 || list[0].Reason != ReasonRestore || list[1].ID != ids[2] {
+		t.Errorf("snapshots after restore = %+v", list)
+	}
+}
+
+func TestSnapshotterTracksSessions(t *testing.T) {
+	m := newTestManager(t, config.Config{ProjectSnapshots: true})
+	dir, _ := m.Dir("p")
+	writeFile(t, filepath.Join(dir, "a.txt"), "x")
+	s, _ := m.Snapshot("p", ReasonManual, "")
+	hook := NewSnapshotter(m)
+	req := backend.Request{ProjectID: "p", ProjectPath: dir, RequestID: "req_1"}
+
+	if err := hook.Admit(req); err != nil {
+		t.Fatal(err)
+	}
+	finish := hook.Start(req)
+	if _, _, err := m.RestoreSnapshot("p", s.ID); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("restore during a session = %v", err)
+	}
+	finish(backend.Result{})
+	if _, _, err := m.RestoreSnapshot("p", s.ID); err != nil {
+		t.Errorf("restore after the session = %v", err)
+	}
+
+	// A run that never started is released
+	if err := hook.Admit(req); err != nil {
+		t.Fatal(err)
+	}
+	hook.Release(req)
+	if n := m.sessions("p"); n != 0 {
+		t.Errorf("sessions after Release = %d", n)
+	}
+
+	// Sessions are snapshotted, read-only ones are not
+	writeFile(t, filepath.Join(dir, "a.txt"), "y")
+	hook.Admit(req)
+	hook.Start(req)(backend.Result{})
+	list, _ := m.Snapshots("p")
+	if len(list) == 0 || list[0].Reason != ReasonSession || list[0].RequestID != "req_1" {
+		t.Errorf("snapshots after a session = %+v", list)
+	}
+	writeFile(t, filepath.Join(dir, "a.txt"), "z")
+	ro := req
+	ro.ReadOnly = true
+	hook.Admit(ro)
+	hook.Start(ro)(backend.Result{})
+	if after, _ := m.Snapshots("p"); len(after) != len(list) {
+		t.Errorf("read-only session snapshotted: %+v", after)
+	}
+}
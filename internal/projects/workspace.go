@@ -0,0 +1,242 @@
+package projects
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Archive formats accepted by WriteArchive.
+const (
+	FormatTarGz = "tar.gz"
+	FormatZip   = "zip"
+)
+
+// Entry is a file or directory in a project's tree.
+type Entry struct {
+	Path       string `json:"path"`
+	Type       string `json:"type"` // file, dir or symlink
+	Size       int64  `json:"size,omitempty"`
+	ModifiedAt int64  `json:"modified_at"`
+	Target     string `json:"target,omitempty"`
+}
+
+// resolve returns the location of path inside a project, rejecting paths
+// that leave the project directly or through a symlink. An empty path is
+// the project directory itself.
+func (m *Manager) resolve(id, path string) (string, error) {
+	dir, err := m.Dir(id)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return "", ErrNotFound
+	}
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return dir, nil
+	}
+	if strings.ContainsRune(path, 0) {
+		return "", ErrBadPath
+	}
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrBadPath
+	}
+	target := filepath.Join(dir, rel)
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	if r, err := filepath.Rel(realDir, realTarget); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", ErrBadPath
+	}
+	return target, nil
+}
+
+// Tree lists the files under path in a project, depth first, stopping after
+// maxEntries. It reports whether the listing was truncated. Symlinks are
+// listed but not followed.
+func (m *Manager) Tree(id, path string, maxEntries int) ([]Entry, bool, error) {
+	base, err := m.resolve(id, path)
+	if err != nil {
+		return nil, false, err
+	}
+	dir, _ := m.Dir(id)
+	if info, err := os.Stat(base); err != nil || !info.IsDir() {
+		return nil, false, ErrNotFound
+	}
+
+	entries := []Entry{}
+	truncated := false
+	err = filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p == base {
+			return nil
+		}
+		if len(entries) >= maxEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		e := Entry{Path: filepath.ToSlash(rel), ModifiedAt: info.ModTime().Unix()}
+		switch {
+		case d.IsDir():
+			e.Type = "dir"
+		case d.Type()&fs.ModeSymlink != 0:
+			e.Type = "symlink"
+			e.Target, _ = os.Readlink(p)
+		case d.Type().IsRegular():
+			e.Type = "file"
+			e.Size = info.Size()
+		default:
+			return nil
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return entries, truncated, nil
+}
+
+// Open opens a regular file in a project. The caller closes it.
+func (m *Manager) Open(id, path string) (*os.File, fs.FileInfo, error) {
+	target, err := m.resolve(id, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, nil, ErrNotFound
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	return f, info, nil
+}
+
+// WriteArchive writes the whole project to w as a tar.gz or zip archive
+// whose entries are rooted at the project ID. Symlinks are kept as links in
+// tar archives and left out of zip archives.
+func (m *Manager) WriteArchive(id, format string, w io.Writer) error {
+	dir, err := m.resolve(id, "")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		if err := walkArchive(dir, id, func(name, path string, info fs.FileInfo) error {
+			link := ""
+			if info.Mode()&fs.ModeSymlink != 0 {
+				link, _ = os.Readlink(path)
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				return copyFile(tw, path, info.Size())
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+
+	case FormatZip:
+		zw := zip.NewWriter(w)
+		if err := walkArchive(dir, id, func(name, path string, info fs.FileInfo) error {
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			} else {
+				hdr.Method = zip.Deflate
+			}
+			fw, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				return copyFile(fw, path, info.Size())
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	return fmt.Errorf("unsupported archive format %q", format)
+}
+
+// walkArchive calls add for every entry under dir with its archive name.
+func walkArchive(dir, prefix string, add func(name, path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		name := prefix
+		if rel != "." {
+			name = prefix + "/" + filepath.ToSlash(rel)
+		}
+		return add(name, p, info)
+	})
+}
+
+// copyFile copies size bytes of a file, the size recorded in the archive
+// header, even if the file has grown since.
+func copyFile(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(w, f, size)
+	return err
+}
@@ -0,0 +1,208 @@
+// Package ratelimit limits how much of the gateway each API key may use:
+// requests per minute, concurrent sessions and tokens per day.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/usage"
+)
+
+// Errors wrapped by LimitError.
+var (
+	ErrRequestLimit = errors.New("request rate limit reached")
+	// ErrSessionLimit wraps backend.ErrBusy, so tasks and batches wait for
+	// one of the key's sessions to end
+	ErrSessionLimit = fmt.Errorf("concurrent session limit reached: %w", backend.ErrBusy)
+	// ErrTokenLimit wraps backend.ErrBusy too, so batches wait for the
+	// budget to reset rather than fail. Tasks fail on it instead of staying
+	// queued until the next day
+	ErrTokenLimit = fmt.Errorf("daily token limit reached: %w", backend.ErrBusy)
+)
+
+// sessionRetry is suggested to callers over their concurrent session limit.
+const sessionRetry = 5 * time.Second
+
+// LimitError reports a limit a run would exceed.
+type LimitError struct {
+	Err   error
+	Limit int64
+	// RetryAfter is when trying again may succeed
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v (limit %d)", e.Err, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Status describes an API key's budgets after a request.
+type Status struct {
+	Allowed    bool
+	RetryAfter time.Duration
+
+	// RequestLimit is 0 when requests are not limited
+	RequestLimit      int
+	RequestsRemaining int
+	RequestsReset     time.Duration
+
+	// TokenLimit is 0 when tokens are not limited
+	TokenLimit      int64
+	TokensRemaining int64
+	TokensReset     time.Duration
+}
+
+// state is what is tracked for one API key.
+type state struct {
+	// requests is a token bucket refilled at RequestsPerMinute per minute
+	requests float64
+	refilled time.Time
+
+	sessions int
+
+	day  string
+	used int64
+}
+
+// Limiter applies the rate limits of config.LimitsFor to each principal.
+// Requests are limited by Allow; as a backend.Hook it limits concurrent
+// sessions and counts the tokens they use. Counters are kept in memory;
+// Restore seeds the day's token counts from the usage store at startup.
+type Limiter struct {
+	cfg *config.Reloader
+
+	mu   sync.Mutex
+	keys map[string]*state
+}
+
+// New creates a limiter.
+func New(cfg *config.Reloader) *Limiter {
+	return &Limiter{cfg: cfg, keys: make(map[string]*state)}
+}
+
+// Restore counts the tokens each principal used today, as recorded in
+// store, against its daily budget, so a restart does not reset it.
+func (l *Limiter) Restore(store *usage.Store) error {
+	now := time.Now()
+	today := now.UTC().Truncate(24 * time.Hour)
+	groups, err := store.Query(usage.Query{Start: today, End: today.Add(24 * time.Hour), GroupBy: []string{usage.ByKey}})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, g := range groups {
+		s := l.stateLocked(g.Key, now)
+		s.used += g.InputTokens + g.CacheCreationInputTokens + g.CacheReadInputTokens + g.OutputTokens
+	}
+	return nil
+}
+
+// stateLocked returns the state of principal p as of now.
+func (l *Limiter) stateLocked(p string, now time.Time) *state {
+	s, ok := l.keys[p]
+	if !ok {
+		s = &state{}
+		l.keys[p] = s
+	}
+	if day := now.UTC().Format("2006-01-02"); s.day != day {
+		s.day = day
+		s.used = 0
+	}
+	return s
+}
+
+// untilTomorrow returns the time left until daily counters reset, at
+// midnight UTC.
+func untilTomorrow(now time.Time) time.Duration {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
+
+// Allow takes one request from principal p's budget.
+func (l *Limiter) Allow(p string) Status {
+	lim := l.cfg.Get().LimitsFor(p)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stateLocked(p, now)
+
+	st := Status{Allowed: true}
+	if n := lim.RequestsPerMinute; n > 0 {
+		capacity := float64(n)
+		rate := capacity / 60 // per second
+		if s.refilled.IsZero() {
+			s.requests = capacity
+		} else {
+			s.requests = math.Min(capacity, s.requests+now.Sub(s.refilled).Seconds()*rate)
+		}
+		s.refilled = now
+
+		if s.requests >= 1 {
+			s.requests--
+		} else {
+			st.Allowed = false
+			st.RetryAfter = time.Duration((1 - s.requests) / rate * float64(time.Second))
+		}
+		st.RequestLimit = n
+		st.RequestsRemaining = int(s.requests)
+		st.RequestsReset = time.Duration((capacity - s.requests) / rate * float64(time.Second))
+	}
+	if n := lim.TokensPerDay; n > 0 {
+		st.TokenLimit = n
+		st.TokensRemaining = max(0, n-s.used)
+		st.TokensReset = untilTomorrow(now)
+	}
+	return st
+}
+
+// Start implements backend.Hook. The session reserved by Admit is given
+// back, and its tokens counted, when the run ends.
+func (l *Limiter) Start(req backend.Request) backend.Finish {
+	return func(res backend.Result) []backend.Event {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		s := l.stateLocked(req.Principal, time.Now())
+		s.sessions--
+		s.used += int64(res.Usage.PromptTokens() + res.Usage.OutputTokens)
+		return nil
+	}
+}
+
+// Admit implements backend.Guard. It refuses runs of keys that used up
+// their tokens for the day or have as many sessions as they may, and
+// otherwise reserves a session.
+func (l *Limiter) Admit(req backend.Request) error {
+	lim := l.cfg.Get().LimitsFor(req.Principal)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stateLocked(req.Principal, now)
+	if lim.TokensPerDay > 0 && s.used >= lim.TokensPerDay {
+		return &LimitError{Err: ErrTokenLimit, Limit: lim.TokensPerDay, RetryAfter: untilTomorrow(now)}
+	}
+	if lim.ConcurrentSessions > 0 && s.sessions >= lim.ConcurrentSessions {
+		return &LimitError{Err: ErrSessionLimit, Limit: int64(lim.ConcurrentSessions), RetryAfter: sessionRetry}
+	}
+	s.sessions++
+	return nil
+}
+
+// Release implements backend.Releaser.
+func (l *Limiter) Release(req backend.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stateLocked(req.Principal, time.Now()).sessions--
+}
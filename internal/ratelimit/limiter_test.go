@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/usage"
+)
+
+func newTestLimiter(cfg config.Config) *Limiter {
+	return New(config.NewReloader(&cfg))
+}
+
+func TestAllow(t *testing.T) {
+	l := newTestLimiter(config.Config{RateLimitRPM: 2, RateLimitTokensPerDay: 100})
+
+	for i, remaining := range []int{1, 0} {
+		st := l.Allow("alice")
+		if !st.Allowed || st.RequestLimit != 2 || st.RequestsRemaining != remaining {
+			t.Errorf("request %d = %+v", i, st)
+		}
+		if st.TokenLimit != 100 || st.TokensRemaining != 100 || st.TokensReset <= 0 || st.TokensReset > 24*time.Hour {
+			t.Errorf("request %d token budget = %+v", i, st)
+		}
+	}
+	st := l.Allow("alice")
+	if st.Allowed || st.RetryAfter <= 0 || st.RetryAfter > 30*time.Second {
+		t.Errorf("over limit = %+v", st)
+	}
+
+	// Each principal has its own bucket
+	if st := l.Allow("bob"); !st.Allowed {
+		t.Errorf("bob = %+v", st)
+	}
+
+	unlimited := newTestLimiter(config.Config{})
+	for i := 0; i < 10; i++ {
+		if st := unlimited.Allow("alice"); !st.Allowed || st.RequestLimit != 0 || st.TokenLimit != 0 {
+			t.Fatalf("unlimited request %d = %+v", i, st)
+		}
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	l := newTestLimiter(config.Config{RateLimitSessions: 1})
+	req := backend.Request{Principal: "alice"}
+
+	if err := l.Admit(req); err != nil {
+		t.Fatalf("first Admit = %v", err)
+	}
+	err := l.Admit(req)
+	var limited *LimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrSessionLimit) || !errors.Is(err, backend.ErrBusy) || limited.Limit != 1 {
+		t.Fatalf("second Admit = %v", err)
+	}
+
+	// A run that never started gives its session back
+	l.Release(req)
+	if err := l.Admit(req); err != nil {
+		t.Fatalf("Admit after Release = %v", err)
+	}
+
+	// So does a finished run
+	l.Start(req)(backend.Result{})
+	if err := l.Admit(req); err != nil {
+		t.Errorf("Admit after Finish = %v", err)
+	}
+}
+
+func TestTokenLimit(t *testing.T) {
+	l := newTestLimiter(config.Config{RateLimitTokensPerDay: 100})
+	req := backend.Request{Principal: "alice"}
+
+	if err := l.Admit(req); err != nil {
+		t.Fatal(err)
+	}
+	l.Start(req)(backend.Result{Usage: backend.Usage{InputTokens: 60, CacheReadInputTokens: 20, OutputTokens: 30}})
+
+	if st := l.Allow("alice"); st.TokensRemaining != 0 {
+		t.Errorf("remaining tokens = %d, want 0", st.TokensRemaining)
+	}
+	err := l.Admit(req)
+	var limited *LimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrTokenLimit) || limited.RetryAfter > 24*time.Hour {
+		t.Fatalf("Admit over the token limit = %v", err)
+	}
+	// Tasks and batches wait for the budget rather than fail
+	if !errors.Is(err, backend.ErrBusy) {
+		t.Error("token limit does not wrap backend.ErrBusy")
+	}
+
+	if err := l.Admit(backend.Request{Principal: "bob"}); err != nil {
+		t.Errorf("bob Admit = %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	store, err := usage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.Add(usage.Record{Time: now.Unix(), Key: "alice", InputTokens: 40, CacheCreationInputTokens: 10, OutputTokens: 10})
+	store.Add(usage.Record{Time: now.Add(-48 * time.Hour).Unix(), Key: "bob", InputTokens: 1000})
+
+	l := newTestLimiter(config.Config{RateLimitTokensPerDay: 100})
+	if err := l.Restore(store); err != nil {
+		t.Fatal(err)
+	}
+	if st := l.Allow("alice"); st.TokensRemaining != 40 {
+		t.Errorf("alice remaining = %d, want 40", st.TokensRemaining)
+	}
+	// Earlier days do not count
+	if st := l.Allow("bob"); st.TokensRemaining != 100 {
+		t.Errorf("bob remaining = %d, want 100", st.TokensRemaining)
+	}
+}
+
+func TestLimitsFromConfigFile(t *testing.T) {
+	lifted := int64(0)
+	two := 2
+	l := newTestLimiter(config.Config{
+		RateLimitSessions:     1,
+		RateLimitTokensPerDay: 10,
+		RateLimits: map[string]config.RateLimit{
+			"alice": {TokensPerDay: &lifted, ConcurrentSessions: &two},
+		},
+	})
+
+	req := backend.Request{Principal: "alice"}
+	for i := 0; i < 2; i++ {
+		if err := l.Admit(req); err != nil {
+			t.Fatalf("Admit %d = %v", i, err)
+		}
+	}
+	if err := l.Admit(req); !errors.Is(err, ErrSessionLimit) {
+		t.Errorf("third Admit = %v", err)
+	}
+	if st := l.Allow("alice"); st.TokenLimit != 0 {
+		t.Errorf("lifted token limit = %d", st.TokenLimit)
+	}
+}
@@ -0,0 +1,125 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"claude-code-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AnthropicEncoder produces the native Messages API event sequence:
+// message_start, then content_block_start/delta/stop for each block,
+// message_delta and message_stop.
+type AnthropicEncoder struct {
+	MessageID string
+	Model     string
+	SessionID string
+	ProjectID string
+
+	index     int
+	blockOpen bool
+}
+
+// NewAnthropicEncoder creates an encoder for a single message.
+func NewAnthropicEncoder(model, sessionID, projectID string) *AnthropicEncoder {
+	return &AnthropicEncoder{
+		MessageID: NewAnthropicMessageID(),
+		Model:     model,
+		SessionID: sessionID,
+		ProjectID: projectID,
+	}
+}
+
+// NewAnthropicMessageID returns a Messages API style message ID.
+func NewAnthropicMessageID() string {
+	return "msg_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+}
+
+// formatNamed formats data as an SSE event with an event name.
+func formatNamed(event string, data interface{}) string {
+	jsonData, _ := json.Marshal(data)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", event, jsonData)
+}
+
+// MessageStart returns the message_start event.
+func (e *AnthropicEncoder) MessageStart(inputTokens int) string {
+	return formatNamed("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": models.AnthropicMessageResponse{
+			ID:        e.MessageID,
+			Type:      "message",
+			Role:      "assistant",
+			Model:     e.Model,
+			Content:   []models.AnthropicContentBlock{},
+			Usage:     models.AnthropicUsage{InputTokens: inputTokens},
+			SessionID: e.SessionID,
+			ProjectID: e.ProjectID,
+		},
+	})
+}
+
+// Ping returns a ping event.
+func (e *AnthropicEncoder) Ping() string {
+	return formatNamed("ping", map[string]string{"type": "ping"})
+}
+
+// Text returns the events for a complete text block: its start, a single
+// delta carrying the text, and its stop.
+func (e *AnthropicEncoder) Text(text string) string {
+	var b strings.Builder
+	b.WriteString(e.closeBlock())
+	b.WriteString(formatNamed("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         e.index,
+		"content_block": map[string]string{"type": "text", "text": ""},
+	}))
+	e.blockOpen = true
+	b.WriteString(formatNamed("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": e.index,
+		"delta": map[string]string{"type": "text_delta", "text": text},
+	}))
+	b.WriteString(e.closeBlock())
+	return b.String()
+}
+
+func (e *AnthropicEncoder) closeBlock() string {
+	if !e.blockOpen {
+		return ""
+	}
+	e.blockOpen = false
+	ev := formatNamed("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": e.index,
+	})
+	e.index++
+	return ev
+}
+
+// Finish returns the message_delta and message_stop events.
+func (e *AnthropicEncoder) Finish(stopReason string, usage models.AnthropicUsage) string {
+	return e.closeBlock() +
+		formatNamed("message_delta", map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": usage,
+		}) +
+		formatNamed("message_stop", map[string]string{"type": "message_stop"})
+}
+
+// Extension formats a gateway-specific event, which Anthropic clients
+// skip as an unknown event type.
+func (e *AnthropicEncoder) Extension(event string, data interface{}) string {
+	return formatNamed(event, data)
+}
+
+// Error returns an error event.
+func (e *AnthropicEncoder) Error(errType, message string) string {
+	return formatNamed("error", models.AnthropicErrorResponse{
+		Type:  "error",
+		Error: models.AnthropicErrorDetail{Type: errType, Message: message},
+	})
+}
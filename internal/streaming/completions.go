@@ -0,0 +1,86 @@
+package streaming
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"claude-code-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// NewCompletionID returns a legacy completions style ID.
+func NewCompletionID() string {
+	return fmt.Sprintf("cmpl-%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+}
+
+// CompletionConverter builds text_completion streaming chunks.
+type CompletionConverter struct {
+	Model        string
+	CompletionID string
+	Created      int64
+}
+
+// NewCompletionConverter creates a new completions converter.
+func NewCompletionConverter(model string) *CompletionConverter {
+	return &CompletionConverter{
+		Model:        model,
+		CompletionID: NewCompletionID(),
+		Created:      time.Now().Unix(),
+	}
+}
+
+// CreateTextChunk creates a chunk carrying text for the choice at index.
+func (c *CompletionConverter) CreateTextChunk(index int, text string) models.CompletionResponse {
+	return c.chunk(models.CompletionChoice{Text: text, Index: index})
+}
+
+// CreateFinalChunk creates the chunk that ends the choice at index.
+func (c *CompletionConverter) CreateFinalChunk(index int, finishReason string) models.CompletionResponse {
+	return c.chunk(models.CompletionChoice{Index: index, FinishReason: &finishReason})
+}
+
+func (c *CompletionConverter) chunk(choice models.CompletionChoice) models.CompletionResponse {
+	return models.CompletionResponse{
+		ID:      c.CompletionID,
+		Object:  "text_completion",
+		Created: c.Created,
+		Model:   c.Model,
+		Choices: []models.CompletionChoice{choice},
+	}
+}
+
+// TruncateAtStop cuts text at the earliest occurrence of any stop sequence.
+// It reports whether a stop sequence was found.
+func TruncateAtStop(text string, stop []string) (string, bool) {
+	cut := -1
+	for _, s := range stop {
+		if s == "" {
+			continue
+		}
+		if i := strings.Index(text, s); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut < 0 {
+		return text, false
+	}
+	return text[:cut], true
+}
+
+// PartialStop returns the length of the longest suffix of text that is the
+// start of a stop sequence. Streaming callers hold those bytes back until
+// more text shows whether the stop sequence completes.
+func PartialStop(text string, stop []string) int {
+	longest := 0
+	for _, s := range stop {
+		for n := len(s) - 1; n > longest; n-- {
+			if strings.HasSuffix(text, s[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,25 @@
+package streaming
+
+import (
+	"encoding/json"
+
+	"claude-code-api/internal/models"
+)
+
+// NDJSONContentType is the content type of newline-delimited JSON streams.
+const NDJSONContentType = "application/x-ndjson"
+
+// NDJSONFormatter formats data as newline-delimited JSON, the streaming
+// format of the Ollama API.
+type NDJSONFormatter struct{}
+
+// FormatEvent formats a data object as a single line.
+func (f *NDJSONFormatter) FormatEvent(data interface{}) string {
+	jsonData, _ := json.Marshal(data)
+	return string(jsonData) + "\n"
+}
+
+// FormatError formats an error line.
+func (f *NDJSONFormatter) FormatError(errMsg string) string {
+	return f.FormatEvent(models.OllamaErrorResponse{Error: errMsg})
+}
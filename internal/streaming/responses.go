@@ -0,0 +1,138 @@
+package streaming
+
+import (
+	"strings"
+
+	"claude-code-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// NewResponseID returns a Responses API style response ID.
+func NewResponseID() string {
+	return "resp_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// NewOutputItemID returns a Responses API style output message ID.
+func NewOutputItemID() string {
+	return "msg_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// OutputMessage builds a completed assistant output message.
+func OutputMessage(id, text string) models.ResponseOutputItem {
+	return models.ResponseOutputItem{
+		Type:   "message",
+		ID:     id,
+		Status: "completed",
+		Role:   "assistant",
+		Content: []models.ResponseOutputContent{{
+			Type:        "output_text",
+			Text:        text,
+			Annotations: []interface{}{},
+		}},
+	}
+}
+
+// ResponsesEncoder produces Responses API streaming events for a single
+// response, updating Response as the stream progresses so that it holds the
+// final object once Completed or Failed has been called.
+type ResponsesEncoder struct {
+	Response *models.Response
+
+	seq    int
+	itemID string
+	text   strings.Builder
+	open   bool
+}
+
+// NewResponsesEncoder creates an encoder for resp, which should be in status
+// "in_progress".
+func NewResponsesEncoder(resp *models.Response) *ResponsesEncoder {
+	return &ResponsesEncoder{Response: resp, itemID: NewOutputItemID()}
+}
+
+func (e *ResponsesEncoder) event(eventType string, fields map[string]interface{}) string {
+	fields["type"] = eventType
+	fields["sequence_number"] = e.seq
+	e.seq++
+	return formatNamed(eventType, fields)
+}
+
+// Created returns the response.created and response.in_progress events.
+func (e *ResponsesEncoder) Created() string {
+	return e.event("response.created", map[string]interface{}{"response": e.Response}) +
+		e.event("response.in_progress", map[string]interface{}{"response": e.Response})
+}
+
+// TextDelta returns the events for a chunk of output text, opening the output
+// message on first use. Consecutive chunks are separated by a newline.
+func (e *ResponsesEncoder) TextDelta(text string) string {
+	var b strings.Builder
+	if !e.open {
+		e.open = true
+		item := OutputMessage(e.itemID, "")
+		item.Status = "in_progress"
+		item.Content = []models.ResponseOutputContent{}
+		b.WriteString(e.event("response.output_item.added", map[string]interface{}{
+			"output_index": 0,
+			"item":         item,
+		}))
+		b.WriteString(e.event("response.content_part.added", map[string]interface{}{
+			"item_id":       e.itemID,
+			"output_index":  0,
+			"content_index": 0,
+			"part":          models.ResponseOutputContent{Type: "output_text", Text: "", Annotations: []interface{}{}},
+		}))
+	} else {
+		text = "\n" + text
+	}
+	e.text.WriteString(text)
+
+	b.WriteString(e.event("response.output_text.delta", map[string]interface{}{
+		"item_id":       e.itemID,
+		"output_index":  0,
+		"content_index": 0,
+		"delta":         text,
+	}))
+	return b.String()
+}
+
+// Completed closes the output message and returns the final events.
+func (e *ResponsesEncoder) Completed(usage *models.ResponseUsage) string {
+	var b strings.Builder
+	text := e.text.String()
+	item := OutputMessage(e.itemID, text)
+
+	if e.open {
+		b.WriteString(e.event("response.output_text.done", map[string]interface{}{
+			"item_id":       e.itemID,
+			"output_index":  0,
+			"content_index": 0,
+			"text":          text,
+		}))
+		b.WriteString(e.event("response.content_part.done", map[string]interface{}{
+			"item_id":       e.itemID,
+			"output_index":  0,
+			"content_index": 0,
+			"part":          item.Content[0],
+		}))
+		b.WriteString(e.event("response.output_item.done", map[string]interface{}{
+			"output_index": 0,
+			"item":         item,
+		}))
+		e.Response.Output = []models.ResponseOutputItem{item}
+	}
+
+	e.Response.Status = "completed"
+	e.Response.Usage = usage
+	b.WriteString(e.event("response.completed", map[string]interface{}{"response": e.Response}))
+	return b.String()
+}
+
+// Failed marks the response failed and returns the error events.
+func (e *ResponsesEncoder) Failed(code, message string) string {
+	e.Response.Status = "failed"
+	e.Response.Error = &models.ResponseError{Code: code, Message: message}
+	return e.event("error", map[string]interface{}{"code": code, "message": message, "param": nil}) +
+		e.event("response.failed", map[string]interface{}{"response": e.Response})
+}
@@ -20,6 +20,19 @@ func (f *SSEFormatter) FormatEvent(data interface{}) string {
 	return fmt.Sprintf("data: %s\n\n", jsonData)
 }
 
+// FormatNamedEvent formats a data object as an SSE event with an event name
+// and an ID clients can resume from with Last-Event-ID.
+func (f *SSEFormatter) FormatNamedEvent(id, event string, data interface{}) string {
+	jsonData, _ := json.Marshal(data)
+	return fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", id, event, jsonData)
+}
+
+// FormatExtensionEvent formats a gateway-specific event. The event name
+// lets OpenAI clients, which only read unnamed events, skip it.
+func (f *SSEFormatter) FormatExtensionEvent(event string, data interface{}) string {
+	return formatNamed(event, data)
+}
+
 // FormatDone returns the SSE completion signal.
 func (f *SSEFormatter) FormatDone() string {
 	return "data: [DONE]\n\n"
@@ -0,0 +1,433 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/ratelimit"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Errors returned by Manager.
+var (
+	ErrNotFound = errors.New("task not found")
+	ErrFinished = errors.New("task already finished")
+)
+
+// busyRetryInterval is how often a queued task retries a backend at capacity.
+const busyRetryInterval = 500 * time.Millisecond
+
+// pruneInterval is how often finished tasks past TASK_RETENTION_HOURS are
+// dropped.
+const pruneInterval = 10 * time.Minute
+
+// cancelWait bounds how long Cancel waits for a task to stop.
+const cancelWait = 5 * time.Second
+
+// entry is the in-memory state of a task. All fields are guarded by
+// Manager.mu.
+type entry struct {
+	rec             record
+	events          []Event
+	loaded          bool
+	changed         chan struct{}
+	done            chan struct{}
+	cancel          context.CancelFunc
+	cancelRequested bool
+}
+
+// Manager runs tasks in the background and keeps their state on disk.
+//
+// Tasks start as soon as the backend has capacity: a backend at its
+// concurrency limit leaves them queued rather than failing them. On restart,
+// queued tasks are resumed and tasks that were running are marked failed.
+// Finished tasks are deleted once TASK_RETENTION_HOURS have passed.
+type Manager struct {
+	cfg      *config.Reloader
+	backends *backend.Registry
+	dir      string
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks map[string]*entry
+}
+
+// NewManager creates a task manager storing tasks under TASKS_DIR and
+// resumes the tasks found there.
+func NewManager(cfg *config.Reloader, backends *backend.Registry) (*Manager, error) {
+	dir := cfg.Get().TasksDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tasks dir: %w", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		cfg:      cfg,
+		backends: backends,
+		dir:      dir,
+		ctx:      ctx,
+		stop:     stop,
+		tasks:    make(map[string]*entry),
+	}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks dir: %w", err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		rec, err := m.load(d.Name())
+		if err != nil {
+			log.Warn().Err(err).Str("task_id", d.Name()).Msg("Skipping unreadable task")
+			continue
+		}
+
+		e := &entry{rec: rec, changed: make(chan struct{}), done: make(chan struct{})}
+		m.tasks[rec.Task.ID] = e
+
+		switch rec.Task.Status {
+		case StatusQueued:
+			log.Info().Str("task_id", rec.Task.ID).Msg("Resuming queued task")
+			m.start(e)
+		case StatusRunning:
+			// The process running it died with the previous server
+			m.mu.Lock()
+			m.finishLocked(e, StatusFailed, "", "interrupted by server restart", nil)
+			m.mu.Unlock()
+		default:
+			close(e.done)
+		}
+	}
+
+	m.prune()
+	m.wg.Add(1)
+	go m.pruneLoop()
+	return m, nil
+}
+
+// Create queues a task for req of a caller in namespace ns and starts it in
+// the background.
+func (m *Manager) Create(ns string, req backend.Request, metadata map[string]string) (Task, error) {
+	t := Task{
+		ID:        "task_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:    "task",
+		Status:    StatusQueued,
+		Model:     req.Model,
+		ProjectID: req.ProjectID,
+		SessionID: req.ResumeSessionID,
+		Metadata:  metadata,
+		CreatedAt: time.Now().Unix(),
+		Namespace: ns,
+	}
+	e := &entry{
+		rec:     record{Task: t, Request: req},
+		loaded:  true,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	if err := m.save(e.rec); err != nil {
+		m.mu.Unlock()
+		return Task{}, err
+	}
+	m.tasks[t.ID] = e
+	m.addEventLocked(e, Event{Status: StatusQueued, Event: backend.Event{Type: EventStatus}})
+	m.mu.Unlock()
+
+	log.Info().Str("task_id", t.ID).Str("request_id", req.RequestID).Str("model", req.Model).Msg("Task created")
+	m.start(e)
+	return t, nil
+}
+
+// Get returns the current state of a task of namespace ns.
+func (m *Manager) Get(ns, id string) (Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.tasks[id]
+	if !ok || e.rec.Task.Namespace != ns {
+		return Task{}, ErrNotFound
+	}
+	return e.rec.Task, nil
+}
+
+// Events returns the events after seq of a task of namespace ns, whether
+// the task has finished, and a channel that is closed when more events
+// arrive.
+func (m *Manager) Events(ns, id string, after int) ([]Event, bool, <-chan struct{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.tasks[id]
+	if !ok || e.rec.Task.Namespace != ns {
+		return nil, false, nil, ErrNotFound
+	}
+	if err := m.ensureEvents(e); err != nil {
+		return nil, false, nil, err
+	}
+
+	var events []Event
+	if after < len(e.events) {
+		start := after
+		if start < 0 {
+			start = 0
+		}
+		events = append(events, e.events[start:]...)
+	}
+	return events, e.rec.Task.Status.Terminal(), e.changed, nil
+}
+
+// Cancel stops a task of namespace ns and waits briefly for it to settle.
+func (m *Manager) Cancel(ns, id string) (Task, error) {
+	m.mu.Lock()
+	e, ok := m.tasks[id]
+	if !ok || e.rec.Task.Namespace != ns {
+		m.mu.Unlock()
+		return Task{}, ErrNotFound
+	}
+	if e.rec.Task.Status.Terminal() {
+		t := e.rec.Task
+		m.mu.Unlock()
+		return t, ErrFinished
+	}
+	e.cancelRequested = true
+	cancel, done := e.cancel, e.done
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	select {
+	case <-done:
+	case <-time.After(cancelWait):
+	}
+	return m.Get(ns, id)
+}
+
+// Shutdown stops all running tasks and waits for them to exit. Tasks that
+// have not started stay queued and resume on the next start.
+func (m *Manager) Shutdown() {
+	m.stop()
+	m.wg.Wait()
+}
+
+func (m *Manager) start(e *entry) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.mu.Lock()
+	e.cancel = cancel
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(ctx, e)
+}
+
+func (m *Manager) run(ctx context.Context, e *entry) {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	req := e.rec.Request
+	m.mu.Unlock()
+
+	run, cancel, err := m.startRun(ctx, req)
+	if err != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		switch {
+		case e.cancelRequested:
+			m.finishLocked(e, StatusCancelled, "", "", nil)
+		case m.ctx.Err() != nil:
+			// Shutting down before the task started; leave it queued
+		default:
+			m.finishLocked(e, StatusFailed, "", err.Error(), nil)
+		}
+		return
+	}
+	defer cancel()
+
+	m.mu.Lock()
+	e.rec.Task.Status = StatusRunning
+	e.rec.Task.StartedAt = time.Now().Unix()
+	m.saveLocked(e)
+	m.addEventLocked(e, Event{Status: StatusRunning, Event: backend.Event{Type: EventStatus}})
+	m.mu.Unlock()
+
+	var parts []string
+	var runErr string
+	for ev := range run.Events() {
+		switch ev.Type {
+		case backend.EventText:
+			parts = append(parts, ev.Text)
+		case backend.EventError:
+			runErr = ev.Error
+		}
+
+		m.mu.Lock()
+		if ev.SessionID != "" && e.rec.Task.SessionID != ev.SessionID {
+			e.rec.Task.SessionID = ev.SessionID
+			m.saveLocked(e)
+		}
+		m.addEventLocked(e, Event{Event: ev})
+		m.mu.Unlock()
+	}
+
+	usage := run.Usage()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case e.cancelRequested:
+		m.finishLocked(e, StatusCancelled, strings.Join(parts, "\n"), "", &usage)
+	case m.ctx.Err() != nil:
+		m.finishLocked(e, StatusFailed, strings.Join(parts, "\n"), "interrupted by server shutdown", &usage)
+	case runErr != "":
+		m.finishLocked(e, StatusFailed, strings.Join(parts, "\n"), runErr, &usage)
+	default:
+		m.finishLocked(e, StatusSucceeded, strings.Join(parts, "\n"), "", &usage)
+	}
+}
+
+// startRun starts the task's run, waiting while the backend is at capacity.
+func (m *Manager) startRun(ctx context.Context, req backend.Request) (backend.Run, context.CancelFunc, error) {
+	for {
+		b, err := m.backends.ForModel(req.Model)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		timeout := time.Duration(m.cfg.Get().TaskTimeoutMinutes) * time.Minute
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		run, err := b.CreateSession(runCtx, req)
+		if err == nil {
+			return run, cancel, nil
+		}
+		cancel()
+		// The token budget resets only the next day, too late to keep
+		// the task waiting
+		if !errors.Is(err, backend.ErrBusy) || errors.Is(err, ratelimit.ErrTokenLimit) {
+			return nil, nil, err
+		}
+
+		select {
+		case <-time.After(busyRetryInterval):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// pruneLoop drops expired tasks until the manager shuts down.
+func (m *Manager) pruneLoop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.prune()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// prune deletes the tasks that finished more than TASK_RETENTION_HOURS ago,
+// with their events.
+func (m *Manager) prune() {
+	hours := m.cfg.Get().TaskRetentionHours
+	if hours <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour).Unix()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, e := range m.tasks {
+		if t := e.rec.Task; !t.Status.Terminal() || t.CompletedAt > cutoff {
+			continue
+		}
+		if err := os.RemoveAll(m.taskDir(id)); err != nil {
+			log.Error().Err(err).Str("task_id", id).Msg("Failed to delete expired task")
+			continue
+		}
+		delete(m.tasks, id)
+		log.Info().Str("task_id", id).Msg("Deleted expired task")
+	}
+}
+
+// finishLocked moves the task to a terminal state.
+func (m *Manager) finishLocked(e *entry, status Status, result, errMsg string, usage *backend.Usage) {
+	t := &e.rec.Task
+	t.Status = status
+	t.Result = result
+	t.Error = errMsg
+	t.Usage = usage
+	t.CompletedAt = time.Now().Unix()
+	m.saveLocked(e)
+	m.addEventLocked(e, Event{Status: status, Event: backend.Event{Type: EventStatus, Error: errMsg}})
+	close(e.done)
+
+	log.Info().Str("task_id", t.ID).Str("status", string(status)).Str("error", errMsg).Msg("Task finished")
+}
+
+func (m *Manager) saveLocked(e *entry) {
+	if err := m.save(e.rec); err != nil {
+		log.Error().Err(err).Str("task_id", e.rec.Task.ID).Msg("Failed to save task")
+	}
+}
+
+// ensureEvents loads the events of a task read from disk.
+func (m *Manager) ensureEvents(e *entry) error {
+	if e.loaded {
+		return nil
+	}
+	events, err := m.loadEvents(e.rec.Task.ID)
+	if err != nil {
+		return err
+	}
+	e.events = events
+	e.loaded = true
+	return nil
+}
+
+// addEventLocked numbers, persists and publishes an event.
+func (m *Manager) addEventLocked(e *entry, ev Event) {
+	if err := m.ensureEvents(e); err != nil {
+		log.Error().Err(err).Str("task_id", e.rec.Task.ID).Msg("Failed to load task events")
+	}
+
+	ev.Seq = len(e.events) + 1
+	ev.CreatedAt = time.Now().Unix()
+	e.events = append(e.events, ev)
+	if err := m.appendEvent(e.rec.Task.ID, ev); err != nil {
+		log.Error().Err(err).Str("task_id", e.rec.Task.ID).Msg("Failed to save task event")
+	}
+
+	if ev.Type != EventStatus {
+		p := &e.rec.Task.Progress
+		p.Events++
+		p.LastEventAt = ev.CreatedAt
+		switch ev.Type {
+		case backend.EventText:
+			p.TextBlocks++
+		case backend.EventToolCall:
+			p.ToolCalls++
+		}
+	}
+
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
@@ -0,0 +1,115 @@
+package tasks
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/backend"
+	"claude-code-api/internal/config"
+	"claude-code-api/internal/ratelimit"
+)
+
+// limited is a guard refusing every run with err.
+type limited struct{ err error }
+
+func (g limited) Admit(backend.Request) error { return g.err }
+
+func (limited) Start(backend.Request) backend.Finish {
+	return func(backend.Result) []backend.Event { return nil }
+}
+
+func newTestManager(t *testing.T, cfg config.Config, hooks ...backend.Hook) *Manager {
+	t.Helper()
+	cfg.TasksDir = t.TempDir()
+	cfg.TaskTimeoutMinutes = 1
+	cfg.DefaultBackend = "echo"
+	live := config.NewReloader(&cfg)
+	backends := backend.NewRegistry(live)
+	backends.Register(backend.NewEcho())
+	for _, h := range hooks {
+		backends.Use(h)
+	}
+	m, err := NewManager(live, backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Shutdown)
+	return m
+}
+
+func waitForTask(t *testing.T, m *Manager, id string) Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		task, err := m.Get("", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Status.Terminal() {
+			return task
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("task %s did not finish", id)
+	return Task{}
+}
+
+func TestTokenLimitFailsTask(t *testing.T) {
+	m := newTestManager(t, config.Config{}, limited{&ratelimit.LimitError{Err: ratelimit.ErrTokenLimit}})
+	task, err := m.Create("", backend.Request{Prompt: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := waitForTask(t, m, task.ID); got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("task over the token limit = %+v", got)
+	}
+}
+
+func TestSessionLimitQueuesTask(t *testing.T) {
+	m := newTestManager(t, config.Config{}, limited{ratelimit.ErrSessionLimit})
+	task, err := m.Create("", backend.Request{Prompt: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * busyRetryInterval)
+	if got, _ := m.Get("", task.ID); got.Status != StatusQueued {
+		t.Errorf("task over the session limit = %+v, want it queued", got)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	m := newTestManager(t, config.Config{TaskRetentionHours: 1})
+	old, _ := m.Create("", backend.Request{Prompt: "old"}, nil)
+	recent, _ := m.Create("", backend.Request{Prompt: "recent"}, nil)
+	waitForTask(t, m, old.ID)
+	waitForTask(t, m, recent.ID)
+
+	m.mu.Lock()
+	e := m.tasks[old.ID]
+	e.rec.Task.CompletedAt = time.Now().Add(-2 * time.Hour).Unix()
+	m.saveLocked(e)
+	m.mu.Unlock()
+
+	// Expired tasks are dropped both at startup and while running
+	reloaded, err := NewManager(m.cfg, m.backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Shutdown()
+	if _, err := reloaded.Get("", old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired task after restart = %v", err)
+	}
+
+	m.prune()
+	if _, err := m.Get("", old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired task = %v", err)
+	}
+	if _, err := os.Stat(m.taskDir(old.ID)); !os.IsNotExist(err) {
+		t.Errorf("expired task dir survived: %v", err)
+	}
+	if _, err := m.Get("", recent.ID); err != nil {
+		t.Errorf("recent task = %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package tasks runs agent requests asynchronously and persists their state,
+// so long-running jobs outlive the HTTP request that started them.
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"claude-code-api/internal/backend"
+)
+
+// Status is the lifecycle state of a task.
+type Status string
+
+// Task states. Succeeded, failed and cancelled are terminal.
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Terminal reports whether a task in this state will not change again.
+func (s Status) Terminal() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// EventStatus is the type of the events recording status changes.
+const EventStatus backend.EventType = "status"
+
+// Progress summarises the events a task has produced so far.
+type Progress struct {
+	Events      int   `json:"events"`
+	TextBlocks  int   `json:"text_blocks"`
+	ToolCalls   int   `json:"tool_calls"`
+	LastEventAt int64 `json:"last_event_at,omitempty"`
+}
+
+// Task is the persisted state of an asynchronous run.
+type Task struct {
+	ID          string            `json:"id"`
+	Object      string            `json:"object"`
+	Status      Status            `json:"status"`
+	Model       string            `json:"model"`
+	ProjectID   string            `json:"project_id"`
+	SessionID   string            `json:"session_id,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   int64             `json:"created_at"`
+	StartedAt   int64             `json:"started_at,omitempty"`
+	CompletedAt int64             `json:"completed_at,omitempty"`
+	Progress    Progress          `json:"progress"`
+	Result      string            `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Usage       *backend.Usage    `json:"usage,omitempty"`
+	// Namespace is that of the caller that created the task; only callers
+	// in it can see or cancel the task
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Event is a task event: an agent event or a status change, numbered from 1
+// in the order it occurred.
+type Event struct {
+	Seq       int    `json:"seq"`
+	CreatedAt int64  `json:"created_at"`
+	Status    Status `json:"status,omitempty"`
+	backend.Event
+}
+
+// record is the on-disk form of a task: its state plus the request needed
+// to run it again after a restart.
+type record struct {
+	Task    Task            `json:"task"`
+	Request backend.Request `json:"request"`
+}
+
+func (m *Manager) taskDir(id string) string {
+	return filepath.Join(m.dir, id)
+}
+
+// save writes the task record atomically.
+func (m *Manager) save(rec record) error {
+	dir := m.taskDir(rec.Task.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create task dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "task.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write task: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, "task.json"))
+}
+
+func (m *Manager) load(id string) (record, error) {
+	var rec record
+	data, err := os.ReadFile(filepath.Join(m.taskDir(id), "task.json"))
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("corrupt task %s: %w", id, err)
+	}
+	return rec, nil
+}
+
+func (m *Manager) appendEvent(id string, ev Event) error {
+	f, err := os.OpenFile(filepath.Join(m.taskDir(id), "events.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, _ := json.Marshal(ev)
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (m *Manager) loadEvents(id string) ([]Event, error) {
+	f, err := os.Open(filepath.Join(m.taskDir(id), "events.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var events []Event
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// A torn final line from a crash; keep what was complete
+			break
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
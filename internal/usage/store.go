@@ -0,0 +1,322 @@
+// Package usage records the tokens, cost and time every run uses and
+// reports them grouped by API key, model, project or day.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-code-api/internal/backend"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dayFormat names the daily record files and the day groups.
+const dayFormat = "2006-01-02"
+
+// Dimensions usage can be grouped by.
+const (
+	ByKey     = "key"
+	ByOwner   = "owner"
+	ByModel   = "model"
+	ByProject = "project"
+	ByDay     = "day"
+)
+
+// Dimensions lists every dimension in the order groups are sorted by.
+var Dimensions = []string{ByDay, ByOwner, ByKey, ByModel, ByProject}
+
+// ErrInvalidDimension is returned for a group_by that is not a dimension.
+var ErrInvalidDimension = errors.New("invalid dimension")
+
+// Record is the usage of one run.
+type Record struct {
+	// Time is when the run ended, as a Unix time
+	Time      int64  `json:"time"`
+	RequestID string `json:"request_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Key is the principal of the API key; empty when auth is off
+	Key       string `json:"key,omitempty"`
+	Model     string `json:"model"`
+	Namespace string `json:"namespace,omitempty"`
+	Project   string `json:"project,omitempty"`
+
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	DurationMs               int64   `json:"duration_ms"`
+	Error                    bool    `json:"error,omitempty"`
+}
+
+// projectRef names the record's project as clients write it.
+func (r Record) projectRef() string {
+	if r.Namespace == "" || r.Project == "" {
+		return r.Project
+	}
+	return r.Namespace + ":" + r.Project
+}
+
+// Totals adds up usage.
+type Totals struct {
+	Requests                 int     `json:"requests"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	DurationMs               int64   `json:"duration_ms"`
+}
+
+func (t *Totals) add(r Record) {
+	t.Requests++
+	t.InputTokens += int64(r.InputTokens)
+	t.OutputTokens += int64(r.OutputTokens)
+	t.CacheCreationInputTokens += int64(r.CacheCreationInputTokens)
+	t.CacheReadInputTokens += int64(r.CacheReadInputTokens)
+	t.CostUSD += r.CostUSD
+	t.DurationMs += r.DurationMs
+}
+
+// Add adds o to t.
+func (t *Totals) Add(o Totals) {
+	t.Requests += o.Requests
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.CacheCreationInputTokens += o.CacheCreationInputTokens
+	t.CacheReadInputTokens += o.CacheReadInputTokens
+	t.CostUSD += o.CostUSD
+	t.DurationMs += o.DurationMs
+}
+
+// Group is the usage of the records sharing the values of the dimensions
+// grouped by; the other dimensions are empty. Groups by key also carry the
+// key's name and owner.
+type Group struct {
+	Day     string `json:"day,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Project string `json:"project,omitempty"`
+	Totals
+}
+
+// Value returns the group's value for dimension d.
+func (g Group) Value(d string) string {
+	switch d {
+	case ByDay:
+		return g.Day
+	case ByOwner:
+		return g.Owner
+	case ByKey:
+		return g.Key
+	case ByModel:
+		return g.Model
+	case ByProject:
+		return g.Project
+	}
+	return ""
+}
+
+// Query selects and groups records.
+type Query struct {
+	// Start and End bound the time of the records, End excluded
+	Start, End time.Time
+	// GroupBy lists dimensions; none adds up all records
+	GroupBy []string
+	// Key, if set, selects the records of one API key
+	Key string
+	// Describe returns the name and owner of an API key; without it a key
+	// is its own name and owner
+	Describe func(key string) (name, owner string)
+}
+
+// Store keeps records in one JSON lines file per UTC day.
+type Store struct {
+	dir string
+	// mu serialises writes
+	mu sync.Mutex
+}
+
+// NewStore opens the usage store in dir.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create usage dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(day time.Time) string {
+	return filepath.Join(s.dir, "usage_"+day.UTC().Format(dayFormat)+".jsonl")
+}
+
+// Add appends r to the file of its day and syncs it to disk.
+func (s *Store) Add(r Record) error {
+	line, _ := json.Marshal(r)
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(time.Unix(r.Time, 0)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	return f.Sync()
+}
+
+// Query adds up the records selected by q into groups, sorted by day, owner,
+// key, model and project.
+func (s *Store) Query(q Query) ([]Group, error) {
+	by := make(map[string]bool, len(q.GroupBy))
+	for _, d := range q.GroupBy {
+		if !validDimension(d) {
+			return nil, fmt.Errorf("%w %q (want %s)", ErrInvalidDimension, d, strings.Join(Dimensions, ", "))
+		}
+		by[d] = true
+	}
+
+	describe := q.Describe
+	if describe == nil {
+		describe = func(key string) (string, string) { return key, key }
+	}
+
+	groups := make(map[Group]*Totals)
+	start, end := q.Start.Unix(), q.End.Unix()
+	for day := q.Start.UTC().Truncate(24 * time.Hour); day.Before(q.End); day = day.Add(24 * time.Hour) {
+		err := s.scan(day, func(r Record) {
+			if r.Time < start || r.Time >= end || (q.Key != "" && r.Key != q.Key) {
+				return
+			}
+			var g Group
+			if by[ByDay] {
+				g.Day = time.Unix(r.Time, 0).UTC().Format(dayFormat)
+			}
+			if by[ByKey] {
+				g.Key = r.Key
+				g.Name, g.Owner = describe(r.Key)
+			}
+			if by[ByOwner] {
+				_, g.Owner = describe(r.Key)
+			}
+			if by[ByModel] {
+				g.Model = r.Model
+			}
+			if by[ByProject] {
+				g.Project = r.projectRef()
+			}
+			t, ok := groups[g]
+			if !ok {
+				t = &Totals{}
+				groups[g] = t
+			}
+			t.add(r)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]Group, 0, len(groups))
+	for g, t := range groups {
+		g.Totals = *t
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		for _, d := range Dimensions {
+			if a, b := list[i].Value(d), list[j].Value(d); a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return list, nil
+}
+
+func validDimension(d string) bool {
+	for _, v := range Dimensions {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
+
+// scan calls fn for each record of day. Lines that cannot be parsed, such
+// as one cut short by a crash or still being written, are skipped.
+func (s *Store) scan(day time.Time, fn func(Record)) error {
+	f, err := os.Open(s.path(day))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read usage: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		fn(r)
+	}
+	return scanner.Err()
+}
+
+// Recorder is a backend.Hook that records the usage of every run.
+type Recorder struct {
+	store *Store
+}
+
+// NewRecorder creates a recorder writing to store.
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Start implements backend.Hook.
+func (rec *Recorder) Start(req backend.Request) backend.Finish {
+	started := time.Now()
+	return func(res backend.Result) []backend.Event {
+		// The CLI reports how long the run took; other backends may not
+		duration := int64(res.Usage.DurationMs)
+		if duration == 0 {
+			duration = time.Since(started).Milliseconds()
+		}
+		r := Record{
+			Time:                     time.Now().Unix(),
+			RequestID:                req.RequestID,
+			SessionID:                res.SessionID,
+			Key:                      req.Principal,
+			Model:                    req.Model,
+			Namespace:                req.Namespace,
+			Project:                  req.ProjectID,
+			InputTokens:              res.Usage.InputTokens,
+			OutputTokens:             res.Usage.OutputTokens,
+			CacheCreationInputTokens: res.Usage.CacheCreationInputTokens,
+			CacheReadInputTokens:     res.Usage.CacheReadInputTokens,
+			CostUSD:                  res.Usage.CostUSD,
+			DurationMs:               duration,
+			Error:                    res.Error != "",
+		}
+		if err := rec.store.Add(r); err != nil {
+			log.Error().Err(err).Str("request_id", req.RequestID).Msg("Failed to record usage")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"claude-code-api/internal/backend"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestStore(t)
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, r := range []Record{
+		{Time: day1.Unix(), Key: "alice", Model: "m1", Namespace: "team", Project: "p", InputTokens: 10, OutputTokens: 1, CostUSD: 0.5},
+		{Time: day1.Unix() + 60, Key: "bob", Model: "m1", Project: "default", InputTokens: 20, OutputTokens: 2},
+		{Time: day2.Unix(), Key: "alice", Model: "m2", Namespace: "team", Project: "p", InputTokens: 30, OutputTokens: 3, CacheReadInputTokens: 5},
+	} {
+		if err := s.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := s.Query(Query{Start: day1.Add(-time.Hour), End: day2.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Totals{Requests: 3, InputTokens: 60, OutputTokens: 6, CacheReadInputTokens: 5, CostUSD: 0.5}
+	if len(all) != 1 || all[0].Totals != want {
+		t.Errorf("totals = %+v, want %+v", all, want)
+	}
+
+	byDayKey, err := s.Query(Query{Start: day1.Add(-time.Hour), End: day2.Add(time.Hour), GroupBy: []string{ByKey, ByDay}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([][2]string, len(byDayKey))
+	for i, g := range byDayKey {
+		got[i] = [2]string{g.Day, g.Key}
+	}
+	wantGroups := [][2]string{{"2026-03-01", "alice"}, {"2026-03-01", "bob"}, {"2026-03-02", "alice"}}
+	if len(got) != len(wantGroups) {
+		t.Fatalf("groups = %v, want %v", got, wantGroups)
+	}
+	for i := range got {
+		if got[i] != wantGroups[i] {
+			t.Errorf("group %d = %v, want %v", i, got[i], wantGroups[i])
+		}
+	}
+
+	// Projects are named as clients write them; the end is excluded
+	byProject, err := s.Query(Query{Start: day1, End: day2, GroupBy: []string{ByProject}, Key: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byProject) != 1 || byProject[0].Project != "team:p" || byProject[0].Requests != 1 {
+		t.Errorf("alice by project = %+v", byProject)
+	}
+
+	// Keys of one owner add up; keys are their own owner by default
+	owners := map[string]string{"alice": "ops", "bob": "ops"}
+	byOwner, err := s.Query(Query{
+		Start:    day1.Add(-time.Hour),
+		End:      day2.Add(time.Hour),
+		GroupBy:  []string{ByOwner},
+		Describe: func(key string) (string, string) { return key, owners[key] },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byOwner) != 1 || byOwner[0].Owner != "ops" || byOwner[0].Key != "" || byOwner[0].Requests != 3 {
+		t.Errorf("by owner = %+v", byOwner)
+	}
+	byKey, _ := s.Query(Query{Start: day1, End: day2, GroupBy: []string{ByKey}})
+	if len(byKey) != 2 || byKey[0].Name != "alice" || byKey[0].Owner != "alice" {
+		t.Errorf("by key = %+v", byKey)
+	}
+
+	if _, err := s.Query(Query{Start: day1, End: day2, GroupBy: []string{"colour"}}); !errors.Is(err, ErrInvalidDimension) {
+		t.Errorf("invalid dimension error = %v", err)
+	}
+}
+
+func TestQuerySkipsBrokenLines(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	s.Add(Record{Time: now.Unix(), Model: "m", InputTokens: 1})
+
+	// A line cut short, as by a crash mid-write
+	f, err := os.OpenFile(s.path(now), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":` + "\n")
+	f.Close()
+	s.Add(Record{Time: now.Unix(), Model: "m", InputTokens: 2})
+
+	groups, err := s.Query(Query{Start: now.Add(-time.Minute), End: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Requests != 2 || groups[0].InputTokens != 3 {
+		t.Errorf("groups = %+v", groups)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	s := newTestStore(t)
+	hook := NewRecorder(s)
+
+	finish := hook.Start(backend.Request{RequestID: "req", Principal: "alice", Model: "m", Namespace: "team", ProjectID: "p"})
+	finish(backend.Result{
+		SessionID: "sess",
+		Usage:     backend.Usage{InputTokens: 7, OutputTokens: 3, CostUSD: 0.1, DurationMs: 1500},
+		Error:     "failed",
+	})
+
+	var got []Record
+	now := time.Now()
+	s.scan(now, func(r Record) { got = append(got, r) })
+	if len(got) != 1 {
+		t.Fatalf("records = %+v", got)
+	}
+	r := got[0]
+	if r.RequestID != "req" || r.SessionID != "sess" || r.Key != "alice" || r.Namespace != "team" || r.Project != "p" ||
+		r.InputTokens != 7 || r.OutputTokens != 3 || r.DurationMs != 1500 || !r.Error {
+		t.Errorf("record = %+v", r)
+	}
+}